@@ -3,28 +3,109 @@ package constants
 const (
 	// Context Keys
 	ContextKeyIsLoggedIn = "isLoggedIn"
-	ContextKeySettings   = "settings"
+	ContextKeySettings   = "settings" // 只包含公开设置
+	// 是否已生成 MetaWeblog 专用密码，用于在页面中声明 RSD
+	ContextKeyMetaWeblogEnabled = "metaWeblogEnabled"
+	// 通过访问令牌认证的 API 请求所用的令牌
+	ContextKeyAPIToken = "apiToken"
+	// 已登录会话的 CSRF 令牌，由 render 传给模板
+	ContextKeyCSRFToken = "csrfToken"
+	// 本次请求的 CSP nonce，内联脚本需要带上它才能执行
+	ContextKeyCSPNonce = "cspNonce"
+	// 没有登录会话或访问令牌的请求（如 MetaWeblog）在审计日志中的操作者
+	ContextKeyAuditActor = "auditActor"
 
 	// Session Keys
 	SessionKeyAuthenticated = "authenticated"
 	SessionKeySuccessFlash  = "success_flash"
+	// 密码已验证、等待两步验证码时保存验证密码的 Unix 时间
+	SessionKeyTwoFactorPending = "two_factor_pending"
+	// 设置两步验证期间尚未确认的密钥
+	SessionKeyTOTPEnrollment = "totp_enrollment"
+	// 通行密钥注册与登录仪式进行中的挑战数据
+	SessionKeyPasskeyRegistration = "passkey_registration"
+	SessionKeyPasskeyLogin        = "passkey_login"
+	// 单点登录跳转到身份提供方期间的 state、nonce 和 PKCE 校验码
+	SessionKeyOIDCLogin = "oidc_login"
+	// 登录后生成的 CSRF 同步令牌
+	SessionKeyCSRFToken = "csrf_token"
 
 	// Setting Keys
-	SettingPassword             = "password"
-	SettingFavicon              = "favicon"
-	SettingOpenAIBaseURL        = "openai_base_url"
-	SettingOpenAIToken          = "openai_token"
-	SettingOpenAIModel          = "openai_model"
-	SettingGithubRepo           = "github_repo"
-	SettingGithubBranch         = "github_branch"
-	SettingGithubToken          = "github_token"
-	SettingGithubBackupCron     = "github_backup_cron"
-	SettingGithubLastBackupHash = "github_last_backup_hash"
-	SettingWebdavURL            = "webdav_url"
-	SettingWebdavUser           = "webdav_user"
-	SettingWebdavPassword       = "webdav_password"
-	SettingWebdavBackupCron     = "webdav_backup_cron"
-	SettingWebdavLastBackupHash = "webdav_last_backup_hash"
+	SettingPassword               = "password" // argon2id 哈希，旧版本中为明文
+	SettingPasswordChangeRequired = "password_change_required"
+	SettingBackupPassword         = "backup_password"
+	SettingBackupPasswordRandom   = "backup_password_random" // 为 true 时备份密码由系统随机生成，管理员尚未修改
+	SettingFavicon                = "favicon"
+	SettingSiteTitle              = "site_title"
+	SettingSiteDescription        = "site_description"
+	SettingSiteURL                = "site_url"
+	SettingSiteAuthor             = "site_author"
+	SettingFeedContent            = "feed_content"
+	SettingRobotsTxt              = "robots_txt"
+	SettingOpenAIBaseURL          = "openai_base_url"
+	SettingOpenAIToken            = "openai_token"
+	SettingOpenAIModel            = "openai_model"
+	SettingOpenAIEmbeddingModel   = "openai_embedding_model"
+	SettingGithubRepo             = "github_repo"
+	SettingGithubBranch           = "github_branch"
+	SettingGithubToken            = "github_token"
+	SettingGithubBackupCron       = "github_backup_cron"
+	SettingGithubLastBackupHash   = "github_last_backup_hash"
+	SettingWebdavURL              = "webdav_url"
+	SettingWebdavUser             = "webdav_user"
+	SettingWebdavPassword         = "webdav_password"
+	SettingWebdavBackupCron       = "webdav_backup_cron"
+	SettingWebdavLastBackupHash   = "webdav_last_backup_hash"
+	SettingAskEnabled             = "ask_enabled"
+	SettingAskRateLimit           = "ask_rate_limit"
+	SettingActivityPubEnabled     = "activitypub_enabled"
+	SettingActivityPubUsername    = "activitypub_username"
+	SettingActivityPubKey         = "activitypub_private_key"
+	SettingWebmentionEnabled      = "webmention_enabled"
+	SettingWebSubHubs             = "websub_hubs"
+	SettingIndexNowEnabled        = "indexnow_enabled"
+	SettingIndexNowKey            = "indexnow_key"
+	SettingIndexNowEndpoint       = "indexnow_endpoint"
+	SettingBaiduPushAPI           = "baidu_push_api"
+	// SettingMetaWeblogPasswordHash 保存桌面编辑器专用密码的 SHA-256 摘要
+	SettingMetaWeblogPasswordHash = "metaweblog_password_hash"
+	// 会话 Cookie 的签名密钥，轮换后旧密钥保留一轮
+	SettingSessionSecret         = "session_secret"
+	SettingSessionSecretPrevious = "session_secret_previous"
+	// SettingTrustedProxies 可信反向代理的 IP 或 CIDR，修改后重启生效
+	SettingTrustedProxies = "trusted_proxies"
+	// 两步验证：TOTP 密钥为空表示未启用，恢复码只保存 SHA-256 摘要
+	SettingTOTPSecret        = "totp_secret"
+	SettingTOTPLastStep      = "totp_last_step"
+	SettingTOTPRecoveryCodes = "totp_recovery_codes"
+	// SettingHTMLIframeHosts 文章中允许嵌入 iframe 的主机名，每行一个
+	SettingHTMLIframeHosts = "html_iframe_hosts"
+	// SettingWebAuthnUserID 是通行密钥中管理员账户的随机用户标识
+	SettingWebAuthnUserID = "webauthn_user_id"
+	// SettingCSPMode 取值 enforce、report-only 或 off
+	SettingCSPMode = "csp_mode"
+	// SettingCSPExtra 追加到 CSP 的来源，每行一条指令，如 "script-src https://cdn.example.com"
+	SettingCSPExtra = "csp_extra"
+	// SettingFrameAncestors 允许嵌入本站页面的来源，留空则不限制
+	SettingFrameAncestors = "frame_ancestors"
+	// SettingHSTSMaxAge 是通过 HTTPS 访问时 HSTS 的有效秒数，0 表示不发送
+	SettingHSTSMaxAge = "hsts_max_age"
+	// SettingAuditRetentionDays 是审计日志的保留天数，0 表示永久保留
+	SettingAuditRetentionDays = "audit_retention_days"
+	// SettingOIDCEnabled 为 "true" 时登录页提供单点登录
+	SettingOIDCEnabled = "oidc_enabled"
+	// SettingOIDCName 是登录按钮上显示的身份提供方名称
+	SettingOIDCName         = "oidc_name"
+	SettingOIDCIssuer       = "oidc_issuer"
+	SettingOIDCClientID     = "oidc_client_id"
+	SettingOIDCClientSecret = "oidc_client_secret"
+	// SettingOIDCAllowedSubjects、SettingOIDCAllowedEmails 和 SettingOIDCAllowedGroups
+	// 是允许登录的账号，每行一个，满足任意一条即可
+	SettingOIDCAllowedSubjects = "oidc_allowed_subjects"
+	SettingOIDCAllowedEmails   = "oidc_allowed_emails"
+	SettingOIDCAllowedGroups   = "oidc_allowed_groups"
+	// SettingOIDCGroupsClaim 是 ID Token 中保存用户组的声明名称
+	SettingOIDCGroupsClaim = "oidc_groups_claim"
 
 	// DEPRECATED: These are for backward compatibility with old setting keys.
 	// They are now replaced by SettingGithubBackupCron and SettingWebdavBackupCron.
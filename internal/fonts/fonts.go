@@ -0,0 +1,13 @@
+// Package fonts embeds the fonts used for server-side image rendering.
+package fonts
+
+import _ "embed"
+
+// WQYMicroHei is WenQuanYi Micro Hei (Apache License 2.0), which covers Latin,
+// CJK and full-width punctuation so post titles in any of them render without
+// tofu. It is the regular face extracted from the upstream .ttc with 4-byte
+// aligned tables, as golang.org/x/image/font/sfnt requires, and without the
+// glyph name table.
+//
+//go:embed wqy-microhei.ttf
+var WQYMicroHei []byte
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"glog/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const inboxMaxBodyBytes = 1 << 20
+
+type ActivityPubHandler struct {
+	activityPubService *services.ActivityPubService
+}
+
+func NewActivityPubHandler(activityPubService *services.ActivityPubService) *ActivityPubHandler {
+	return &ActivityPubHandler{activityPubService: activityPubService}
+}
+
+// wantsActivityJSON reports whether the client asked for an ActivityPub
+// representation rather than HTML.
+func wantsActivityJSON(c *gin.Context) bool {
+	accept := c.GetHeader("Accept")
+	return strings.Contains(accept, "application/activity+json") ||
+		(strings.Contains(accept, "application/ld+json") && strings.Contains(accept, "activitystreams"))
+}
+
+// writeActivity sends an ActivityPub document or maps the service error to a status.
+func writeActivity(c *gin.Context, contentType string, doc map[string]interface{}, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrActivityPubDisabled), errors.Is(err, services.ErrActivityPubNotFound):
+			c.Status(http.StatusNotFound)
+		default:
+			log.Printf("ActivityPub 请求处理失败: %v", err)
+			c.Status(http.StatusInternalServerError)
+		}
+		return
+	}
+	c.Header("Content-Type", contentType)
+	c.JSON(http.StatusOK, doc)
+}
+
+func (h *ActivityPubHandler) WebFinger(c *gin.Context) {
+	doc, err := h.activityPubService.WebFinger(c.Query("resource"))
+	writeActivity(c, "application/jrd+json; charset=utf-8", doc, err)
+}
+
+func (h *ActivityPubHandler) Actor(c *gin.Context) {
+	// 浏览器直接访问时跳转到首页
+	if !wantsActivityJSON(c) && strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	doc, err := h.activityPubService.Actor()
+	writeActivity(c, "application/activity+json; charset=utf-8", doc, err)
+}
+
+func (h *ActivityPubHandler) Outbox(c *gin.Context) {
+	doc, err := h.activityPubService.Outbox()
+	writeActivity(c, "application/activity+json; charset=utf-8", doc, err)
+}
+
+func (h *ActivityPubHandler) Followers(c *gin.Context) {
+	doc, err := h.activityPubService.Followers()
+	writeActivity(c, "application/activity+json; charset=utf-8", doc, err)
+}
+
+func (h *ActivityPubHandler) Inbox(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, inboxMaxBodyBytes))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	err = h.activityPubService.HandleInbox(c.Request, body)
+	switch {
+	case err == nil:
+		c.Status(http.StatusAccepted)
+	case errors.Is(err, services.ErrActivityPubDisabled):
+		c.Status(http.StatusNotFound)
+	case errors.Is(err, services.ErrInboxUnauthorized):
+		log.Printf("ActivityPub 收件箱拒绝请求: %v", err)
+		c.Status(http.StatusUnauthorized)
+	case errors.Is(err, services.ErrInboxBadRequest):
+		c.Status(http.StatusBadRequest)
+	default:
+		log.Printf("ActivityPub 收件箱处理失败: %v", err)
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+// NegotiatePost answers ActivityPub requests for /post/:slug with the Article
+// object and hands everything else on to the HTML page.
+func (h *ActivityPubHandler) NegotiatePost(c *gin.Context) {
+	if !wantsActivityJSON(c) || !h.activityPubService.Enabled() {
+		c.Next()
+		return
+	}
+	doc, err := h.activityPubService.ArticleBySlug(c.Param("slug"))
+	writeActivity(c, "application/activity+json; charset=utf-8", doc, err)
+	c.Abort()
+}
@@ -7,13 +7,18 @@ import (
 	"fmt"
 	"glog/internal/constants"
 	"glog/internal/models"
+	"glog/internal/repository"
 	"glog/internal/services"
 	"glog/internal/tasks"
 	"glog/internal/utils"
+	"html/template"
 	"io"
+	"log"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -23,21 +28,37 @@ import (
 	"github.com/yeka/zip"
 )
 
+var activityPubUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// clearSettingField names the secret settings whose saved value should be
+// removed. An empty secret field otherwise keeps the saved value.
+const clearSettingField = "clear"
+
 type AdminHandler struct {
-	postService    *services.PostService
-	settingService *services.SettingService
-	aiService      *services.AIService
-	backupService  *services.BackupService
-	scheduler      *tasks.Scheduler
+	postService        *services.PostService
+	settingService     *services.SettingService
+	aiService          *services.AIService
+	backupService      *services.BackupService
+	scheduler          *tasks.Scheduler
+	activityPubService *services.ActivityPubService
+	tokenService       *services.TokenService
+	webhookService     *services.WebhookService
+	passkeyService     *services.PasskeyService
+	auditService       *services.AuditService
 }
 
-func NewAdminHandler(postService *services.PostService, settingService *services.SettingService, aiService *services.AIService, backupService *services.BackupService, scheduler *tasks.Scheduler) *AdminHandler {
+func NewAdminHandler(postService *services.PostService, settingService *services.SettingService, aiService *services.AIService, backupService *services.BackupService, scheduler *tasks.Scheduler, activityPubService *services.ActivityPubService, tokenService *services.TokenService, webhookService *services.WebhookService, passkeyService *services.PasskeyService, auditService *services.AuditService) *AdminHandler {
 	return &AdminHandler{
-		postService:    postService,
-		settingService: settingService,
-		aiService:      aiService,
-		backupService:  backupService,
-		scheduler:      scheduler,
+		postService:        postService,
+		settingService:     settingService,
+		aiService:          aiService,
+		backupService:      backupService,
+		scheduler:          scheduler,
+		activityPubService: activityPubService,
+		tokenService:       tokenService,
+		webhookService:     webhookService,
+		passkeyService:     passkeyService,
+		auditService:       auditService,
 	}
 }
 
@@ -49,24 +70,94 @@ func (h *AdminHandler) UpdateSettings(c *gin.Context) {
 		return
 	}
 
+	// 敏感设置留空表示不修改，需要勾选“清除”才会删除已保存的值
+	for _, key := range c.Request.PostForm[clearSettingField] {
+		if services.SettingVisibilityOf(key) != services.SettingSecret || services.IsSystemSetting(key) || key == constants.SettingPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "不能清除设置 " + key})
+			return
+		}
+		if _, viaToken := c.Get(constants.ContextKeyAPIToken); viaToken && !services.TokenMayWrite(key) {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "访问令牌不能修改设置 " + key})
+			return
+		}
+		settingsToUpdate[key] = ""
+	}
+
 	for key, values := range c.Request.PostForm {
+		if key == clearSettingField {
+			continue
+		}
+		if _, cleared := settingsToUpdate[key]; cleared {
+			continue
+		}
 		if len(values) > 0 {
 			value := values[0]
-			if (key == constants.SettingPassword || key == constants.SettingOpenAIToken || key == constants.SettingGithubToken || key == constants.SettingWebdavPassword) && value == "" {
+			if _, viaToken := c.Get(constants.ContextKeyAPIToken); viaToken && !services.TokenMayWrite(key) {
+				c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "访问令牌不能修改设置 " + key})
+				return
+			}
+			// 敏感设置不会回显到表单中，留空表示不修改
+			if services.SettingVisibilityOf(key) == services.SettingSecret && value == "" {
+				continue
+			}
+			// 密钥类设置由系统生成，不允许通过表单修改
+			if services.IsSystemSetting(key) || key == "csrf_token" {
 				continue
 			}
+			if err := services.ValidateSecuritySetting(key, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+				return
+			}
+			if key == constants.SettingTrustedProxies {
+				if _, err := services.ParseTrustedProxies(value); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+					return
+				}
+			}
+			// 修改管理员密码需要验证当前密码，只能在修改密码页面进行
+			if key == constants.SettingPassword {
+				c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "请在修改密码页面修改管理员密码"})
+				return
+			}
+			if key == constants.SettingBackupPassword {
+				settingsToUpdate[constants.SettingBackupPasswordRandom] = "false"
+			}
+			if key == constants.SettingActivityPubUsername && !activityPubUsernamePattern.MatchString(value) {
+				c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "ActivityPub 用户名只能包含字母、数字和下划线"})
+				return
+			}
 			settingsToUpdate[key] = value
 		}
 	}
 
+	before, _ := h.settingService.GetAllSettings()
+	if err := services.ValidateSettingDependencies(before, settingsToUpdate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
 	err := h.settingService.UpdateSettings(settingsToUpdate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "更新设置失败"})
 		return
 	}
+	changes := services.DiffSettings(before, settingsToUpdate)
+	if len(changes) > 0 {
+		recordAudit(c, h.auditService, services.AuditSettingsUpdate, "站点设置", changes)
+	}
 
 	go h.scheduler.ReloadTasks()
 
+	// 过滤规则依赖 iframe 白名单和站点地址，变化后重新渲染已有文章
+	_, hostsChanged := settingsToUpdate[constants.SettingHTMLIframeHosts]
+	_, siteURLChanged := settingsToUpdate[constants.SettingSiteURL]
+	if hostsChanged || siteURLChanged {
+		go func() {
+			if err := h.postService.RerenderAll(); err != nil {
+				log.Printf("重新渲染文章失败: %v", err)
+			}
+		}()
+	}
+
 	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "设置已成功保存！"})
 }
 
@@ -76,10 +167,33 @@ func (h *AdminHandler) ListPosts(c *gin.Context) {
 	if pageSize <= 0 {
 		pageSize = 10
 	}
-	query := c.Query("q")
-	status := c.DefaultQuery("status", "all")
 
-	posts, total, err := h.postService.GetPostsPageByAdmin(page, pageSize, query, status)
+	filter := repository.AdminPostFilter{
+		Query:  c.Query("q"),
+		Status: c.DefaultQuery("status", "all"),
+		Sort:   c.DefaultQuery("sort", "published"),
+		Order:  c.DefaultQuery("order", "desc"),
+	}
+	if filter.Order != "asc" {
+		filter.Order = "desc"
+	}
+
+	// 日期范围以上海时区的自然日为单位，结束日期包含当天
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	from := c.Query("from")
+	if t, err := time.ParseInLocation("2006-01-02", from, loc); err == nil {
+		filter.From = t
+	} else {
+		from = ""
+	}
+	to := c.Query("to")
+	if t, err := time.ParseInLocation("2006-01-02", to, loc); err == nil {
+		filter.To = t.AddDate(0, 0, 1)
+	} else {
+		to = ""
+	}
+
+	posts, total, err := h.postService.GetPostsPageByAdmin(page, pageSize, filter)
 	if err != nil {
 		c.String(http.StatusInternalServerError, "加载文章失败")
 		return
@@ -88,6 +202,27 @@ func (h *AdminHandler) ListPosts(c *gin.Context) {
 	totalPages := int(math.Ceil(float64(total) / float64(pageSize)))
 	pagination := utils.GeneratePagination(page, totalPages)
 
+	// 将当前的筛选条件编码进查询字符串，供分页链接保留筛选状态
+	filterQuery := url.Values{}
+	if filter.Query != "" {
+		filterQuery.Set("q", filter.Query)
+	}
+	if filter.Status != "all" {
+		filterQuery.Set("status", filter.Status)
+	}
+	if filter.Sort != "published" {
+		filterQuery.Set("sort", filter.Sort)
+	}
+	if filter.Order != "desc" {
+		filterQuery.Set("order", filter.Order)
+	}
+	if from != "" {
+		filterQuery.Set("from", from)
+	}
+	if to != "" {
+		filterQuery.Set("to", to)
+	}
+
 	session := sessions.Default(c)
 	flashes := session.Flashes(constants.SessionKeySuccessFlash)
 	session.Save()
@@ -95,8 +230,13 @@ func (h *AdminHandler) ListPosts(c *gin.Context) {
 	render(c, http.StatusOK, "admin.html", gin.H{
 		"posts":           posts,
 		"Pagination":      pagination,
-		"Query":           query,
-		"Status":          status,
+		"Query":           filter.Query,
+		"Status":          filter.Status,
+		"Sort":            filter.Sort,
+		"Order":           filter.Order,
+		"From":            from,
+		"To":              to,
+		"FilterQuery":     template.URL(filterQuery.Encode()),
 		"Flashes":         flashes,
 		"PageSize":        pageSize,
 		"PageSizeOptions": []int{10, 20, 50},
@@ -145,6 +285,8 @@ func (h *AdminHandler) SavePost(c *gin.Context) {
 	content := c.PostForm("content")
 	publishedAtStr := c.PostForm("published_at")
 	isPrivate := c.PostForm("is_private") == "on"
+	noIndex := c.PostForm("no_index") == "on"
+	trustedHTML := c.PostForm("trusted_html") == "on"
 	aiSummary := c.PostForm("ai_summary") == "on"
 
 	loc, err := time.LoadLocation("Asia/Shanghai")
@@ -171,12 +313,16 @@ func (h *AdminHandler) SavePost(c *gin.Context) {
 
 	var post *models.Post
 	var aiTriggered bool
+	var before models.Post
 
 	if idStr == "" || idStr == "0" {
-		post, aiTriggered, err = h.postService.CreatePost(title, content, isPrivate, aiSummary, publishedAt)
+		post, aiTriggered, err = h.postService.CreatePost(title, content, isPrivate, noIndex, trustedHTML, aiSummary, publishedAt)
 	} else {
 		id, _ := strconv.ParseUint(idStr, 10, 64)
-		post, aiTriggered, err = h.postService.UpdatePost(uint(id), title, content, isPrivate, aiSummary, publishedAt)
+		if existing, findErr := h.postService.GetPostByID(uint(id)); findErr == nil {
+			before = *existing
+		}
+		post, aiTriggered, err = h.postService.UpdatePost(uint(id), title, content, isPrivate, noIndex, trustedHTML, aiSummary, publishedAt)
 	}
 
 	if err != nil {
@@ -188,12 +334,20 @@ func (h *AdminHandler) SavePost(c *gin.Context) {
 	}
 
 	if post == nil {
+		if before.ID != 0 {
+			recordAudit(c, h.auditService, services.AuditPostDelete, services.PostAuditTarget(&before), nil)
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"status":  "deleted",
 			"message": "文章内容为空，已自动删除。",
 		})
 		return
 	}
+	if before.ID == 0 {
+		recordAudit(c, h.auditService, services.AuditPostCreate, services.PostAuditTarget(post), nil)
+	} else if changes := services.DiffPost(&before, post); len(changes) > 0 {
+		recordAudit(c, h.auditService, services.AuditPostUpdate, services.PostAuditTarget(post), changes)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
@@ -230,17 +384,58 @@ func (h *AdminHandler) DeletePost(c *gin.Context) {
 		return
 	}
 
+	target := fmt.Sprintf("文章 #%d", id)
+	if post, err := h.postService.GetPostByID(uint(id)); err == nil {
+		target = services.PostAuditTarget(post)
+	}
 	err = h.postService.DeletePost(uint(id))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "删除文章失败"})
 		return
 	}
+	recordAudit(c, h.auditService, services.AuditPostDelete, target, nil)
 
 	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "文章已成功删除"})
 }
 
 func (h *AdminHandler) ShowSettingsPage(c *gin.Context) {
-	render(c, http.StatusOK, "settings.html", gin.H{})
+	tokens, err := h.tokenService.List()
+	if err != nil {
+		log.Printf("获取访问令牌失败: %v", err)
+	}
+	webhooks, err := h.webhookService.List()
+	if err != nil {
+		log.Printf("获取 Webhook 失败: %v", err)
+	}
+	deliveries, err := h.webhookService.Deliveries()
+	if err != nil {
+		log.Printf("获取 Webhook 投递记录失败: %v", err)
+	}
+	passkeys, err := h.passkeyService.List()
+	if err != nil {
+		log.Printf("获取通行密钥失败: %v", err)
+	}
+	data := gin.H{
+		"Configured":           h.settingService.ConfiguredSecrets(),
+		"Passkeys":             passkeys,
+		"DefaultRobotsTxt":     services.DefaultRobotsTxt,
+		"SiteBaseURL":          siteURL(c),
+		"ActivityPubHandle":    h.activityPubService.Handle(),
+		"ActivityPubFollowers": h.activityPubService.FollowerCount(),
+		"Tokens":               tokens,
+		"TokenScopes":          services.TokenScopes,
+		"Webhooks":             webhooks,
+		"WebhookEvents":        services.WebhookEvents,
+		"WebhookDeliveries":    deliveries,
+	}
+	for key, value := range h.settingService.AdminSettings() {
+		data[key] = value
+	}
+	// 系统生成的备份密码只有管理员看过才能用来解密备份
+	if data[constants.SettingBackupPasswordRandom] == "true" {
+		data["RandomBackupPassword"], _ = h.settingService.GetSetting(constants.SettingBackupPassword)
+	}
+	render(c, http.StatusOK, "settings.html", data)
 }
 
 func (h *AdminHandler) TestAISettings(c *gin.Context) {
@@ -264,17 +459,24 @@ func (h *AdminHandler) TestAISettings(c *gin.Context) {
 		return
 	}
 
+	if embeddingModel := c.PostForm(constants.SettingOpenAIEmbeddingModel); embeddingModel != "" {
+		if _, err := h.aiService.CreateEmbeddings([]string{testContent}, baseURL, finalToken, embeddingModel); err != nil {
+			c.JSON(http.StatusOK, gin.H{"status": "error", "message": "Embedding 测试失败: " + err.Error()})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "测试成功！连接和配置均有效。"})
 }
 
 func (h *AdminHandler) BackupSite(c *gin.Context) {
-	password, err := h.settingService.GetSetting(constants.SettingPassword)
+	password, err := h.settingService.GetSetting(constants.SettingBackupPassword)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "获取站点密码失败: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "获取备份密码失败: " + err.Error()})
 		return
 	}
 	if password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "请先设置站点密码，备份文件需要加密。"})
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "请先设置备份密码，备份文件需要加密。"})
 		return
 	}
 
@@ -289,6 +491,7 @@ func (h *AdminHandler) BackupSite(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "获取设置失败: " + err.Error()})
 		return
 	}
+	services.StripBackupCredentials(settings)
 
 	backupData := models.SiteBackup{
 		Posts:    posts,
@@ -315,6 +518,7 @@ func (h *AdminHandler) BackupSite(c *gin.Context) {
 	}
 	zipWriter.Close()
 
+	recordAudit(c, h.auditService, services.AuditBackupDownload, fmt.Sprintf("%d 篇文章", len(posts)), nil)
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=glog_backup_%s.zip", time.Now().Format("20060102150405")))
 	c.Data(http.StatusOK, "application/zip", buf.Bytes())
@@ -324,6 +528,7 @@ func (h *AdminHandler) UploadBackup(c *gin.Context) {
 	contentType := c.GetHeader("Content-Type")
 	var backupData models.SiteBackup
 	var postCount int
+	before, _ := h.settingService.GetAllSettings()
 
 	if strings.Contains(contentType, "application/json") {
 		if err := c.ShouldBindJSON(&backupData); err != nil {
@@ -398,25 +603,17 @@ func (h *AdminHandler) UploadBackup(c *gin.Context) {
 		postCount = importedCount
 	}
 
+	after, _ := h.settingService.GetAllSettings()
+	recordAudit(c, h.auditService, services.AuditBackupRestore, fmt.Sprintf("导入 %d 篇文章", postCount), services.DiffSettings(before, after))
+
 	c.JSON(http.StatusOK, gin.H{"status": "success", "message": fmt.Sprintf("恢复成功！导入 %d 篇文章并更新了站点设置。", postCount)})
 }
 
 func (h *AdminHandler) restoreFromBackupData(backupData *models.SiteBackup) error {
-	if len(backupData.Settings) > 0 {
-		if newPass, ok := backupData.Settings[constants.SettingPassword]; !ok || newPass == "" {
-			delete(backupData.Settings, constants.SettingPassword)
-		}
-
-		if err := h.settingService.UpdateSettings(backupData.Settings); err != nil {
-			return fmt.Errorf("恢复设置失败: %w", err)
-		}
-	}
-
 	if err := h.postService.CreatePostsFromBackup(backupData.Posts); err != nil {
 		return fmt.Errorf("导入文章失败: %w", err)
 	}
-
-	return nil
+	return h.postService.RestoreSettings(backupData.Settings)
 }
 
 type BatchUpdateRequest struct {
@@ -442,6 +639,15 @@ func (h *AdminHandler) BatchUpdatePosts(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "操作失败: " + err.Error()})
 		return
 	}
+	ids := make([]string, len(req.IDs))
+	for i, id := range req.IDs {
+		ids[i] = "#" + strconv.FormatUint(uint64(id), 10)
+	}
+	changes := []models.AuditChange{{Key: "action", New: req.Action}}
+	if req.Action != "delete" {
+		changes = append(changes, models.AuditChange{Key: "is_private", New: strconv.FormatBool(req.IsPrivate)})
+	}
+	recordAudit(c, h.auditService, services.AuditPostBatch, "文章 "+strings.Join(ids, ", "), changes)
 
 	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "操作成功！"})
 }
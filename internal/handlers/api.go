@@ -10,12 +10,16 @@ import (
 )
 
 type APIHandler struct {
-	postService *services.PostService
+	postService    *services.PostService
+	settingService *services.SettingService
+	auditService   *services.AuditService
 }
 
-func NewAPIHandler(postService *services.PostService) *APIHandler {
+func NewAPIHandler(postService *services.PostService, settingService *services.SettingService, auditService *services.AuditService) *APIHandler {
 	return &APIHandler{
-		postService: postService,
+		postService:    postService,
+		settingService: settingService,
+		auditService:   auditService,
 	}
 }
 
@@ -29,11 +33,12 @@ func (h *APIHandler) CreatePost(c *gin.Context) {
 
 	// For API creation, we don't trigger AI summary by default.
 	// PublishedAt will be set by the service if not provided.
-	createdPost, _, err := h.postService.CreatePost(post.Title, post.Content, post.IsPrivate, false, post.PublishedAt)
+	createdPost, _, err := h.postService.CreatePost(post.Title, post.Content, post.IsPrivate, post.NoIndex, false, false, post.PublishedAt)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	recordAudit(c, h.auditService, services.AuditPostCreate, services.PostAuditTarget(createdPost), nil)
 
 	c.JSON(http.StatusCreated, createdPost)
 }
@@ -76,3 +81,10 @@ func (h *APIHandler) FindPosts(c *gin.Context) {
 		"total": total,
 	})
 }
+
+// GetSettings returns the site settings except secrets. Settings are changed
+// with POST /api/v1/settings, which takes the same form fields as the
+// settings page.
+func (h *APIHandler) GetSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, h.settingService.AdminSettings())
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"glog/internal/constants"
+	"glog/internal/services"
+	"glog/internal/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AskHandler struct {
+	askService     *services.AskService
+	settingService *services.SettingService
+	limiter        *utils.RateLimiter
+}
+
+func NewAskHandler(askService *services.AskService, settingService *services.SettingService) *AskHandler {
+	return &AskHandler{
+		askService:     askService,
+		settingService: settingService,
+		limiter:        utils.NewRateLimiter(),
+	}
+}
+
+func (h *AskHandler) ShowAskPage(c *gin.Context) {
+	if !h.askService.Enabled() {
+		render(c, http.StatusNotFound, "404.html", gin.H{})
+		return
+	}
+	render(c, http.StatusOK, "ask.html", gin.H{
+		"question": c.Query("q"),
+	})
+}
+
+// Stream answers a question over Server-Sent Events. It emits a "sources"
+// event with the cited posts, "delta" events with answer text, and finally
+// either "done" or "error".
+func (h *AskHandler) Stream(c *gin.Context) {
+	if !h.askService.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": services.ErrAskDisabled.Error()})
+		return
+	}
+
+	isLoggedInValue, exists := c.Get(constants.ContextKeyIsLoggedIn)
+	isLoggedIn := exists && isLoggedInValue.(bool)
+
+	// 登录用户不受频率限制
+	if !isLoggedIn {
+		limitStr, _ := h.settingService.GetSetting(constants.SettingAskRateLimit)
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			limit = 10
+		}
+		if !h.limiter.Allow(c.ClientIP(), limit, time.Hour) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"status": "error", "message": "提问过于频繁，请稍后再试。"})
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁止 Nginx 缓冲，保证逐字输出
+
+	ctx := c.Request.Context()
+	send := func(event string, data interface{}) error {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+		return ctx.Err()
+	}
+
+	err := h.askService.Ask(ctx, c.Query("q"), isLoggedIn,
+		func(sources []services.AskSource) error {
+			if sources == nil {
+				sources = []services.AskSource{}
+			}
+			return send("sources", sources)
+		},
+		func(delta string) error {
+			return send("delta", gin.H{"text": delta})
+		},
+	)
+	if err != nil {
+		if ctx.Err() != nil {
+			return // 客户端已断开
+		}
+		message := "回答生成失败，请稍后再试。"
+		if errors.Is(err, services.ErrAskBadRequest) || errors.Is(err, services.ErrAskDisabled) {
+			message = err.Error()
+		} else {
+			log.Printf("问答生成失败: %v", err)
+		}
+		send("error", gin.H{"message": message})
+		return
+	}
+	send("done", gin.H{})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"glog/internal/services"
+	"glog/internal/utils"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const auditLogPageSize = 50
+
+// auditActor names who made the request: an access token, the admin's
+// session, or the admin password used on the API.
+func auditActor(c *gin.Context) string {
+	if actor := c.GetString(constants.ContextKeyAuditActor); actor != "" {
+		return actor
+	}
+	if value, exists := c.Get(constants.ContextKeyAPIToken); exists {
+		return "令牌：" + value.(*models.AccessToken).Name
+	}
+	if authenticated, _ := sessions.Default(c).Get(constants.SessionKeyAuthenticated).(bool); authenticated {
+		return "管理员"
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		return "API（管理员密码）"
+	}
+	return "未知"
+}
+
+// recordAudit appends an entry for the current request to the audit log.
+func recordAudit(c *gin.Context, auditService *services.AuditService, action, target string, changes []models.AuditChange) {
+	auditService.Record(auditActor(c), c.ClientIP(), action, target, changes)
+}
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListAuditLogs shows the audit log, filtered by action, actor or keyword.
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	filter := repository.AuditLogFilter{
+		Action:  c.Query("action"),
+		Actor:   c.Query("actor"),
+		Keyword: strings.TrimSpace(c.Query("q")),
+	}
+
+	entries, total, err := h.auditService.List(filter, page, auditLogPageSize)
+	if err != nil {
+		log.Printf("加载审计日志失败: %v", err)
+		c.String(http.StatusInternalServerError, "加载审计日志失败")
+		return
+	}
+	actors, err := h.auditService.Actors()
+	if err != nil {
+		log.Printf("加载操作者失败: %v", err)
+	}
+
+	labels := make(map[string]string, len(services.AuditActions))
+	for _, action := range services.AuditActions {
+		labels[action.Action] = action.Label
+	}
+	query := url.Values{}
+	for key, value := range map[string]string{"action": filter.Action, "actor": filter.Actor, "q": filter.Keyword} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	render(c, http.StatusOK, "audit.html", gin.H{
+		"entries":       entries,
+		"Filter":        filter,
+		"Actions":       services.AuditActions,
+		"ActionLabels":  labels,
+		"Actors":        actors,
+		"FilterQuery":   template.URL(query.Encode()),
+		"RetentionDays": h.auditService.RetentionDays(),
+		"Pagination":    utils.GeneratePagination(page, int(math.Ceil(float64(total)/float64(auditLogPageSize)))),
+	})
+}
@@ -1,40 +1,65 @@
 package handlers
 
 import (
+	"errors"
 	"glog/internal/constants"
+	"glog/internal/models"
 	"glog/internal/services"
+	"glog/internal/utils"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
+// twoFactorTimeout limits how long a verified password waits for the code.
+const twoFactorTimeout = 5 * time.Minute
+
 type AuthHandler struct {
-	settingService *services.SettingService
+	authService      *services.AuthService
+	loginGuard       *services.LoginGuard
+	twoFactorService *services.TwoFactorService
+	passkeyService   *services.PasskeyService
+	oidcService      *services.OIDCService
+	sessionService   *services.SessionService
+	auditService     *services.AuditService
 }
 
-func NewAuthHandler(settingService *services.SettingService) *AuthHandler {
-	return &AuthHandler{settingService: settingService}
+func NewAuthHandler(authService *services.AuthService, loginGuard *services.LoginGuard, twoFactorService *services.TwoFactorService, passkeyService *services.PasskeyService, oidcService *services.OIDCService, sessionService *services.SessionService, auditService *services.AuditService) *AuthHandler {
+	return &AuthHandler{authService: authService, loginGuard: loginGuard, twoFactorService: twoFactorService, passkeyService: passkeyService, oidcService: oidcService, sessionService: sessionService, auditService: auditService}
 }
 
 func (h *AuthHandler) ShowLoginPage(c *gin.Context) {
-	render(c, http.StatusOK, "login.html", gin.H{})
+	h.renderLoginPage(c, http.StatusOK, "")
+}
+
+// renderLoginPage shows the login form. loginError is shown as a
+// notification, for failures of a login that ended in a redirect.
+func (h *AuthHandler) renderLoginPage(c *gin.Context, status int, loginError string) {
+	render(c, status, "login.html", gin.H{
+		"HasPasskeys": h.passkeyService.HasPasskeys(),
+		"OIDCEnabled": h.oidcService.Enabled(),
+		"OIDCName":    h.oidcService.Name(),
+		"LoginError":  loginError,
+	})
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
 	session := sessions.Default(c)
 	submittedPassword := c.PostForm(constants.SettingPassword)
 
-	adminPassword, err := h.settingService.GetSetting(constants.SettingPassword)
+	ok, err := h.loginGuard.Attempt(c.ClientIP(), c.Request.UserAgent(), services.LoginSourceWeb, func() bool {
+		return h.authService.CheckPassword(submittedPassword)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": "服务器内部错误",
-		})
+		respondLoginBlocked(c, err)
 		return
 	}
-
-	if submittedPassword != adminPassword {
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  "error",
 			"message": "密码错误，请重新输入！",
@@ -42,16 +67,248 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	if h.twoFactorService.Enabled() {
+		session.Clear()
+		session.Set(constants.SessionKeyTwoFactorPending, time.Now().Unix())
+		session.Save()
+		c.JSON(http.StatusOK, gin.H{"status": "success", "two_factor": true})
+		return
+	}
+
+	h.completeLogin(c, session, "密码")
+}
+
+// VerifyTwoFactor is the second login step when two-factor authentication
+// is enabled. It accepts an authenticator code or a recovery code.
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	session := sessions.Default(c)
+	pendingSince, _ := session.Get(constants.SessionKeyTwoFactorPending).(int64)
+	if pendingSince == 0 || time.Since(time.Unix(pendingSince, 0)) > twoFactorTimeout {
+		session.Clear()
+		session.Save()
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "登录已过期，请重新输入密码", "restart": true})
+		return
+	}
+
+	code := c.PostForm("code")
+	ok, err := h.loginGuard.Attempt(c.ClientIP(), c.Request.UserAgent(), services.LoginSourceTwoFactor, func() bool {
+		return h.twoFactorService.Verify(code)
+	})
+	if err != nil {
+		respondLoginBlocked(c, err)
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "验证码错误，请重新输入！"})
+		return
+	}
+
+	session.Delete(constants.SessionKeyTwoFactorPending)
+	h.completeLogin(c, session, "密码和两步验证")
+}
+
+// BeginPasskeyLogin starts a WebAuthn assertion for passwordless login.
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	assertion, sessionData, err := h.passkeyService.BeginLogin()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	session := sessions.Default(c)
+	session.Set(constants.SessionKeyPasskeyLogin, sessionData)
+	session.Save()
+	c.JSON(http.StatusOK, gin.H{"status": "success", "options": assertion})
+}
+
+// FinishPasskeyLogin verifies the assertion. A passkey requires user
+// verification on the device, so it replaces both the password and the
+// two-factor code.
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	session := sessions.Default(c)
+	sessionData, _ := session.Get(constants.SessionKeyPasskeyLogin).(string)
+	if sessionData == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "登录已过期，请重试"})
+		return
+	}
+	session.Delete(constants.SessionKeyPasskeyLogin)
+
+	var verifyErr error
+	ok, err := h.loginGuard.Attempt(c.ClientIP(), c.Request.UserAgent(), services.LoginSourcePasskey, func() bool {
+		verifyErr = h.passkeyService.FinishLogin(sessionData, c.Request)
+		return verifyErr == nil
+	})
+	if err != nil {
+		session.Save()
+		respondLoginBlocked(c, err)
+		return
+	}
+	if !ok {
+		session.Save()
+		log.Printf("通行密钥登录失败: %v", verifyErr)
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "通行密钥验证失败"})
+		return
+	}
+
+	session.Delete(constants.SessionKeyTwoFactorPending)
+	h.completeLogin(c, session, "通行密钥")
+}
+
+// BeginOIDCLogin redirects to the OpenID Connect provider.
+func (h *AuthHandler) BeginOIDCLogin(c *gin.Context) {
+	authURL, sessionData, err := h.oidcService.Begin(c.Request.Context())
+	if err != nil {
+		log.Printf("开始单点登录失败: %v", err)
+		h.renderLoginPage(c, http.StatusBadGateway, "无法连接身份提供方，请稍后重试")
+		return
+	}
+	session := sessions.Default(c)
+	session.Set(constants.SessionKeyOIDCLogin, sessionData)
+	session.Save()
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// FinishOIDCLogin handles the redirect back from the provider. Like a
+// passkey, single sign-on replaces both the password and the two-factor
+// code; the provider is expected to enforce its own second factor.
+func (h *AuthHandler) FinishOIDCLogin(c *gin.Context) {
+	session := sessions.Default(c)
+	sessionData, _ := session.Get(constants.SessionKeyOIDCLogin).(string)
+	if sessionData == "" {
+		h.renderLoginPage(c, http.StatusBadRequest, "登录已过期，请重试")
+		return
+	}
+	session.Delete(constants.SessionKeyOIDCLogin)
+	session.Save()
+
+	if providerError := c.Query("error"); providerError != "" {
+		log.Printf("身份提供方拒绝了单点登录: %s %s", providerError, c.Query("error_description"))
+		h.renderLoginPage(c, http.StatusUnauthorized, "身份提供方拒绝了登录")
+		return
+	}
+
+	// 换取令牌需要请求身份提供方，放在 Attempt 之外以免阻塞其他登录
+	identity, verifyErr := h.oidcService.Finish(c.Request.Context(), sessionData, c.Query("state"), c.Query("code"))
+	ok, err := h.loginGuard.Attempt(c.ClientIP(), c.Request.UserAgent(), services.LoginSourceOIDC, func() bool {
+		return verifyErr == nil
+	})
+	if err != nil {
+		h.renderLoginPage(c, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if !ok {
+		log.Printf("单点登录失败: %v", verifyErr)
+		message := "单点登录验证失败"
+		if errors.Is(verifyErr, services.ErrOIDCNotAllowed) || errors.Is(verifyErr, services.ErrOIDCExpired) {
+			message = verifyErr.Error()
+		}
+		h.renderLoginPage(c, http.StatusUnauthorized, message)
+		return
+	}
+
+	session.Delete(constants.SessionKeyTwoFactorPending)
+	c.Redirect(http.StatusFound, h.authenticate(c, session, "单点登录（"+identity.String()+"）"))
+}
+
+// authenticate marks the session as authenticated and returns the page to
+// continue to. method describes how the admin signed in, for the audit log.
+func (h *AuthHandler) authenticate(c *gin.Context, session sessions.Session, method string) string {
 	session.Set(constants.SessionKeyAuthenticated, true)
 	session.Save()
+	recordAudit(c, h.auditService, services.AuditLogin, c.Request.UserAgent(), []models.AuditChange{{Key: "method", New: method}})
+
+	if h.authService.ChangeRequired() {
+		return "/admin/password"
+	}
+	return "/admin/"
+}
+
+// completeLogin finishes a login made from the login page's scripts.
+func (h *AuthHandler) completeLogin(c *gin.Context, session sessions.Session, method string) {
 	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
+		"status":   "success",
+		"redirect": h.authenticate(c, session, method),
 	})
 }
 
+// respondLoginBlocked answers a throttled authentication attempt.
+func respondLoginBlocked(c *gin.Context, err error) {
+	var blocked *services.LoginBlockedError
+	if errors.As(err, &blocked) {
+		c.Header("Retry-After", strconv.Itoa(int(blocked.RetryAfter.Seconds())+1))
+	}
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"status": "error", "message": err.Error()})
+}
+
 func (h *AuthHandler) Logout(c *gin.Context) {
 	session := sessions.Default(c)
+	if authenticated, _ := session.Get(constants.SessionKeyAuthenticated).(bool); authenticated {
+		recordAudit(c, h.auditService, services.AuditLogout, c.Request.UserAgent(), nil)
+	}
 	session.Clear()
 	session.Save()
 	c.Redirect(http.StatusFound, "/login")
 }
+
+func (h *AuthHandler) ShowChangePasswordPage(c *gin.Context) {
+	render(c, http.StatusOK, "password.html", gin.H{
+		"ChangeRequired": h.authService.ChangeRequired(),
+	})
+}
+
+// ChangePassword sets a new admin password after checking the current one,
+// and logs out every other session.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	currentPassword := c.PostForm("current_password")
+	ok, err := h.loginGuard.Attempt(c.ClientIP(), c.Request.UserAgent(), services.LoginSourcePasswordChange, func() bool {
+		return h.authService.CheckPassword(currentPassword)
+	})
+	if err != nil {
+		respondLoginBlocked(c, err)
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "当前密码错误"})
+		return
+	}
+	newPassword := c.PostForm("new_password")
+	if newPassword != c.PostForm("confirm_password") {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "两次输入的新密码不一致"})
+		return
+	}
+	if err := h.authService.SetPassword(newPassword); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrPasswordTooShort) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	// 旧密码可能已经泄露，已登录的其他设备需要用新密码重新登录
+	if err := h.sessionService.RevokeOthers(sessions.Default(c).ID()); err != nil {
+		log.Printf("修改密码后退出其他会话失败: %v", err)
+	}
+	recordAudit(c, h.auditService, services.AuditPasswordChange, "管理员密码", nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "密码已修改，其他设备已退出登录"})
+}
+
+const loginAttemptPageSize = 50
+
+// ListLoginAttempts shows recent successful and failed login attempts.
+func (h *AuthHandler) ListLoginAttempts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	attempts, total, err := h.loginGuard.List(page, loginAttemptPageSize)
+	if err != nil {
+		log.Printf("加载登录记录失败: %v", err)
+		c.String(http.StatusInternalServerError, "加载登录记录失败")
+		return
+	}
+
+	render(c, http.StatusOK, "login_attempts.html", gin.H{
+		"attempts":   attempts,
+		"Pagination": utils.GeneratePagination(page, int(math.Ceil(float64(total)/float64(loginAttemptPageSize)))),
+	})
+}
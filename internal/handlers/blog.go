@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"glog/internal/constants"
+	"glog/internal/models"
 	"glog/internal/services"
 	"glog/internal/utils"
+	"log"
 	"math"
 	"net/http"
 	"strconv"
@@ -14,11 +17,13 @@ import (
 )
 
 type BlogHandler struct {
-	postService *services.PostService
+	postService       *services.PostService
+	embeddingService  *services.EmbeddingService
+	webmentionService *services.WebmentionService
 }
 
-func NewBlogHandler(postService *services.PostService) *BlogHandler {
-	return &BlogHandler{postService: postService}
+func NewBlogHandler(postService *services.PostService, embeddingService *services.EmbeddingService, webmentionService *services.WebmentionService) *BlogHandler {
+	return &BlogHandler{postService: postService, embeddingService: embeddingService, webmentionService: webmentionService}
 }
 
 func (h *BlogHandler) Index(c *gin.Context) {
@@ -93,6 +98,7 @@ func (h *BlogHandler) Index(c *gin.Context) {
 		"Pagination": pagination,
 		"View":       view, // 将视图名称传递给模板
 		"is_index":   true, // 标记这是首页
+		"meta":       indexMeta(c),
 	})
 }
 
@@ -107,8 +113,33 @@ func (h *BlogHandler) ShowPost(c *gin.Context) {
 		return
 	}
 
+	// 相关文章只读取已生成的向量，未配置或生成失败时静默忽略
+	related, err := h.embeddingService.RelatedPosts(post.ID, 5, isLoggedIn.(bool))
+	if err != nil && !errors.Is(err, services.ErrEmbeddingDisabled) {
+		log.Printf("获取相关文章失败 for post ID %d: %v", post.ID, err)
+	}
+
+	mentions, err := h.webmentionService.ApprovedForPost(post.ID)
+	if err != nil {
+		log.Printf("获取文章 ID %d 的 Webmention 失败: %v", post.ID, err)
+	}
+	// 点赞和转发只显示头像，回复和提及显示内容
+	var reactions, replies []models.Webmention
+	for _, mention := range mentions {
+		if mention.Type == "like" || mention.Type == "repost" {
+			reactions = append(reactions, mention)
+		} else {
+			replies = append(replies, mention)
+		}
+	}
+
 	render(c, http.StatusOK, "post.html", gin.H{
-		"post": post,
+		"post":              post,
+		"related":           related,
+		"meta":              postMeta(c, post),
+		"reactions":         reactions,
+		"replies":           replies,
+		"WebmentionEnabled": h.webmentionService.Enabled(),
 	})
 }
 
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"glog/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeedHandler struct {
+	feedService *services.FeedService
+}
+
+func NewFeedHandler(feedService *services.FeedService) *FeedHandler {
+	return &FeedHandler{feedService: feedService}
+}
+
+func (h *FeedHandler) RSS(c *gin.Context) {
+	h.serve(c, "application/rss+xml; charset=utf-8", h.feedService.BuildRSS)
+}
+
+func (h *FeedHandler) Atom(c *gin.Context) {
+	h.serve(c, "application/atom+xml; charset=utf-8", h.feedService.BuildAtom)
+}
+
+func (h *FeedHandler) JSONFeed(c *gin.Context) {
+	h.serve(c, "application/feed+json; charset=utf-8", h.feedService.BuildJSONFeed)
+}
+
+// serve builds a feed for the current site URL and writes it.
+func (h *FeedHandler) serve(c *gin.Context, contentType string, build func(baseURL string) ([]byte, time.Time, error)) {
+	body, lastModified, err := build(siteURL(c))
+	if err != nil {
+		log.Printf("生成订阅源失败: %v", err)
+		c.String(http.StatusInternalServerError, "生成订阅源失败")
+		return
+	}
+
+	// WebSub 订阅者优先从响应头中发现 hub
+	if hubs := h.feedService.Hubs(); len(hubs) > 0 {
+		c.Header("Link", "<"+siteURL(c)+c.Request.URL.Path+`>; rel="self"`)
+		for _, hub := range hubs {
+			c.Writer.Header().Add("Link", "<"+hub+`>; rel="hub"`)
+		}
+	}
+
+	writeCacheable(c, contentType, body, lastModified)
+}
+
+// writeCacheable writes a generated document with ETag/Last-Modified
+// validators and answers conditional requests with 304 Not Modified.
+func writeCacheable(c *gin.Context, contentType string, body []byte, lastModified time.Time) {
+	// 内容相同则 ETag 相同，设置或文章变化都会反映到 ETag 上
+	hash := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=300")
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(c, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// notModified evaluates If-None-Match first and only falls back to
+// If-Modified-Since when no entity tag was sent, as RFC 9110 requires.
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if since := c.GetHeader("If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(since); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			return true
+		}
+	}
+	return false
+}
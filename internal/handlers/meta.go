@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"html/template"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PageMeta holds the Open Graph / Twitter Card values and the JSON-LD block
+// that base.html renders into <head>.
+type PageMeta struct {
+	Type          string // og:type，website 或 article
+	Title         string
+	Description   string
+	URL           string
+	Image         string
+	SiteName      string
+	Author        string
+	PublishedTime string
+	ModifiedTime  string
+	JSONLD        template.JS
+}
+
+func siteSettings(c *gin.Context) map[string]string {
+	if settings, exists := c.Get(constants.ContextKeySettings); exists {
+		return settings.(map[string]string)
+	}
+	return map[string]string{}
+}
+
+func siteName(settings map[string]string) string {
+	if title := settings[constants.SettingSiteTitle]; title != "" {
+		return title
+	}
+	return "Glog"
+}
+
+// marshalJSONLD encodes structured data for a <script type="application/ld+json">
+// block. json.Marshal escapes <, > and &, so the output cannot close the script.
+func marshalJSONLD(v interface{}) template.JS {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("生成结构化数据失败: %v", err)
+		return ""
+	}
+	return template.JS(data)
+}
+
+// postMeta builds the social preview metadata and BlogPosting JSON-LD for a post.
+func postMeta(c *gin.Context, post *models.RenderedPost) *PageMeta {
+	settings := siteSettings(c)
+	name := siteName(settings)
+	author := settings[constants.SettingSiteAuthor]
+	if author == "" {
+		author = name
+	}
+	description := strings.TrimSpace(post.Excerpt)
+	if description == "" {
+		description = settings[constants.SettingSiteDescription]
+	}
+
+	meta := &PageMeta{
+		Type:          "article",
+		Title:         post.Title,
+		Description:   description,
+		URL:           absoluteURL(c, "/post/"+post.Slug),
+		Image:         absoluteURL(c, post.Cover),
+		SiteName:      name,
+		Author:        author,
+		PublishedTime: post.PublishedAt.Format(time.RFC3339),
+		ModifiedTime:  post.UpdatedAt.Format(time.RFC3339),
+	}
+
+	// 没有封面时使用自动生成的分享卡片
+	if meta.Image == "" {
+		meta.Image = absoluteURL(c, "/post/"+post.Slug+"/og.png")
+	}
+
+	posting := map[string]interface{}{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         meta.Title,
+		"description":      meta.Description,
+		"url":              meta.URL,
+		"mainEntityOfPage": map[string]string{"@type": "WebPage", "@id": meta.URL},
+		"datePublished":    meta.PublishedTime,
+		"dateModified":     meta.ModifiedTime,
+		"author":           map[string]string{"@type": "Person", "name": author},
+		"publisher":        map[string]string{"@type": "Organization", "name": name},
+		"inLanguage":       "zh-CN",
+	}
+	posting["image"] = meta.Image
+	meta.JSONLD = marshalJSONLD(posting)
+	return meta
+}
+
+// indexMeta builds the metadata for the home page, including WebSite JSON-LD
+// with a SearchAction so search engines can offer a sitelinks search box.
+func indexMeta(c *gin.Context) *PageMeta {
+	settings := siteSettings(c)
+	name := siteName(settings)
+	home := siteURL(c) + "/"
+
+	meta := &PageMeta{
+		Type:        "website",
+		Title:       name,
+		Description: settings[constants.SettingSiteDescription],
+		URL:         home,
+		SiteName:    name,
+	}
+	meta.JSONLD = marshalJSONLD(map[string]interface{}{
+		"@context":    "https://schema.org",
+		"@type":       "WebSite",
+		"name":        name,
+		"description": meta.Description,
+		"url":         home,
+		"inLanguage":  "zh-CN",
+		"potentialAction": map[string]interface{}{
+			"@type":       "SearchAction",
+			"target":      siteURL(c) + "/search?q={search_term_string}",
+			"query-input": "required name=search_term_string",
+		},
+	})
+	return meta
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/services"
+	"glog/internal/utils"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// xmlrpcMaxBodyBytes leaves room for a base64-encoded media upload.
+const xmlrpcMaxBodyBytes = services.MediaMaxBytes*4/3 + 1<<20
+
+// XML-RPC fault codes, following the conventions of WordPress.
+const (
+	faultBadRequest = 400
+	faultAuth       = 403
+	faultNotFound   = 404
+	faultInternal   = 500
+)
+
+type xmlrpcFault struct {
+	code    int
+	message string
+}
+
+func (f *xmlrpcFault) Error() string { return f.message }
+
+type MetaWeblogHandler struct {
+	metaWeblogService *services.MetaWeblogService
+	settingService    *services.SettingService
+	loginGuard        *services.LoginGuard
+	auditService      *services.AuditService
+}
+
+func NewMetaWeblogHandler(metaWeblogService *services.MetaWeblogService, settingService *services.SettingService, loginGuard *services.LoginGuard, auditService *services.AuditService) *MetaWeblogHandler {
+	return &MetaWeblogHandler{metaWeblogService: metaWeblogService, settingService: settingService, loginGuard: loginGuard, auditService: auditService}
+}
+
+// XMLRPC serves the MetaWeblog and Blogger APIs at /xmlrpc.
+func (h *MetaWeblogHandler) XMLRPC(c *gin.Context) {
+	method, params, err := utils.ParseXMLRPCCall(io.LimitReader(c.Request.Body, xmlrpcMaxBodyBytes))
+	if err != nil {
+		h.writeFault(c, faultBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.dispatch(c, method, params)
+	if err != nil {
+		var fault *xmlrpcFault
+		if errors.As(err, &fault) {
+			h.writeFault(c, fault.code, fault.message)
+			return
+		}
+		log.Printf("处理 XML-RPC 方法 %s 失败: %v", method, err)
+		h.writeFault(c, faultInternal, "服务器内部错误")
+		return
+	}
+
+	body, err := utils.EncodeXMLRPCResponse(result)
+	if err != nil {
+		log.Printf("编码 XML-RPC 方法 %s 的结果失败: %v", method, err)
+		h.writeFault(c, faultInternal, "服务器内部错误")
+		return
+	}
+	c.Data(http.StatusOK, "text/xml; charset=utf-8", body)
+}
+
+func (h *MetaWeblogHandler) writeFault(c *gin.Context, code int, message string) {
+	c.Data(http.StatusOK, "text/xml; charset=utf-8", utils.EncodeXMLRPCFault(code, message))
+}
+
+// xmlrpcParams gives typed access to positional parameters.
+type xmlrpcParams []interface{}
+
+func (p xmlrpcParams) string(i int) string {
+	if i >= len(p) {
+		return ""
+	}
+	switch v := p[i].(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	}
+	return ""
+}
+
+func (p xmlrpcParams) bool(i int, fallback bool) bool {
+	if i >= len(p) {
+		return fallback
+	}
+	if v, ok := p[i].(bool); ok {
+		return v
+	}
+	return fallback
+}
+
+func (p xmlrpcParams) int(i int, fallback int) int {
+	if i >= len(p) {
+		return fallback
+	}
+	switch v := p[i].(type) {
+	case int:
+		return v
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func (p xmlrpcParams) structure(i int) (map[string]interface{}, error) {
+	if i < len(p) {
+		if v, ok := p[i].(map[string]interface{}); ok {
+			return v, nil
+		}
+	}
+	return nil, &xmlrpcFault{faultBadRequest, fmt.Sprintf("第 %d 个参数应为 struct", i+1)}
+}
+
+// checkAuth verifies the password parameter at index i. Failures count
+// towards the same limits as the login page.
+func (h *MetaWeblogHandler) checkAuth(c *gin.Context, params xmlrpcParams, i int) error {
+	ok, err := h.loginGuard.Attempt(c.ClientIP(), c.Request.UserAgent(), services.LoginSourceMetaWeblog, func() bool {
+		return h.metaWeblogService.Authenticate(params.string(i)) == nil
+	})
+	if err != nil {
+		return &xmlrpcFault{faultAuth, err.Error()}
+	}
+	if !ok {
+		return &xmlrpcFault{faultAuth, services.ErrMetaWeblogAuth.Error()}
+	}
+	return nil
+}
+
+func (h *MetaWeblogHandler) dispatch(c *gin.Context, method string, raw []interface{}) (interface{}, error) {
+	params := xmlrpcParams(raw)
+	baseURL := siteURL(c)
+	// 只有通过 checkAuth 的请求才会写入审计日志
+	c.Set(constants.ContextKeyAuditActor, "MetaWeblog 客户端")
+
+	switch method {
+	case "blogger.getUsersBlogs", "metaWeblog.getUsersBlogs":
+		if err := h.checkAuth(c, params, 2); err != nil {
+			return nil, err
+		}
+		title, _ := h.settingService.GetSetting(constants.SettingSiteTitle)
+		if title == "" {
+			title = "Glog"
+		}
+		return []interface{}{map[string]interface{}{
+			"blogid":   "1",
+			"blogName": title,
+			"url":      baseURL + "/",
+			"xmlrpc":   baseURL + "/xmlrpc",
+			"isAdmin":  true,
+		}}, nil
+
+	case "blogger.getUserInfo":
+		if err := h.checkAuth(c, params, 2); err != nil {
+			return nil, err
+		}
+		author, _ := h.settingService.GetSetting(constants.SettingSiteAuthor)
+		return map[string]interface{}{
+			"userid":    "1",
+			"nickname":  author,
+			"firstname": author,
+			"lastname":  "",
+			"email":     "",
+			"url":       baseURL + "/",
+		}, nil
+
+	case "metaWeblog.getCategories":
+		if err := h.checkAuth(c, params, 2); err != nil {
+			return nil, err
+		}
+		return []interface{}{}, nil
+
+	case "metaWeblog.newPost":
+		if err := h.checkAuth(c, params, 2); err != nil {
+			return nil, err
+		}
+		fields, err := params.structure(3)
+		if err != nil {
+			return nil, err
+		}
+		input := parseMetaWeblogPost(fields)
+		id, err := h.metaWeblogService.NewPost(input, params.bool(4, true))
+		if err != nil {
+			return nil, serviceFault(err)
+		}
+		recordAudit(c, h.auditService, services.AuditPostCreate, fmt.Sprintf("文章 #%d《%s》", id, input.Title), nil)
+		return strconv.FormatUint(uint64(id), 10), nil
+
+	case "metaWeblog.editPost":
+		if err := h.checkAuth(c, params, 2); err != nil {
+			return nil, err
+		}
+		id, err := services.ParsePostID(params.string(0))
+		if err != nil {
+			return nil, &xmlrpcFault{faultNotFound, err.Error()}
+		}
+		fields, err := params.structure(3)
+		if err != nil {
+			return nil, err
+		}
+		input := parseMetaWeblogPost(fields)
+		if err := h.metaWeblogService.EditPost(id, input, params.bool(4, true)); err != nil {
+			return nil, serviceFault(err)
+		}
+		recordAudit(c, h.auditService, services.AuditPostUpdate, fmt.Sprintf("文章 #%d《%s》", id, input.Title), nil)
+		return true, nil
+
+	case "metaWeblog.getPost":
+		if err := h.checkAuth(c, params, 2); err != nil {
+			return nil, err
+		}
+		id, err := services.ParsePostID(params.string(0))
+		if err != nil {
+			return nil, &xmlrpcFault{faultNotFound, err.Error()}
+		}
+		post, err := h.metaWeblogService.GetPost(id)
+		if err != nil {
+			return nil, serviceFault(err)
+		}
+		return metaWeblogPostStruct(post, baseURL), nil
+
+	case "metaWeblog.getRecentPosts":
+		if err := h.checkAuth(c, params, 2); err != nil {
+			return nil, err
+		}
+		posts, err := h.metaWeblogService.RecentPosts(params.int(3, 10))
+		if err != nil {
+			return nil, err
+		}
+		result := make([]interface{}, 0, len(posts))
+		for i := range posts {
+			result = append(result, metaWeblogPostStruct(&posts[i], baseURL))
+		}
+		return result, nil
+
+	case "metaWeblog.newMediaObject", "wp.uploadFile":
+		if err := h.checkAuth(c, params, 2); err != nil {
+			return nil, err
+		}
+		fields, err := params.structure(3)
+		if err != nil {
+			return nil, err
+		}
+		bits, _ := fields["bits"].([]byte)
+		path, err := h.metaWeblogService.NewMediaObject(bits)
+		if err != nil {
+			return nil, serviceFault(err)
+		}
+		name, _ := fields["name"].(string)
+		return map[string]interface{}{
+			"url":  baseURL + path,
+			"file": name,
+		}, nil
+
+	case "blogger.deletePost":
+		if err := h.checkAuth(c, params, 3); err != nil {
+			return nil, err
+		}
+		id, err := services.ParsePostID(params.string(1))
+		if err != nil {
+			return nil, &xmlrpcFault{faultNotFound, err.Error()}
+		}
+		if err := h.metaWeblogService.DeletePost(id); err != nil {
+			return nil, serviceFault(err)
+		}
+		recordAudit(c, h.auditService, services.AuditPostDelete, fmt.Sprintf("文章 #%d", id), nil)
+		return true, nil
+	}
+
+	return nil, &xmlrpcFault{faultBadRequest, "不支持的方法: " + method}
+}
+
+func serviceFault(err error) error {
+	switch {
+	case errors.Is(err, services.ErrMetaWeblogNotFound):
+		return &xmlrpcFault{faultNotFound, err.Error()}
+	case errors.Is(err, services.ErrMetaWeblogEmpty), errors.Is(err, services.ErrMediaType), errors.Is(err, services.ErrMediaTooLarge):
+		return &xmlrpcFault{faultBadRequest, err.Error()}
+	}
+	return err
+}
+
+// parseMetaWeblogPost reads the post struct sent by an editor. The extended
+// entry (mt_text_more) is joined with Glog's excerpt separator.
+func parseMetaWeblogPost(fields map[string]interface{}) services.MetaWeblogPost {
+	str := func(key string) string {
+		v, _ := fields[key].(string)
+		return v
+	}
+	post := services.MetaWeblogPost{
+		Title:   str("title"),
+		Content: str("description"),
+		Status:  str("post_status"),
+	}
+	if more := strings.TrimSpace(str("mt_text_more")); more != "" {
+		post.Content += "\n\n<!--more-->\n\n" + more
+	}
+	for _, key := range []string{"dateCreated", "date_created_gmt"} {
+		if t, ok := fields[key].(time.Time); ok && !t.IsZero() {
+			post.PublishedAt = t
+			break
+		}
+	}
+	return post
+}
+
+func metaWeblogPostStruct(post *models.Post, baseURL string) map[string]interface{} {
+	link := baseURL + "/post/" + post.Slug
+	status := "publish"
+	if post.IsPrivate {
+		status = "private"
+	}
+	return map[string]interface{}{
+		"postid":      strconv.FormatUint(uint64(post.ID), 10),
+		"title":       post.Title,
+		"description": post.Content,
+		"dateCreated": post.PublishedAt,
+		"link":        link,
+		"permaLink":   link,
+		"post_status": status,
+		"wp_slug":     post.Slug,
+		"categories":  []interface{}{},
+		"mt_keywords": "",
+	}
+}
+
+// RSD serves the Really Simple Discovery document that lets editors find
+// the XML-RPC endpoint from the blog's address.
+func (h *MetaWeblogHandler) RSD(c *gin.Context) {
+	if !h.metaWeblogService.HasCredential() {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	base := html.EscapeString(siteURL(c))
+	c.Data(http.StatusOK, "application/rsd+xml; charset=utf-8", []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<rsd version="1.0" xmlns="http://archipelago.phrasewise.com/rsd">
+  <service>
+    <engineName>Glog</engineName>
+    <homePageLink>`+base+`/</homePageLink>
+    <apis>
+      <api name="MetaWeblog" blogID="1" preferred="true" apiLink="`+base+`/xmlrpc"/>
+      <api name="Blogger" blogID="1" preferred="false" apiLink="`+base+`/xmlrpc"/>
+    </apis>
+  </service>
+</rsd>
+`))
+}
+
+// GenerateCredential creates a new MetaWeblog password and returns it once.
+func (h *MetaWeblogHandler) GenerateCredential(c *gin.Context) {
+	password, err := h.metaWeblogService.GenerateCredential()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditMetaWeblogEnable, "MetaWeblog 密码", nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "已生成新密码，旧密码立即失效", "password": password})
+}
+
+func (h *MetaWeblogHandler) RevokeCredential(c *gin.Context) {
+	if err := h.metaWeblogService.RevokeCredential(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "停用失败"})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditMetaWeblogRevoke, "MetaWeblog 密码", nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "已停用 MetaWeblog 接口"})
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/services"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const micropubMaxJSONBytes = 1 << 20
+
+type MicropubHandler struct {
+	micropubService *services.MicropubService
+	tokenService    *services.TokenService
+	auditService    *services.AuditService
+	mediaService    *services.MediaService
+}
+
+func NewMicropubHandler(micropubService *services.MicropubService, tokenService *services.TokenService, mediaService *services.MediaService, auditService *services.AuditService) *MicropubHandler {
+	return &MicropubHandler{micropubService: micropubService, tokenService: tokenService, mediaService: mediaService, auditService: auditService}
+}
+
+// micropubError writes an error response in the format of the Micropub spec.
+func micropubError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// authenticate reads the bearer token from the Authorization header or, for
+// form-encoded requests, the access_token parameter, and checks its scope.
+func (h *MicropubHandler) authenticate(c *gin.Context, scope string) (*models.AccessToken, bool) {
+	plaintext := ""
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		plaintext = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	} else if c.ContentType() != "application/json" {
+		plaintext = c.PostForm("access_token")
+	}
+	if plaintext == "" {
+		micropubError(c, http.StatusUnauthorized, "unauthorized", "缺少访问令牌")
+		return nil, false
+	}
+
+	token, err := h.tokenService.Authenticate(plaintext)
+	if err != nil {
+		micropubError(c, http.StatusUnauthorized, "unauthorized", err.Error())
+		return nil, false
+	}
+	if scope != "" && !token.HasScope(scope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "error_description": "令牌没有 " + scope + " 权限", "scope": scope})
+		return nil, false
+	}
+	c.Set(constants.ContextKeyAPIToken, token)
+	return token, true
+}
+
+func (h *MicropubHandler) writeServiceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrMicropubNotFound):
+		micropubError(c, http.StatusBadRequest, "invalid_request", err.Error())
+	case errors.Is(err, services.ErrMicropubInvalid):
+		micropubError(c, http.StatusBadRequest, "invalid_request", strings.TrimPrefix(err.Error(), "invalid_request: "))
+	default:
+		log.Printf("处理 Micropub 请求失败: %v", err)
+		micropubError(c, http.StatusInternalServerError, "server_error", "服务器内部错误")
+	}
+}
+
+// Query handles GET /micropub: q=config, q=source and q=syndicate-to. Any
+// token may read the configuration; q=source needs update or posts:read.
+func (h *MicropubHandler) Query(c *gin.Context) {
+	token, ok := h.authenticate(c, "")
+	if !ok {
+		return
+	}
+
+	switch c.Query("q") {
+	case "config":
+		c.JSON(http.StatusOK, gin.H{
+			"media-endpoint": absoluteURL(c, "/micropub/media"),
+			"syndicate-to":   []string{},
+			"post-types": []gin.H{
+				{"type": "article", "name": "文章"},
+				{"type": "note", "name": "短文"},
+				{"type": "photo", "name": "图片"},
+			},
+		})
+	case "syndicate-to":
+		c.JSON(http.StatusOK, gin.H{"syndicate-to": []string{}})
+	case "source":
+		// 来源包含草稿和私密文章的原文，需要能修改或读取文章的令牌
+		if !token.HasScope(services.ScopeUpdate) && !token.HasScope(services.ScopePostsRead) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "error_description": "令牌没有 " + services.ScopeUpdate + " 权限", "scope": services.ScopeUpdate})
+			return
+		}
+		properties := c.QueryArray("properties[]")
+		if len(properties) == 0 {
+			properties = c.QueryArray("properties")
+		}
+		source, err := h.micropubService.Source(c.Query("url"), properties)
+		if err != nil {
+			h.writeServiceError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, source)
+	default:
+		micropubError(c, http.StatusBadRequest, "invalid_request", "不支持的查询")
+	}
+}
+
+// micropubJSONRequest is the body of a JSON create, update or delete request.
+type micropubJSONRequest struct {
+	Type       []string                    `json:"type"`
+	Properties services.MicropubProperties `json:"properties"`
+	Action     string                      `json:"action"`
+	URL        string                      `json:"url"`
+	Replace    services.MicropubProperties `json:"replace"`
+	Add        services.MicropubProperties `json:"add"`
+	Delete     interface{}                 `json:"delete"`
+}
+
+// Create handles POST /micropub in both form-encoded and JSON syntax.
+func (h *MicropubHandler) Create(c *gin.Context) {
+	var req micropubJSONRequest
+	if c.ContentType() == "application/json" {
+		if err := json.NewDecoder(io.LimitReader(c.Request.Body, micropubMaxJSONBytes)).Decode(&req); err != nil {
+			micropubError(c, http.StatusBadRequest, "invalid_request", "无效的 JSON")
+			return
+		}
+		if len(req.Type) > 0 {
+			req.Type[0] = strings.TrimPrefix(req.Type[0], "h-")
+		}
+	} else {
+		if c.ContentType() == "multipart/form-data" {
+			if err := c.Request.ParseMultipartForm(services.MediaMaxBytes); err != nil {
+				micropubError(c, http.StatusBadRequest, "invalid_request", "无效的表单")
+				return
+			}
+		}
+		req.Action = c.PostForm("action")
+		req.URL = c.PostForm("url")
+		req.Type = []string{c.DefaultPostForm("h", "entry")}
+		req.Properties = formProperties(c)
+	}
+
+	switch req.Action {
+	case "":
+		h.create(c, &req)
+	case "update":
+		if _, ok := h.authenticate(c, services.ScopeUpdate); !ok {
+			return
+		}
+		post, err := h.micropubService.Update(req.URL, req.Replace, req.Add, req.Delete)
+		if err != nil {
+			h.writeServiceError(c, err)
+			return
+		}
+		recordAudit(c, h.auditService, services.AuditPostUpdate, services.PostAuditTarget(post), nil)
+		// 标题变化时文章地址也会改变
+		newURL := absoluteURL(c, "/post/"+post.Slug)
+		if newURL != req.URL {
+			c.Header("Location", newURL)
+			c.Status(http.StatusCreated)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	case "delete":
+		if _, ok := h.authenticate(c, services.ScopeDelete); !ok {
+			return
+		}
+		if err := h.micropubService.Delete(req.URL); err != nil {
+			h.writeServiceError(c, err)
+			return
+		}
+		recordAudit(c, h.auditService, services.AuditPostDelete, req.URL, nil)
+		c.Status(http.StatusNoContent)
+	default:
+		micropubError(c, http.StatusBadRequest, "invalid_request", "不支持的操作: "+req.Action)
+	}
+}
+
+func (h *MicropubHandler) create(c *gin.Context, req *micropubJSONRequest) {
+	if _, ok := h.authenticate(c, services.ScopeCreate); !ok {
+		return
+	}
+	entryType := ""
+	if len(req.Type) > 0 {
+		entryType = req.Type[0]
+	}
+	if req.Properties == nil {
+		req.Properties = services.MicropubProperties{}
+	}
+
+	// 表单中直接上传的图片先保存，再作为 photo 属性加入文章
+	if form := c.Request.MultipartForm; form != nil {
+		for _, field := range []string{"photo", "photo[]"} {
+			for _, header := range form.File[field] {
+				path, err := h.saveUpload(header.Open)
+				if err != nil {
+					micropubError(c, http.StatusBadRequest, "invalid_request", err.Error())
+					return
+				}
+				req.Properties["photo"] = append(req.Properties["photo"], absoluteURL(c, path))
+			}
+		}
+	}
+
+	post, err := h.micropubService.Create(entryType, req.Properties)
+	if err != nil {
+		h.writeServiceError(c, err)
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditPostCreate, services.PostAuditTarget(post), nil)
+	c.Header("Location", absoluteURL(c, "/post/"+post.Slug))
+	c.Status(http.StatusCreated)
+}
+
+// formProperties converts form fields to properties, dropping the reserved
+// parameters and the [] suffix of multi-valued fields.
+func formProperties(c *gin.Context) services.MicropubProperties {
+	props := services.MicropubProperties{}
+	for key, values := range c.Request.PostForm {
+		key = strings.TrimSuffix(key, "[]")
+		if key == "h" || key == "access_token" || key == "action" || key == "url" || strings.HasPrefix(key, "mp-") {
+			continue
+		}
+		for _, value := range values {
+			props[key] = append(props[key], value)
+		}
+	}
+	return props
+}
+
+func (h *MicropubHandler) saveUpload(open func() (multipart.File, error)) (string, error) {
+	file, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, services.MediaMaxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	return h.mediaService.Save(data)
+}
+
+// Media handles uploads to the media endpoint and answers with the file URL.
+func (h *MicropubHandler) Media(c *gin.Context) {
+	if _, ok := h.authenticate(c, services.ScopeMedia); !ok {
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		micropubError(c, http.StatusBadRequest, "invalid_request", "缺少 file 字段")
+		return
+	}
+	path, err := h.saveUpload(header.Open)
+	if err != nil {
+		if errors.Is(err, services.ErrMediaType) || errors.Is(err, services.ErrMediaTooLarge) {
+			micropubError(c, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		log.Printf("保存上传文件失败: %v", err)
+		micropubError(c, http.StatusInternalServerError, "server_error", "保存文件失败")
+		return
+	}
+	location := absoluteURL(c, path)
+	c.Header("Location", location)
+	c.JSON(http.StatusCreated, gin.H{"url": location})
+}
@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
 	"glog/internal/constants"
+	"glog/internal/models"
 	"glog/internal/services"
 
 	"github.com/gin-contrib/sessions"
@@ -15,23 +20,48 @@ import (
 // CacheControlMiddleware adds Cache-Control headers to static assets.
 func CacheControlMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if strings.HasPrefix(c.Request.URL.Path, "/static/") {
+		// 上传文件使用随机文件名，内容不会变化，可以与静态资源一样长期缓存
+		if strings.HasPrefix(c.Request.URL.Path, "/static/") || strings.HasPrefix(c.Request.URL.Path, "/uploads/") {
 			c.Header("Cache-Control", "public, max-age=31536000") // Cache for 1 year
 		}
 		c.Next()
 	}
 }
 
-// APIAuthMiddleware checks for a valid Bearer token.
-func APIAuthMiddleware(settingService *services.SettingService) gin.HandlerFunc {
+// ClientIPMiddleware passes the client IP to the session store, which only
+// sees the *http.Request. It must run before the sessions middleware.
+func ClientIPMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		adminPassword, err := settingService.GetSetting("password")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
-			c.Abort()
+		c.Request = c.Request.WithContext(services.WithClientIP(c.Request.Context(), c.ClientIP()))
+		c.Next()
+	}
+}
+
+// SecurityHeadersMiddleware sets the security headers configured in the
+// settings on every response and generates the nonce that lets the inline
+// scripts of this request pass the Content-Security-Policy.
+func SecurityHeadersMiddleware(securityService *services.SecurityService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			log.Printf("生成 CSP nonce 失败: %v", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
+		nonce := base64.RawURLEncoding.EncodeToString(random)
+		c.Set(constants.ContextKeyCSPNonce, nonce)
+		for key, values := range securityService.Headers(nonce, isHTTPS(c)) {
+			c.Header(key, values[0])
+		}
+		c.Next()
+	}
+}
 
+// APIAuthMiddleware checks for a valid Bearer token created in the settings.
+// The admin password is not accepted, so integrations keep working when it
+// changes and only get the scopes their token was granted.
+func APIAuthMiddleware(tokenService *services.TokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "需要 Authorization 请求头"})
@@ -46,12 +76,36 @@ func APIAuthMiddleware(settingService *services.SettingService) gin.HandlerFunc
 			return
 		}
 
-		if parts[1] != adminPassword {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的 token"})
+		token, err := tokenService.Authenticate(parts[1])
+		if err != nil {
+			message := "无效的访问令牌"
+			if errors.Is(err, services.ErrTokenExpired) {
+				message = err.Error()
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": message})
 			c.Abort()
 			return
 		}
+		c.Set(constants.ContextKeyAPIToken, token)
+		c.Next()
+	}
+}
 
+// RequireScope rejects API requests whose access token was not granted
+// scope. It runs after APIAuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get(constants.ContextKeyAPIToken)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "需要访问令牌"})
+			c.Abort()
+			return
+		}
+		if token := value.(*models.AccessToken); !token.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "令牌没有 " + scope + " 权限", "scope": scope})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -74,18 +128,85 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-// SettingsMiddleware loads settings from the database and adds them to the context.
-func SettingsMiddleware(settingService *services.SettingService) gin.HandlerFunc {
+// PasswordChangeMiddleware keeps the admin area locked until the default
+// password has been changed. It runs after AuthMiddleware.
+func PasswordChangeMiddleware(authService *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		settings, err := settingService.GetAllSettings()
-		if err != nil {
-			// Log the error but don't block the request.
-			// The application can run with default settings.
-			log.Printf("无法加载设置: %v", err)
-			c.Set("settings", make(map[string]string))
+		if !authService.ChangeRequired() {
+			c.Next()
+			return
+		}
+		if c.Request.Method == http.MethodGet {
+			c.Redirect(http.StatusFound, "/admin/password")
 		} else {
-			c.Set(constants.ContextKeySettings, settings)
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "请先修改默认密码"})
+		}
+		c.Abort()
+	}
+}
+
+// CSRFTokenMiddleware gives every logged-in session a synchronizer token for
+// CSRFMiddleware and passes it to the templates. It runs on all routes so
+// that the logout form in the navigation works on every page.
+func CSRFTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+		if authenticated, _ := session.Get(constants.SessionKeyAuthenticated).(bool); authenticated {
+			token, _ := session.Get(constants.SessionKeyCSRFToken).(string)
+			if token == "" {
+				random := make([]byte, 32)
+				if _, err := rand.Read(random); err != nil {
+					log.Printf("生成 CSRF 令牌失败: %v", err)
+					c.AbortWithStatus(http.StatusInternalServerError)
+					return
+				}
+				token = base64.RawURLEncoding.EncodeToString(random)
+				session.Set(constants.SessionKeyCSRFToken, token)
+				if err := session.Save(); err != nil {
+					log.Printf("保存 CSRF 令牌失败: %v", err)
+				}
+			}
+			c.Set(constants.ContextKeyCSRFToken, token)
 		}
+		c.Next()
+	}
+}
+
+// CSRFMiddleware rejects state-changing requests from a logged-in session
+// that do not carry the session's CSRF token, either in the X-CSRF-Token
+// header sent by the admin scripts or in the csrf_token form field.
+func CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		expected := c.GetString(constants.ContextKeyCSRFToken)
+		if expected == "" {
+			// 未登录的请求没有可被冒用的权限
+			c.Next()
+			return
+		}
+		token := c.GetHeader("X-CSRF-Token")
+		if token == "" {
+			token = c.PostForm("csrf_token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "请求校验失败，请刷新页面后重试"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// SettingsMiddleware adds the public settings to the context. Admin-only and
+// secret settings are read from the SettingService where they are needed.
+func SettingsMiddleware(settingService *services.SettingService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(constants.ContextKeySettings, settingService.PublicSettings())
+		c.Set(constants.ContextKeyMetaWeblogEnabled, settingService.ConfiguredSecrets()[constants.SettingMetaWeblogPasswordHash])
 
 		// Also, add the login status to the context for the template.
 		session := sessions.Default(c)
@@ -98,26 +219,27 @@ func SettingsMiddleware(settingService *services.SettingService) gin.HandlerFunc
 
 // render is a helper function to render templates with common data.
 func render(c *gin.Context, status int, templateName string, data gin.H) {
-	// Get settings from context
+	// Get public settings from context
 	settings, exists := c.Get(constants.ContextKeySettings)
 	if exists {
 		// Merge settings into the data map
 		for key, value := range settings.(map[string]string) {
 			if _, ok := data[key]; !ok { // Don't overwrite existing data
-				if key == constants.SettingFavicon {
-					data[key] = value
-				} else {
-					data[key] = value
-				}
+				data[key] = value
 			}
 		}
 	}
+	data["MetaWeblogEnabled"] = c.GetBool(constants.ContextKeyMetaWeblogEnabled)
 
 	// Get login status from context
 	isLoggedIn, exists := c.Get(constants.ContextKeyIsLoggedIn)
 	if exists {
 		data["IsLoggedIn"] = isLoggedIn
 	}
+	if token := c.GetString(constants.ContextKeyCSRFToken); token != "" {
+		data["CSRFToken"] = token
+	}
+	data["CSPNonce"] = c.GetString(constants.ContextKeyCSPNonce)
 
 	c.HTML(status, templateName, data)
 }
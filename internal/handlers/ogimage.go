@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"glog/internal/constants"
+	"glog/internal/services"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OGImageHandler struct {
+	postService    *services.PostService
+	ogImageService *services.OGImageService
+}
+
+func NewOGImageHandler(postService *services.PostService, ogImageService *services.OGImageService) *OGImageHandler {
+	return &OGImageHandler{postService: postService, ogImageService: ogImageService}
+}
+
+// PostImage serves the generated share card for /post/:slug/og.png.
+func (h *OGImageHandler) PostImage(c *gin.Context) {
+	isLoggedIn, _ := c.Get(constants.ContextKeyIsLoggedIn)
+	post, err := h.postService.GetPostBySlug(c.Param("slug"), isLoggedIn.(bool))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	path, err := h.ogImageService.PostImage(post)
+	if err != nil {
+		log.Printf("生成分享图片失败 for post ID %d: %v", post.ID, err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	// 文件名随文章更新而变化，ServeFile 会处理 If-Modified-Since。
+	// 私密文章和尚未发布的文章只有登录后才能看到，不能让共享缓存保存
+	if post.IsPrivate || post.PublishedAt.After(time.Now()) {
+		c.Header("Cache-Control", "private, no-store")
+	} else {
+		c.Header("Cache-Control", "public, max-age=86400")
+	}
+	c.File(path)
+}
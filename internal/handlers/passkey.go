@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/services"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type PasskeyHandler struct {
+	passkeyService   *services.PasskeyService
+	authService      *services.AuthService
+	twoFactorService *services.TwoFactorService
+	loginGuard       *services.LoginGuard
+	auditService     *services.AuditService
+}
+
+func NewPasskeyHandler(passkeyService *services.PasskeyService, authService *services.AuthService, twoFactorService *services.TwoFactorService, loginGuard *services.LoginGuard, auditService *services.AuditService) *PasskeyHandler {
+	return &PasskeyHandler{
+		passkeyService:   passkeyService,
+		authService:      authService,
+		twoFactorService: twoFactorService,
+		loginGuard:       loginGuard,
+		auditService:     auditService,
+	}
+}
+
+// BeginRegistration returns the options for navigator.credentials.create.
+// A passkey replaces both the password and the two-factor code, so adding
+// one asks for the authenticator code when 2FA is enabled, or the current
+// password otherwise.
+func (h *PasskeyHandler) BeginRegistration(c *gin.Context) {
+	source, verify := services.LoginSourcePasswordConfirm, func() bool {
+		return h.authService.CheckPassword(c.PostForm("password"))
+	}
+	message := "当前密码错误"
+	if h.twoFactorService.Enabled() {
+		source, verify = services.LoginSourceTwoFactor, func() bool {
+			return h.twoFactorService.Verify(c.PostForm("code"))
+		}
+		message = services.ErrInvalidTOTPCode.Error()
+	}
+	ok, err := h.loginGuard.Attempt(c.ClientIP(), c.Request.UserAgent(), source, verify)
+	if err != nil {
+		respondLoginBlocked(c, err)
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": message})
+		return
+	}
+
+	creation, sessionData, err := h.passkeyService.BeginRegistration()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	session := sessions.Default(c)
+	session.Set(constants.SessionKeyPasskeyRegistration, sessionData)
+	session.Save()
+	c.JSON(http.StatusOK, gin.H{"status": "success", "options": creation})
+}
+
+// FinishRegistration stores the new passkey. The request body is the
+// authenticator response; the name is passed in the query string.
+func (h *PasskeyHandler) FinishRegistration(c *gin.Context) {
+	session := sessions.Default(c)
+	sessionData, _ := session.Get(constants.SessionKeyPasskeyRegistration).(string)
+	if sessionData == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "注册已过期，请重试"})
+		return
+	}
+	session.Delete(constants.SessionKeyPasskeyRegistration)
+	session.Save()
+
+	passkey, err := h.passkeyService.FinishRegistration(sessionData, c.Query("name"), c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditPasskeyAdd, fmt.Sprintf("通行密钥 #%d %s", passkey.ID, passkey.Name), nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "通行密钥已添加"})
+}
+
+func (h *PasskeyHandler) RenamePasskey(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.passkeyService.Rename(id, c.PostForm("name")); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrPasskeyNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditPasskeyRename, fmt.Sprintf("通行密钥 #%d", id), []models.AuditChange{{Key: "name", New: c.PostForm("name")}})
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "已重命名"})
+}
+
+func (h *PasskeyHandler) DeletePasskey(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.passkeyService.Delete(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "删除失败"})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditPasskeyDelete, fmt.Sprintf("通行密钥 #%d", id), nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "通行密钥已删除"})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"glog/internal/services"
+	"glog/internal/utils"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const pingPageSize = 30
+
+type PingHandler struct {
+	pingService *services.PingService
+}
+
+func NewPingHandler(pingService *services.PingService) *PingHandler {
+	return &PingHandler{pingService: pingService}
+}
+
+// IndexNowKeyFile serves the IndexNow key file at /<key>.txt. It runs before
+// the 404 handler and passes every other path on.
+func (h *PingHandler) IndexNowKeyFile(c *gin.Context) {
+	key := h.pingService.IndexNowKey()
+	if key != "" && c.Request.Method == http.MethodGet && c.Request.URL.Path == "/"+key+".txt" {
+		c.String(http.StatusOK, key)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// ListSubmissions shows the log of hub pings and search engine submissions.
+func (h *PingHandler) ListSubmissions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	jobs, total, err := h.pingService.ListSubmissions(page, pingPageSize)
+	if err != nil {
+		log.Printf("加载推送记录失败: %v", err)
+		c.String(http.StatusInternalServerError, "加载推送记录失败")
+		return
+	}
+
+	render(c, http.StatusOK, "pings.html", gin.H{
+		"jobs":       jobs,
+		"Pagination": utils.GeneratePagination(page, int(math.Ceil(float64(total)/float64(pingPageSize)))),
+	})
+}
+
+func (h *PingHandler) RetrySubmission(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "无效的记录 ID"})
+		return
+	}
+	if err := h.pingService.RetrySubmission(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "已重新加入推送队列"})
+}
@@ -2,22 +2,32 @@ package handlers
 
 import (
 	"glog/internal/constants"
+	"glog/internal/models"
 	"glog/internal/services"
 	"glog/internal/utils"
+	"html/template"
 	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// semanticSearchRateLimit is how many semantic searches a visitor may run per
+// hour. Each uncached query is a paid call to the embedding API.
+const semanticSearchRateLimit = 30
+
 type SearchHandler struct {
-	postService *services.PostService
+	postService      *services.PostService
+	embeddingService *services.EmbeddingService
+	limiter          *utils.RateLimiter
 }
 
-func NewSearchHandler(postService *services.PostService) *SearchHandler {
-	return &SearchHandler{postService: postService}
+func NewSearchHandler(postService *services.PostService, embeddingService *services.EmbeddingService) *SearchHandler {
+	return &SearchHandler{postService: postService, embeddingService: embeddingService, limiter: utils.NewRateLimiter()}
 }
 
 func (h *SearchHandler) Search(c *gin.Context) {
@@ -54,7 +64,27 @@ func (h *SearchHandler) Search(c *gin.Context) {
 
 	isLoggedIn, _ := c.Get(constants.ContextKeyIsLoggedIn)
 
-	posts, total, err := h.postService.SearchPostsPage(query, page, pageSize, isLoggedIn.(bool))
+	// 搜索模式：keyword 为关键字匹配，semantic 为基于向量的语义搜索
+	semanticEnabled := h.embeddingService.Enabled()
+	mode := c.Query("mode")
+	if mode != "semantic" || !semanticEnabled {
+		mode = "keyword"
+	}
+	// 访客超过频率限制时改用关键字搜索，登录用户不受限制
+	semanticLimited := false
+	if mode == "semantic" && !isLoggedIn.(bool) && !h.limiter.Allow(c.ClientIP(), semanticSearchRateLimit, time.Hour) {
+		mode = "keyword"
+		semanticLimited = true
+	}
+
+	var posts []models.RenderedPost
+	var total int
+	var err error
+	if mode == "semantic" {
+		posts, total, err = h.embeddingService.SemanticSearchPage(query, page, pageSize, isLoggedIn.(bool))
+	} else {
+		posts, total, err = h.postService.SearchPostsPage(query, page, pageSize, isLoggedIn.(bool))
+	}
 	if err != nil {
 		render(c, http.StatusInternalServerError, "404.html", gin.H{
 			"error": "Search failed",
@@ -66,6 +96,11 @@ func (h *SearchHandler) Search(c *gin.Context) {
 
 	pagination := utils.GeneratePagination(page, totalPages)
 
+	filterQuery := url.Values{"q": {query}}
+	if mode == "semantic" {
+		filterQuery.Set("mode", mode)
+	}
+
 	// 根据视图选择渲染的模板
 	templateName := "search.html"
 	if view == "cards" {
@@ -73,9 +108,13 @@ func (h *SearchHandler) Search(c *gin.Context) {
 	}
 
 	render(c, http.StatusOK, templateName, gin.H{
-		"posts":      posts,
-		"query":      query,
-		"Pagination": pagination,
-		"View":       view, // 将视图名称传递给模板
+		"posts":           posts,
+		"query":           query,
+		"Pagination":      pagination,
+		"FilterQuery":     template.URL(filterQuery.Encode()),
+		"View":            view, // 将视图名称传递给模板
+		"SearchMode":      mode,
+		"SemanticOn":      semanticEnabled,
+		"SemanticLimited": semanticLimited,
 	})
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"glog/internal/services"
+	"glog/internal/utils"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	cspReportPageSize = 50
+	// 浏览器发送的单个报告通常只有几 KB
+	cspReportMaxBody = 64 << 10
+)
+
+type SecurityHandler struct {
+	securityService *services.SecurityService
+}
+
+func NewSecurityHandler(securityService *services.SecurityService) *SecurityHandler {
+	return &SecurityHandler{securityService: securityService}
+}
+
+// ReceiveReport collects CSP violation reports sent by browsers.
+func (h *SecurityHandler) ReceiveReport(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, cspReportMaxBody))
+	if err != nil {
+		c.Status(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := h.securityService.RecordReports(body); err != nil {
+		log.Printf("记录 CSP 报告失败: %v", err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListReports shows the collected CSP violation reports.
+func (h *SecurityHandler) ListReports(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	reports, total, err := h.securityService.ListReports(page, cspReportPageSize)
+	if err != nil {
+		log.Printf("加载 CSP 报告失败: %v", err)
+		c.String(http.StatusInternalServerError, "加载 CSP 报告失败")
+		return
+	}
+
+	render(c, http.StatusOK, "csp_reports.html", gin.H{
+		"reports":    reports,
+		"Pagination": utils.GeneratePagination(page, int(math.Ceil(float64(total)/float64(cspReportPageSize)))),
+	})
+}
+
+func (h *SecurityHandler) ClearReports(c *gin.Context) {
+	if err := h.securityService.ClearReports(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "清空 CSP 报告失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "CSP 报告已清空"})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"glog/internal/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+	auditService   *services.AuditService
+}
+
+func NewSessionHandler(sessionService *services.SessionService, auditService *services.AuditService) *SessionHandler {
+	return &SessionHandler{sessionService: sessionService, auditService: auditService}
+}
+
+// ListSessions shows the active login sessions.
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	list, err := h.sessionService.List()
+	if err != nil {
+		log.Printf("加载会话列表失败: %v", err)
+		c.String(http.StatusInternalServerError, "加载会话列表失败")
+		return
+	}
+
+	render(c, http.StatusOK, "sessions.html", gin.H{
+		"sessions":  list,
+		"CurrentID": services.SessionID(sessions.Default(c).ID()),
+	})
+}
+
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	if err := h.sessionService.Revoke(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "撤销会话失败"})
+		return
+	}
+	target := c.Param("id")
+	if len(target) > 8 {
+		target = target[:8]
+	}
+	recordAudit(c, h.auditService, services.AuditSessionRevoke, "会话 "+target, nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "会话已撤销"})
+}
+
+// RevokeAll logs out every device, including this one.
+func (h *SessionHandler) RevokeAll(c *gin.Context) {
+	if err := h.sessionService.RevokeAll(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "退出失败"})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditSessionRevokeAll, "所有会话", nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "已在所有设备上退出登录", "redirect": "/login"})
+}
+
+func (h *SessionHandler) RotateSecret(c *gin.Context) {
+	if err := h.sessionService.RotateSecret(); err != nil {
+		log.Printf("轮换会话密钥失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "轮换密钥失败"})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditSessionRotate, "会话签名密钥", nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "会话签名密钥已轮换"})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"glog/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SitemapHandler struct {
+	sitemapService *services.SitemapService
+}
+
+func NewSitemapHandler(sitemapService *services.SitemapService) *SitemapHandler {
+	return &SitemapHandler{sitemapService: sitemapService}
+}
+
+const sitemapContentType = "application/xml; charset=utf-8"
+
+// Sitemap serves a single sitemap while the site fits into one file and a
+// sitemap index once it grows past 50,000 URLs.
+func (h *SitemapHandler) Sitemap(c *gin.Context) {
+	parts, err := h.sitemapService.Parts()
+	if err != nil {
+		log.Printf("生成站点地图失败: %v", err)
+		c.String(http.StatusInternalServerError, "生成站点地图失败")
+		return
+	}
+
+	var body []byte
+	if parts <= 1 {
+		body, err = h.sitemapService.BuildPart(siteURL(c), 1)
+	} else {
+		body, err = h.sitemapService.BuildIndex(siteURL(c), parts)
+	}
+	if err != nil {
+		log.Printf("生成站点地图失败: %v", err)
+		c.String(http.StatusInternalServerError, "生成站点地图失败")
+		return
+	}
+	writeCacheable(c, sitemapContentType, body, time.Time{})
+}
+
+// SitemapPart serves one file of a split sitemap, e.g. /sitemap/2.xml.
+func (h *SitemapHandler) SitemapPart(c *gin.Context) {
+	part, err := strconv.Atoi(strings.TrimSuffix(c.Param("part"), ".xml"))
+	if err != nil || part < 1 {
+		c.String(http.StatusNotFound, "站点地图不存在")
+		return
+	}
+	parts, err := h.sitemapService.Parts()
+	if err != nil {
+		log.Printf("生成站点地图失败: %v", err)
+		c.String(http.StatusInternalServerError, "生成站点地图失败")
+		return
+	}
+	if part > parts {
+		c.String(http.StatusNotFound, "站点地图不存在")
+		return
+	}
+
+	body, err := h.sitemapService.BuildPart(siteURL(c), part)
+	if err != nil {
+		log.Printf("生成站点地图失败: %v", err)
+		c.String(http.StatusInternalServerError, "生成站点地图失败")
+		return
+	}
+	writeCacheable(c, sitemapContentType, body, time.Time{})
+}
+
+func (h *SitemapHandler) Robots(c *gin.Context) {
+	c.String(http.StatusOK, h.sitemapService.BuildRobots(siteURL(c)))
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"glog/internal/models"
+	"glog/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenHandler struct {
+	tokenService *services.TokenService
+	auditService *services.AuditService
+}
+
+func NewTokenHandler(tokenService *services.TokenService, auditService *services.AuditService) *TokenHandler {
+	return &TokenHandler{tokenService: tokenService, auditService: auditService}
+}
+
+// CreateToken issues an access token and returns it once. expires_in is the
+// number of days the token stays valid; empty means it never expires.
+func (h *TokenHandler) CreateToken(c *gin.Context) {
+	validDays := 0
+	if raw := strings.TrimSpace(c.PostForm("expires_in")); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "无效的有效期"})
+			return
+		}
+		validDays = days
+	}
+	plaintext, token, err := h.tokenService.Create(c.PostForm("name"), c.PostFormArray("scope"), validDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	changes := []models.AuditChange{{Key: "scopes", New: token.Scopes}}
+	if token.ExpiresAt != nil {
+		changes = append(changes, models.AuditChange{Key: "expires_at", New: token.ExpiresAt.Format("2006-01-02 15:04")})
+	}
+	recordAudit(c, h.auditService, services.AuditTokenCreate, fmt.Sprintf("令牌 #%d %s", token.ID, token.Name), changes)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "令牌已创建", "token": plaintext})
+}
+
+func (h *TokenHandler) RevokeToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "无效的令牌 ID"})
+		return
+	}
+	if err := h.tokenService.Revoke(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "撤销失败"})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditTokenRevoke, fmt.Sprintf("令牌 #%d", id), nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "令牌已撤销"})
+}
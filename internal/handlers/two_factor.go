@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"glog/internal/constants"
+	"glog/internal/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+	loginGuard       *services.LoginGuard
+	auditService     *services.AuditService
+}
+
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService, loginGuard *services.LoginGuard, auditService *services.AuditService) *TwoFactorHandler {
+	return &TwoFactorHandler{twoFactorService: twoFactorService, loginGuard: loginGuard, auditService: auditService}
+}
+
+// verifyCode checks the code in the request through the login guard, so a
+// hijacked session cannot guess codes without limit. It writes the error
+// response when the code is rejected.
+func (h *TwoFactorHandler) verifyCode(c *gin.Context) bool {
+	code := c.PostForm("code")
+	ok, err := h.loginGuard.Attempt(c.ClientIP(), c.Request.UserAgent(), services.LoginSourceTwoFactor, func() bool {
+		return h.twoFactorService.Verify(code)
+	})
+	if err != nil {
+		respondLoginBlocked(c, err)
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": services.ErrInvalidTOTPCode.Error()})
+		return false
+	}
+	return true
+}
+
+// Setup generates a new secret and keeps it in the session until the admin
+// confirms it with a code from the authenticator app.
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	if h.twoFactorService.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "两步验证已启用"})
+		return
+	}
+	enrollment, err := h.twoFactorService.NewEnrollment()
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "生成密钥失败"})
+		return
+	}
+	session := sessions.Default(c)
+	session.Set(constants.SessionKeyTOTPEnrollment, enrollment.Secret)
+	session.Save()
+	c.JSON(http.StatusOK, gin.H{"status": "success", "enrollment": enrollment})
+}
+
+// Enable confirms the enrollment and returns the recovery codes once.
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	session := sessions.Default(c)
+	secret, _ := session.Get(constants.SessionKeyTOTPEnrollment).(string)
+	if secret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "请先生成密钥"})
+		return
+	}
+	codes, err := h.twoFactorService.Enable(secret, c.PostForm("code"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	session.Delete(constants.SessionKeyTOTPEnrollment)
+	session.Save()
+	recordAudit(c, h.auditService, services.AuditTwoFactorEnable, "两步验证", nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "两步验证已启用", "recovery_codes": codes})
+}
+
+// Disable turns two-factor authentication off after checking a current code.
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	if !h.verifyCode(c) {
+		return
+	}
+	if err := h.twoFactorService.Disable(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "关闭两步验证失败"})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditTwoFactorDisable, "两步验证", nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "两步验证已关闭"})
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	if !h.verifyCode(c) {
+		return
+	}
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditRecoveryCodes, "两步验证", nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "已生成新的恢复码", "recovery_codes": codes})
+}
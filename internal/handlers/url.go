@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"glog/internal/constants"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// siteURL returns the configured site base URL without a trailing slash. When
+// it is not configured, the URL is derived from the current request.
+func siteURL(c *gin.Context) string {
+	if settings, exists := c.Get(constants.ContextKeySettings); exists {
+		if base := strings.TrimRight(settings.(map[string]string)[constants.SettingSiteURL], "/"); base != "" {
+			return base
+		}
+	}
+
+	scheme := "http"
+	if isHTTPS(c) {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// isHTTPS reports whether the request arrived over TLS, directly or through
+// a reverse proxy that terminates it.
+func isHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+// absoluteURL resolves a root-relative path against the site base URL.
+// Values that are already absolute are returned unchanged.
+func absoluteURL(c *gin.Context, path string) string {
+	if path == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if strings.HasPrefix(path, "//") {
+		return "https:" + path
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return siteURL(c) + path
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"glog/internal/models"
+	"glog/internal/services"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	auditService   *services.AuditService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService, auditService *services.AuditService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService, auditService: auditService}
+}
+
+func parseIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "无效的 ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// CreateWebhook adds a webhook and returns its secret once.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	webhook, err := h.webhookService.Create(c.PostForm("url"), c.PostForm("secret"), c.PostFormArray("event"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	// Webhook 地址中常带有密钥，只记录主机名
+	host := ""
+	if u, err := url.Parse(webhook.URL); err == nil {
+		host = u.Host
+	}
+	recordAudit(c, h.auditService, services.AuditWebhookCreate, fmt.Sprintf("Webhook #%d %s", webhook.ID, host), []models.AuditChange{{Key: "events", New: webhook.Events}})
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Webhook 已添加", "secret": webhook.Secret})
+}
+
+func (h *WebhookHandler) SetEnabled(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	enabled := c.PostForm("enabled") == "true"
+	if err := h.webhookService.SetEnabled(id, enabled); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	message := "Webhook 已停用"
+	if enabled {
+		message = "Webhook 已启用"
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": message})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.webhookService.Delete(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "删除失败"})
+		return
+	}
+	recordAudit(c, h.auditService, services.AuditWebhookDelete, fmt.Sprintf("Webhook #%d", id), nil)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Webhook 已删除"})
+}
+
+func (h *WebhookHandler) SendTest(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.webhookService.SendTest(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "测试消息已加入发送队列，稍后刷新查看投递记录"})
+}
+
+func (h *WebhookHandler) RetryDelivery(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.webhookService.RetryDelivery(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "已重新加入发送队列"})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"glog/internal/models"
+	"glog/internal/services"
+	"glog/internal/utils"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	webmentionPageSize = 20
+	// webmentionRateLimit is how many mentions one address may send per hour.
+	// Each accepted mention makes the blog fetch the source page.
+	webmentionRateLimit = 30
+)
+
+type WebmentionHandler struct {
+	webmentionService *services.WebmentionService
+	limiter           *utils.RateLimiter
+}
+
+func NewWebmentionHandler(webmentionService *services.WebmentionService) *WebmentionHandler {
+	return &WebmentionHandler{webmentionService: webmentionService, limiter: utils.NewRateLimiter()}
+}
+
+// Receive is the public Webmention endpoint. Verification happens in the
+// background, so a valid request is answered with 202.
+func (h *WebmentionHandler) Receive(c *gin.Context) {
+	if !h.limiter.Allow(c.ClientIP(), webmentionRateLimit, time.Hour) {
+		c.String(http.StatusTooManyRequests, "请求过于频繁，请稍后再试")
+		return
+	}
+	err := h.webmentionService.Receive(c.PostForm("source"), c.PostForm("target"))
+	switch {
+	case err == nil:
+		c.String(http.StatusAccepted, "已收到，将在验证后显示")
+	case errors.Is(err, services.ErrWebmentionDisabled):
+		c.String(http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrWebmentionInvalid):
+		c.String(http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("接收 Webmention 失败: %v", err)
+		c.String(http.StatusInternalServerError, "服务器内部错误")
+	}
+}
+
+func (h *WebmentionHandler) ListMentions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	status := c.DefaultQuery("status", models.WebmentionPending)
+	switch status {
+	case models.WebmentionPending, models.WebmentionApproved, models.WebmentionRejected:
+	default:
+		status = ""
+	}
+
+	mentions, total, posts, err := h.webmentionService.ListMentions(status, page, webmentionPageSize)
+	if err != nil {
+		log.Printf("加载 Webmention 失败: %v", err)
+		c.String(http.StatusInternalServerError, "加载 Webmention 失败")
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(webmentionPageSize)))
+	filterQuery := url.Values{}
+	filterQuery.Set("status", c.DefaultQuery("status", models.WebmentionPending))
+
+	render(c, http.StatusOK, "webmentions.html", gin.H{
+		"mentions":     mentions,
+		"posts":        posts,
+		"Status":       status,
+		"PendingCount": h.webmentionService.CountPending(),
+		"Pagination":   utils.GeneratePagination(page, totalPages),
+		"FilterQuery":  template.URL(filterQuery.Encode()),
+	})
+}
+
+func (h *WebmentionHandler) UpdateStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "无效的 ID"})
+		return
+	}
+	if err := h.webmentionService.SetStatus(uint(id), c.PostForm("status")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "更新失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "已更新"})
+}
+
+func (h *WebmentionHandler) DeleteMention(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "无效的 ID"})
+		return
+	}
+	if err := h.webmentionService.DeleteMention(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "删除失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "已删除"})
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// AccessToken is a bearer token for a Micropub app or an API script. Only
+// the SHA-256 hash of the token is stored.
+type AccessToken struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	Name       string `gorm:"not null"`
+	TokenHash  string `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes     string `gorm:"not null"` // 以空格分隔的权限范围
+	LastUsedAt *time.Time
+	ExpiresAt  *time.Time // 为空表示永不过期
+}
+
+// Expired reports whether the token can no longer be used at now.
+func (t *AccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// ScopeList returns the token's scopes.
+func (t *AccessToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// HasScope reports whether the token was granted scope.
+func (t *AccessToken) HasScope(scope string) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
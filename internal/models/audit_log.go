@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLog records one sensitive action in the admin area or through the
+// APIs. Entries are never modified; old ones are only removed by retention.
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	Actor     string    `gorm:"index"` // 管理员、令牌名称等
+	IP        string
+	Action    string `gorm:"index"`
+	Target    string
+	Changes   string // JSON 编码的 []AuditChange
+}
+
+// AuditChange is one changed field. Secret values are never stored; only
+// the fact that they changed.
+type AuditChange struct {
+	Key    string `json:"key"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// ChangeList decodes Changes for the audit page.
+func (l *AuditLog) ChangeList() []AuditChange {
+	var changes []AuditChange
+	if l.Changes != "" {
+		json.Unmarshal([]byte(l.Changes), &changes)
+	}
+	return changes
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// CSPReport is a Content-Security-Policy violation reported by a browser.
+// Repeated reports of the same violation are counted in one row.
+type CSPReport struct {
+	ID          uint      `gorm:"primarykey"`
+	CreatedAt   time.Time `gorm:"index"`
+	LastSeenAt  time.Time `gorm:"index"`
+	DocumentURI string
+	Directive   string
+	BlockedURI  string
+	SourceFile  string
+	LineNumber  int
+	Sample      string
+	Disposition string // enforce 或 report
+	Count       int    `gorm:"default:1"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Delivery job states.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// DeliveryJob is an outgoing HTTP delivery (federation, pings, webhooks)
+// persisted so it survives restarts and can be retried with backoff. Finished
+// jobs are kept as the delivery log.
+type DeliveryJob struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Kind          string    `gorm:"index;not null"` // 投递类型，决定由哪个处理器发送
+	Target        string    `gorm:"not null"`       // 目标地址，如收件箱 URL
+	Payload       string    `gorm:"type:text"`
+	Ref           string    `gorm:"index"` // 关联对象，如文章 URL，便于在日志中查看
+	Status        string    `gorm:"index;not null;default:pending"`
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"index"`
+	LastError     string
+	ResponseCode  int
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// PostEmbedding stores the embedding vector of a post for semantic search.
+type PostEmbedding struct {
+	PostID      uint `gorm:"primarykey;autoIncrement:false"`
+	UpdatedAt   time.Time
+	Model       string `gorm:"not null"`
+	ContentHash string `gorm:"not null"` // 模型、标题与正文的哈希，变化时需要重新生成向量
+	Vector      []byte `gorm:"not null"` // 小端序 float32 数组
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Follower is a remote ActivityPub actor following the blog.
+type Follower struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	ActorID     string `gorm:"uniqueIndex;not null"`
+	Inbox       string `gorm:"not null"`
+	SharedInbox string
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// LoginAttempt records one authentication attempt, used both for brute-force
+// throttling and for the admin's attempt log.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	IP        string    `gorm:"index"`
+	UserAgent string
+	Source    string // login 或 api
+	Success   bool
+	Reason    string // 失败原因
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Passkey is a WebAuthn credential registered for the admin. Credential holds
+// the library's credential record as JSON; CredentialID is kept separately
+// for lookups during login.
+type Passkey struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	CredentialID []byte `gorm:"uniqueIndex"`
+	Credential   string `gorm:"type:text" json:"-"`
+	LastUsedAt   *time.Time
+}
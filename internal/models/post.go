@@ -9,14 +9,17 @@ type Post struct {
 	ID          uint `gorm:"primarykey"`
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
-	PublishedAt time.Time `gorm:"index"`
-	Title       string    `gorm:"not null" json:"title" form:"title"`
-	Slug        string    `gorm:"uniqueIndex;not null" json:"slug"`
-	Cover       string    `json:"cover" form:"cover"` // 新增封面图字段
-	Content     string    `gorm:"type:text;not null" json:"content" form:"content"`
-	ContentHTML string    `gorm:"type:text" json:"content_html"`
-	Excerpt     string    `json:"excerpt"`
-	IsPrivate   bool      `gorm:"index:idx_pub;default:false" json:"is_private" form:"is_private"`
+	PublishedAt time.Time  `gorm:"index"`
+	Title       string     `gorm:"not null" json:"title" form:"title"`
+	Slug        string     `gorm:"uniqueIndex;not null" json:"slug"`
+	Cover       string     `json:"cover" form:"cover"` // 新增封面图字段
+	Content     string     `gorm:"type:text;not null" json:"content" form:"content"`
+	ContentHTML string     `gorm:"type:text" json:"content_html"`
+	Excerpt     string     `json:"excerpt"`
+	IsPrivate   bool       `gorm:"index:idx_pub;default:false" json:"is_private" form:"is_private"`
+	NoIndex     bool       `gorm:"default:false" json:"no_index" form:"no_index"` // 不希望被搜索引擎收录
+	TrustedHTML bool       `gorm:"default:false" json:"trusted_html"`             // 原样输出文章中的 HTML，只能在后台编辑器中设置
+	AnnouncedAt *time.Time `gorm:"index" json:"-"`                                // 文章首次对外公开、已发出发布事件的时间
 }
 
 // RenderedPost is a view model for displaying a post with rendered HTML content.
@@ -32,6 +35,7 @@ type RenderedPost struct {
 	Body        template.HTML // Rendered HTML of the content after <!--more-->
 	Excerpt     string        // Plain text excerpt for lists
 	IsPrivate   bool
+	NoIndex     bool
 }
 
 // PostBackup is a simplified struct for backup and restore operations.
@@ -40,6 +44,7 @@ type PostBackup struct {
 	Cover       string    `json:"cover"` // 备份时也包含封面
 	Content     string    `json:"content"`
 	IsPrivate   bool      `json:"is_private"`
+	NoIndex     bool      `json:"no_index,omitempty"`
 	PublishedAt time.Time `json:"published_at"`
 }
 
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session is a server-side login session. The cookie only carries a signed
+// random token; ID is the SHA-256 of that token, so a copy of the database
+// cannot be used to hijack sessions.
+type Session struct {
+	ID         string `gorm:"primaryKey;size:64"`
+	Data       []byte
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time `gorm:"index"`
+	ExpiresAt  time.Time `gorm:"index"` // 绝对过期时间，不随访问延长
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook is an HTTP endpoint notified of site events. Payloads are signed
+// with Secret so the receiver can verify they come from this blog.
+type Webhook struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	URL       string `gorm:"not null"`
+	Secret    string `json:"-"`
+	Events    string // 订阅的事件，以空格分隔
+	Enabled   bool   `gorm:"not null;default:true"`
+}
+
+// EventList returns the subscribed event types.
+func (w *Webhook) EventList() []string {
+	return strings.Fields(w.Events)
+}
+
+// Subscribes reports whether the webhook wants events of the given type.
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, event := range w.EventList() {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Webmention moderation states.
+const (
+	WebmentionPending  = "pending"
+	WebmentionApproved = "approved"
+	WebmentionRejected = "rejected"
+)
+
+// Webmention is a verified mention of a post received from another site.
+// Only approved mentions are shown under the post.
+type Webmention struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	PostID      uint   `gorm:"index;not null"`
+	Source      string `gorm:"uniqueIndex:idx_webmention_source_target;not null"`
+	Target      string `gorm:"uniqueIndex:idx_webmention_source_target;not null"`
+	Type        string `gorm:"not null;default:mention"` // mention、reply、like、repost、bookmark
+	Status      string `gorm:"index;not null;default:pending"`
+	AuthorName  string
+	AuthorURL   string
+	AuthorPhoto string
+	Title       string
+	Content     string `gorm:"type:text"` // 纯文本摘录
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"glog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditLogFilter narrows the audit log page. Empty fields match everything.
+type AuditLogFilter struct {
+	Action  string
+	Actor   string
+	Keyword string
+}
+
+// AuditLogRepository only appends entries; the log is never edited.
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+func (r *AuditLogRepository) Create(entry *models.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *AuditLogRepository) FindPage(filter AuditLogFilter, page, pageSize int) ([]models.AuditLog, int64, error) {
+	query := r.db.Model(&models.AuditLog{})
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Keyword != "" {
+		like := "%" + filter.Keyword + "%"
+		query = query.Where("target LIKE ? OR changes LIKE ? OR ip LIKE ?", like, like, like)
+	}
+
+	var entries []models.AuditLog
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error
+	return entries, total, err
+}
+
+// Actors returns the distinct actors, for the filter on the audit page.
+func (r *AuditLogRepository) Actors() ([]string, error) {
+	var actors []string
+	err := r.db.Model(&models.AuditLog{}).Distinct("actor").Order("actor").Pluck("actor", &actors).Error
+	return actors, err
+}
+
+// DeleteBefore removes entries older than the retention period.
+func (r *AuditLogRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", before).Delete(&models.AuditLog{})
+	return result.RowsAffected, result.Error
+}
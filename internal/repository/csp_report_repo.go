@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"glog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CSPReportRepository struct {
+	db *gorm.DB
+}
+
+func NewCSPReportRepository(db *gorm.DB) *CSPReportRepository {
+	return &CSPReportRepository{db: db}
+}
+
+func (r *CSPReportRepository) Create(report *models.CSPReport) error {
+	return r.db.Create(report).Error
+}
+
+// IncrementSame counts report on an existing row for the same violation seen
+// since the given time. It reports whether such a row existed.
+func (r *CSPReportRepository) IncrementSame(report *models.CSPReport, since time.Time) (bool, error) {
+	result := r.db.Model(&models.CSPReport{}).
+		Where("document_uri = ? AND directive = ? AND blocked_uri = ? AND source_file = ? AND line_number = ? AND last_seen_at > ?",
+			report.DocumentURI, report.Directive, report.BlockedURI, report.SourceFile, report.LineNumber, since).
+		Updates(map[string]interface{}{"count": gorm.Expr("count + 1"), "last_seen_at": report.LastSeenAt})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *CSPReportRepository) FindPage(page, pageSize int) ([]models.CSPReport, int64, error) {
+	var reports []models.CSPReport
+	var total int64
+	if err := r.db.Model(&models.CSPReport{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.db.Order("last_seen_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&reports).Error
+	return reports, total, err
+}
+
+func (r *CSPReportRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.db.Where("last_seen_at < ?", before).Delete(&models.CSPReport{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *CSPReportRepository) DeleteAll() error {
+	return r.db.Where("1 = 1").Delete(&models.CSPReport{}).Error
+}
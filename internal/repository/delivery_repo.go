@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"glog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewDeliveryRepository(db *gorm.DB) *DeliveryRepository {
+	return &DeliveryRepository{db: db}
+}
+
+func (r *DeliveryRepository) Create(job *models.DeliveryJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *DeliveryRepository) FindByID(id uint) (*models.DeliveryJob, error) {
+	var job models.DeliveryJob
+	err := r.db.First(&job, id).Error
+	return &job, err
+}
+
+func (r *DeliveryRepository) Update(job *models.DeliveryJob) error {
+	return r.db.Save(job).Error
+}
+
+// FindDue retrieves pending jobs whose next attempt is due, oldest first.
+func (r *DeliveryRepository) FindDue(now time.Time, limit int) ([]models.DeliveryJob, error) {
+	var jobs []models.DeliveryJob
+	err := r.db.Where("status = ?", models.DeliveryPending).
+		Where("next_attempt_at <= ?", now).
+		Order("next_attempt_at").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// HasPending reports whether a job of the given kind, target and ref is
+// still waiting to be delivered.
+func (r *DeliveryRepository) HasPending(kind, target, ref string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.DeliveryJob{}).
+		Where("kind = ? AND target = ? AND ref = ? AND status = ?", kind, target, ref, models.DeliveryPending).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FindPage retrieves the most recent jobs of the given kinds for the delivery log.
+func (r *DeliveryRepository) FindPage(kinds []string, page, pageSize int) ([]models.DeliveryJob, error) {
+	var jobs []models.DeliveryJob
+	err := r.db.Where("kind IN ?", kinds).Order("id desc").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&jobs).Error
+	return jobs, err
+}
+
+// Count counts the jobs of the given kinds.
+func (r *DeliveryRepository) Count(kinds []string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.DeliveryJob{}).Where("kind IN ?", kinds).Count(&count).Error
+	return count, err
+}
+
+// DeleteFinishedBefore removes succeeded and failed jobs last touched before the cutoff.
+func (r *DeliveryRepository) DeleteFinishedBefore(cutoff time.Time) error {
+	return r.db.Where("status <> ?", models.DeliveryPending).Where("updated_at < ?", cutoff).
+		Delete(&models.DeliveryJob{}).Error
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"glog/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EmbeddingRepository struct {
+	db *gorm.DB
+}
+
+func NewEmbeddingRepository(db *gorm.DB) *EmbeddingRepository {
+	return &EmbeddingRepository{db: db}
+}
+
+// FindAll retrieves the embeddings of all posts.
+func (r *EmbeddingRepository) FindAll() ([]models.PostEmbedding, error) {
+	var embeddings []models.PostEmbedding
+	err := r.db.Find(&embeddings).Error
+	return embeddings, err
+}
+
+// FindHashes retrieves the content hash of every stored embedding, keyed by post ID.
+func (r *EmbeddingRepository) FindHashes() (map[uint]string, error) {
+	var embeddings []models.PostEmbedding
+	if err := r.db.Select("post_id", "content_hash").Find(&embeddings).Error; err != nil {
+		return nil, err
+	}
+	hashes := make(map[uint]string, len(embeddings))
+	for _, e := range embeddings {
+		hashes[e.PostID] = e.ContentHash
+	}
+	return hashes, nil
+}
+
+// Upsert creates or replaces the embedding of a post.
+func (r *EmbeddingRepository) Upsert(embedding *models.PostEmbedding) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "post_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "model", "content_hash", "vector"}),
+	}).Create(embedding).Error
+}
+
+// DeleteByPostIDs removes the embeddings of the given posts.
+func (r *EmbeddingRepository) DeleteByPostIDs(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Where("post_id IN ?", ids).Delete(&models.PostEmbedding{}).Error
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"glog/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FollowerRepository struct {
+	db *gorm.DB
+}
+
+func NewFollowerRepository(db *gorm.DB) *FollowerRepository {
+	return &FollowerRepository{db: db}
+}
+
+// Upsert adds a follower or refreshes the inboxes of an existing one.
+func (r *FollowerRepository) Upsert(follower *models.Follower) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "actor_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"inbox", "shared_inbox"}),
+	}).Create(follower).Error
+}
+
+func (r *FollowerRepository) DeleteByActorID(actorID string) error {
+	return r.db.Where("actor_id = ?", actorID).Delete(&models.Follower{}).Error
+}
+
+func (r *FollowerRepository) FindAll() ([]models.Follower, error) {
+	var followers []models.Follower
+	err := r.db.Order("id").Find(&followers).Error
+	return followers, err
+}
+
+func (r *FollowerRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Follower{}).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"glog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type LoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+func (r *LoginAttemptRepository) Create(attempt *models.LoginAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+// RecentFailures returns the number of failures since the given time and the
+// time of the latest one. An empty ip counts failures from all addresses.
+func (r *LoginAttemptRepository) RecentFailures(ip string, since time.Time) (int64, time.Time, error) {
+	query := r.db.Model(&models.LoginAttempt{}).Where("success = ? AND created_at > ?", false, since)
+	if ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil || count == 0 {
+		return count, time.Time{}, err
+	}
+	var latest models.LoginAttempt
+	err := query.Order("created_at DESC").Limit(1).Find(&latest).Error
+	return count, latest.CreatedAt, err
+}
+
+// LastSuccess returns the time of the latest successful attempt from ip.
+func (r *LoginAttemptRepository) LastSuccess(ip string) (time.Time, error) {
+	var attempt models.LoginAttempt
+	err := r.db.Where("ip = ? AND success = ?", ip, true).Order("created_at DESC").Limit(1).Find(&attempt).Error
+	return attempt.CreatedAt, err
+}
+
+func (r *LoginAttemptRepository) FindPage(page, pageSize int) ([]models.LoginAttempt, int64, error) {
+	var attempts []models.LoginAttempt
+	var total int64
+	if err := r.db.Model(&models.LoginAttempt{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.db.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&attempts).Error
+	return attempts, total, err
+}
+
+func (r *LoginAttemptRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", before).Delete(&models.LoginAttempt{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"glog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PasskeyRepository struct {
+	db *gorm.DB
+}
+
+func NewPasskeyRepository(db *gorm.DB) *PasskeyRepository {
+	return &PasskeyRepository{db: db}
+}
+
+func (r *PasskeyRepository) Create(passkey *models.Passkey) error {
+	return r.db.Create(passkey).Error
+}
+
+func (r *PasskeyRepository) FindAll() ([]models.Passkey, error) {
+	var passkeys []models.Passkey
+	err := r.db.Order("id").Find(&passkeys).Error
+	return passkeys, err
+}
+
+func (r *PasskeyRepository) FindByCredentialID(credentialID []byte) (*models.Passkey, error) {
+	var passkey models.Passkey
+	err := r.db.Where("credential_id = ?", credentialID).First(&passkey).Error
+	return &passkey, err
+}
+
+func (r *PasskeyRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Passkey{}).Count(&count).Error
+	return count, err
+}
+
+// UpdateUsage stores the credential after a login, which carries the new
+// signature counter, and records when it was used.
+func (r *PasskeyRepository) UpdateUsage(id uint, credential string, usedAt time.Time) error {
+	return r.db.Model(&models.Passkey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"credential":   credential,
+		"last_used_at": usedAt,
+	}).Error
+}
+
+func (r *PasskeyRepository) UpdateName(id uint, name string) error {
+	result := r.db.Model(&models.Passkey{}).Where("id = ?", id).Update("name", name)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *PasskeyRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Passkey{}, id).Error
+}
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"glog/internal/models"
+	"strings"
 	"time"
+	"unicode"
 
 	"gorm.io/gorm"
 )
@@ -52,12 +54,21 @@ func (r *PostRepository) FindBySlug(slug string, isLoggedIn bool) (*models.Post,
 }
 
 func (r *PostRepository) FindPage(page, pageSize int, isLoggedIn bool) ([]models.Post, error) {
+	return r.findPage(page, pageSize, isLoggedIn, "id", "created_at", "updated_at", "published_at", "title", "slug", "cover", "excerpt", "is_private")
+}
+
+// FindPageWithContent is FindPage including the Markdown and rendered HTML, for feeds.
+func (r *PostRepository) FindPageWithContent(page, pageSize int, isLoggedIn bool) ([]models.Post, error) {
+	return r.findPage(page, pageSize, isLoggedIn, "id", "created_at", "updated_at", "published_at", "title", "slug", "cover", "content", "content_html", "excerpt", "is_private")
+}
+
+func (r *PostRepository) findPage(page, pageSize int, isLoggedIn bool, columns ...string) ([]models.Post, error) {
 	var posts []models.Post
 	query := r.db.Order("published_at desc")
 	if !isLoggedIn {
 		query = query.Where("is_private = ?", false).Where("published_at <= ?", time.Now().In(shanghaiLocation))
 	}
-	err := query.Select("id", "created_at", "updated_at", "published_at", "title", "slug", "cover", "excerpt", "is_private").Offset((page - 1) * pageSize).Limit(pageSize).Find(&posts).Error
+	err := query.Select(columns).Offset((page - 1) * pageSize).Limit(pageSize).Find(&posts).Error
 	return posts, err
 }
 
@@ -71,16 +82,35 @@ func (r *PostRepository) Count(isLoggedIn bool) (int64, error) {
 	return count, err
 }
 
-func (r *PostRepository) FindAllByAdmin(page, pageSize int, query, status string) ([]models.Post, error) {
-	var posts []models.Post
-	dbQuery := r.db.Order("published_at desc")
+// AdminPostFilter 描述后台文章列表的搜索、筛选与排序条件。
+type AdminPostFilter struct {
+	Query  string    // 关键字，同时匹配标题与正文，多个关键字以空白或逗号分隔
+	Status string    // all, published, draft, private
+	Sort   string    // updated, created, published, title, length
+	Order  string    // asc, desc
+	From   time.Time // 发布时间下限（含），零值表示不限
+	To     time.Time // 发布时间上限（不含），零值表示不限
+}
+
+// adminSortColumns 将排序参数映射为 SQL 排序表达式，避免拼接任意用户输入。
+var adminSortColumns = map[string]string{
+	"updated":   "updated_at",
+	"created":   "created_at",
+	"published": "published_at",
+	"title":     "title",
+	"length":    "LENGTH(content)",
+}
 
-	if query != "" {
-		dbQuery = dbQuery.Where("title LIKE ?", "%"+query+"%")
+func (r *PostRepository) applyAdminFilter(dbQuery *gorm.DB, filter AdminPostFilter) *gorm.DB {
+	for _, keyword := range strings.FieldsFunc(filter.Query, func(c rune) bool {
+		return c == ',' || c == '，' || unicode.IsSpace(c)
+	}) {
+		likeQuery := "%" + keyword + "%"
+		dbQuery = dbQuery.Where("title LIKE ? OR content LIKE ?", likeQuery, likeQuery)
 	}
 
 	now := time.Now().In(shanghaiLocation)
-	switch status {
+	switch filter.Status {
 	case "published":
 		dbQuery = dbQuery.Where("is_private = ? AND published_at <= ?", false, now)
 	case "draft":
@@ -89,28 +119,36 @@ func (r *PostRepository) FindAllByAdmin(page, pageSize int, query, status string
 		dbQuery = dbQuery.Where("is_private = ?", true)
 	}
 
-	err := dbQuery.Select("id", "published_at", "title", "slug", "is_private", "updated_at").Offset((page - 1) * pageSize).Limit(pageSize).Find(&posts).Error
-	return posts, err
+	if !filter.From.IsZero() {
+		dbQuery = dbQuery.Where("published_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		dbQuery = dbQuery.Where("published_at < ?", filter.To)
+	}
+	return dbQuery
 }
 
-func (r *PostRepository) CountAllByAdmin(query, status string) (int64, error) {
-	var count int64
-	dbQuery := r.db.Model(&models.Post{})
+func (r *PostRepository) FindAllByAdmin(page, pageSize int, filter AdminPostFilter) ([]models.Post, error) {
+	var posts []models.Post
 
-	if query != "" {
-		dbQuery = dbQuery.Where("title LIKE ?", "%"+query+"%")
+	column, ok := adminSortColumns[filter.Sort]
+	if !ok {
+		column = adminSortColumns["published"]
 	}
-
-	now := time.Now().In(shanghaiLocation)
-	switch status {
-	case "published":
-		dbQuery = dbQuery.Where("is_private = ? AND published_at <= ?", false, now)
-	case "draft":
-		dbQuery = dbQuery.Where("published_at > ?", now)
-	case "private":
-		dbQuery = dbQuery.Where("is_private = ?", true)
+	direction := "desc"
+	if filter.Order == "asc" {
+		direction = "asc"
 	}
+	// 追加 id 作为次级排序，保证相同排序值的文章在翻页时顺序稳定
+	dbQuery := r.applyAdminFilter(r.db.Order(column+" "+direction+", id "+direction), filter)
 
+	err := dbQuery.Select("id", "created_at", "published_at", "title", "slug", "is_private", "updated_at").Offset((page - 1) * pageSize).Limit(pageSize).Find(&posts).Error
+	return posts, err
+}
+
+func (r *PostRepository) CountAllByAdmin(filter AdminPostFilter) (int64, error) {
+	var count int64
+	dbQuery := r.applyAdminFilter(r.db.Model(&models.Post{}), filter)
 	err := dbQuery.Count(&count).Error
 	return count, err
 }
@@ -133,6 +171,86 @@ func (r *PostRepository) FindAllForBackup() ([]models.Post, error) {
 	return posts, err
 }
 
+// FindAllForRendering retrieves the fields of all posts needed to render their HTML.
+func (r *PostRepository) FindAllForRendering() ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.Select("id", "content", "content_html", "trusted_html").Find(&posts).Error
+	return posts, err
+}
+
+// UpdateContentHTML replaces the rendered HTML of a post without changing its
+// modification time, since the content itself is unchanged.
+func (r *PostRepository) UpdateContentHTML(id uint, contentHTML string) error {
+	return r.db.Model(&models.Post{}).Where("id = ?", id).UpdateColumn("content_html", contentHTML).Error
+}
+
+// FindAllForEmbedding retrieves the fields of all posts needed to build embeddings.
+func (r *PostRepository) FindAllForEmbedding() ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.Select("id", "title", "content").Find(&posts).Error
+	return posts, err
+}
+
+// sitemapScope limits a query to posts search engines may index: public,
+// already published and not marked noindex.
+func (r *PostRepository) sitemapScope() *gorm.DB {
+	return r.db.Model(&models.Post{}).
+		Where("is_private = ?", false).
+		Where("published_at <= ?", time.Now().In(shanghaiLocation)).
+		Where("no_index = ?", false)
+}
+
+// CountForSitemap counts the posts that belong in the sitemap.
+func (r *PostRepository) CountForSitemap() (int64, error) {
+	var count int64
+	err := r.sitemapScope().Count(&count).Error
+	return count, err
+}
+
+// FindForSitemap retrieves the slug and update time of a range of sitemap posts.
+func (r *PostRepository) FindForSitemap(offset, limit int) ([]models.Post, error) {
+	var posts []models.Post
+	err := r.sitemapScope().Select("id", "slug", "updated_at").Order("id").Offset(offset).Limit(limit).Find(&posts).Error
+	return posts, err
+}
+
+// FindVisibleForRetrieval retrieves the title, slug and Markdown content of
+// every post the visitor is allowed to see.
+func (r *PostRepository) FindVisibleForRetrieval(isLoggedIn bool) ([]models.Post, error) {
+	var posts []models.Post
+	query := r.db
+	if !isLoggedIn {
+		query = query.Where("is_private = ?", false).Where("published_at <= ?", time.Now().In(shanghaiLocation))
+	}
+	err := query.Select("id", "title", "slug", "content").Find(&posts).Error
+	return posts, err
+}
+
+// FindVisibleIDs retrieves the IDs of all posts the visitor is allowed to see.
+func (r *PostRepository) FindVisibleIDs(isLoggedIn bool) ([]uint, error) {
+	var ids []uint
+	query := r.db.Model(&models.Post{})
+	if !isLoggedIn {
+		query = query.Where("is_private = ?", false).Where("published_at <= ?", time.Now().In(shanghaiLocation))
+	}
+	err := query.Pluck("id", &ids).Error
+	return ids, err
+}
+
+// FindByIDs retrieves the listed posts that are visible to the visitor, in no particular order.
+func (r *PostRepository) FindByIDs(ids []uint, isLoggedIn bool) ([]models.Post, error) {
+	var posts []models.Post
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	query := r.db.Where("id IN ?", ids)
+	if !isLoggedIn {
+		query = query.Where("is_private = ?", false).Where("published_at <= ?", time.Now().In(shanghaiLocation))
+	}
+	err := query.Select("id", "created_at", "updated_at", "published_at", "title", "slug", "cover", "excerpt", "is_private").Find(&posts).Error
+	return posts, err
+}
+
 func (r *PostRepository) CreateBatchFromBackup(posts []models.Post) error {
 	return r.db.Create(&posts).Error
 }
@@ -141,8 +259,41 @@ func (r *PostRepository) DeleteByIDs(ids []uint) error {
 	return r.db.Delete(&models.Post{}, ids).Error
 }
 
+// FindUnannounced retrieves the posts that are publicly visible but whose
+// publication has not been announced yet, e.g. scheduled posts that just went live.
+func (r *PostRepository) FindUnannounced() ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.Where("is_private = ?", false).
+		Where("published_at <= ?", time.Now().In(shanghaiLocation)).
+		Where("announced_at IS NULL").
+		Order("published_at").Find(&posts).Error
+	return posts, err
+}
+
+// MarkAnnounced records that the post's publication was announced. It
+// reports false when another caller already did so.
+func (r *PostRepository) MarkAnnounced(id uint, at time.Time) (bool, error) {
+	result := r.db.Model(&models.Post{}).Where("id = ? AND announced_at IS NULL", id).Update("announced_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindAllByIDs retrieves the full records of the listed posts regardless of visibility.
+func (r *PostRepository) FindAllByIDs(ids []uint) ([]models.Post, error) {
+	var posts []models.Post
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&posts).Error
+	return posts, err
+}
+
 func (r *PostRepository) UpdatePrivacyByIDs(ids []uint, isPrivate bool) error {
-	return r.db.Model(&models.Post{}).Where("id IN ?", ids).Update("is_private", isPrivate).Error
+	fields := map[string]interface{}{"is_private": isPrivate}
+	if isPrivate {
+		// 再次公开时需要重新发出发布事件
+		fields["announced_at"] = nil
+	}
+	return r.db.Model(&models.Post{}).Where("id IN ?", ids).Updates(fields).Error
 }
 
 // --- LIKE Search Methods ---
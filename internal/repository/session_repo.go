@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"glog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Save(session *models.Session) error {
+	return r.db.Save(session).Error
+}
+
+func (r *SessionRepository) FindByID(id string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Where("id = ?", id).First(&session).Error
+	return &session, err
+}
+
+func (r *SessionRepository) FindActive(idleSince, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("last_seen_at > ? AND expires_at > ?", idleSince, now).Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *SessionRepository) Touch(id, ip, userAgent string, lastSeenAt time.Time) error {
+	return r.db.Model(&models.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"ip":           ip,
+		"user_agent":   userAgent,
+		"last_seen_at": lastSeenAt,
+	}).Error
+}
+
+func (r *SessionRepository) Delete(id string) error {
+	return r.db.Where("id = ?", id).Delete(&models.Session{}).Error
+}
+
+// DeleteAllExcept removes every session other than id.
+func (r *SessionRepository) DeleteAllExcept(id string) error {
+	return r.db.Where("id <> ?", id).Delete(&models.Session{}).Error
+}
+
+func (r *SessionRepository) DeleteAll() error {
+	return r.db.Where("1 = 1").Delete(&models.Session{}).Error
+}
+
+// DeleteExpired removes sessions that are idle since before idleSince or past
+// their absolute expiry.
+func (r *SessionRepository) DeleteExpired(idleSince, now time.Time) (int64, error) {
+	result := r.db.Where("last_seen_at <= ? OR expires_at <= ?", idleSince, now).Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
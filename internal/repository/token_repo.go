@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"glog/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) Create(token *models.AccessToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *TokenRepository) FindByHash(hash string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	return &token, err
+}
+
+func (r *TokenRepository) FindAll() ([]models.AccessToken, error) {
+	var tokens []models.AccessToken
+	err := r.db.Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *TokenRepository) TouchLastUsed(id uint, at time.Time) error {
+	return r.db.Model(&models.AccessToken{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
+
+func (r *TokenRepository) Delete(id uint) error {
+	return r.db.Delete(&models.AccessToken{}, id).Error
+}
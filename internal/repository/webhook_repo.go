@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"glog/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) Create(webhook *models.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *WebhookRepository) FindByID(id uint) (*models.Webhook, error) {
+	var webhook models.Webhook
+	err := r.db.First(&webhook, id).Error
+	return &webhook, err
+}
+
+func (r *WebhookRepository) FindAll() ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *WebhookRepository) FindEnabled() ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Where("enabled = ?", true).Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *WebhookRepository) UpdateEnabled(id uint, enabled bool) error {
+	return r.db.Model(&models.Webhook{}).Where("id = ?", id).Update("enabled", enabled).Error
+}
+
+func (r *WebhookRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Webhook{}, id).Error
+}
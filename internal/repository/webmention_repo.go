@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"glog/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type WebmentionRepository struct {
+	db *gorm.DB
+}
+
+func NewWebmentionRepository(db *gorm.DB) *WebmentionRepository {
+	return &WebmentionRepository{db: db}
+}
+
+func (r *WebmentionRepository) FindByID(id uint) (*models.Webmention, error) {
+	var mention models.Webmention
+	err := r.db.First(&mention, id).Error
+	return &mention, err
+}
+
+func (r *WebmentionRepository) FindBySourceTarget(source, target string) (*models.Webmention, error) {
+	var mention models.Webmention
+	err := r.db.Where("source = ? AND target = ?", source, target).First(&mention).Error
+	return &mention, err
+}
+
+func (r *WebmentionRepository) Save(mention *models.Webmention) error {
+	return r.db.Save(mention).Error
+}
+
+func (r *WebmentionRepository) UpdateStatus(id uint, status string) error {
+	return r.db.Model(&models.Webmention{}).Where("id = ?", id).Update("status", status).Error
+}
+
+func (r *WebmentionRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Webmention{}, id).Error
+}
+
+func (r *WebmentionRepository) DeleteBySourceTarget(source, target string) error {
+	return r.db.Where("source = ? AND target = ?", source, target).Delete(&models.Webmention{}).Error
+}
+
+func (r *WebmentionRepository) DeleteByPostID(postID uint) error {
+	return r.db.Where("post_id = ?", postID).Delete(&models.Webmention{}).Error
+}
+
+// FindApprovedByPost returns the mentions shown under a post, oldest first.
+func (r *WebmentionRepository) FindApprovedByPost(postID uint) ([]models.Webmention, error) {
+	var mentions []models.Webmention
+	err := r.db.Where("post_id = ? AND status = ?", postID, models.WebmentionApproved).
+		Order("created_at").Find(&mentions).Error
+	return mentions, err
+}
+
+// FindPage lists mentions for moderation, newest first. An empty status matches all.
+func (r *WebmentionRepository) FindPage(status string, page, pageSize int) ([]models.Webmention, error) {
+	var mentions []models.Webmention
+	query := r.db.Order("id desc")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Offset((page - 1) * pageSize).Limit(pageSize).Find(&mentions).Error
+	return mentions, err
+}
+
+func (r *WebmentionRepository) Count(status string) (int64, error) {
+	var count int64
+	query := r.db.Model(&models.Webmention{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
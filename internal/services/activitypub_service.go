@@ -0,0 +1,626 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"glog/internal/utils"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DeliveryKindActivityPub = "activitypub"
+
+	activityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	activityStreamsPublic  = "https://www.w3.org/ns/activitystreams#Public"
+	activityContentType    = "application/activity+json"
+
+	apOutboxLimit      = 20
+	apMaxResponseBytes = 1 << 20
+	apKeyCacheTTL      = time.Hour
+)
+
+var (
+	// ErrActivityPubDisabled is returned when federation is switched off or
+	// the site URL, which every ActivityPub ID is built from, is not configured.
+	ErrActivityPubDisabled = errors.New("ActivityPub 未开启")
+	ErrActivityPubNotFound = errors.New("对象不存在")
+	// ErrInboxUnauthorized means the HTTP Signature of an inbox delivery could not be verified.
+	ErrInboxUnauthorized = errors.New("签名校验失败")
+	ErrInboxBadRequest   = errors.New("无效的 Activity")
+)
+
+// ActivityPubService makes the blog a followable ActivityPub actor: it serves
+// WebFinger, the actor, outbox and followers documents, accepts Follow/Undo
+// in the inbox and delivers Create{Article} to followers whenever a post
+// becomes public.
+type ActivityPubService struct {
+	followerRepo   *repository.FollowerRepository
+	postRepo       *repository.PostRepository
+	settingService *SettingService
+	queue          *DeliveryQueue
+
+	keyMu sync.Mutex // 保护本地密钥的首次生成
+
+	remoteKeysMu sync.Mutex
+	remoteKeys   map[string]*remoteKey // keyId -> 远程公钥
+}
+
+type remoteKey struct {
+	key       *rsa.PublicKey
+	owner     string
+	fetchedAt time.Time
+}
+
+// remoteActor holds the fields of a remote actor document we use.
+type remoteActor struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Inbox     string `json:"inbox"`
+	Endpoints struct {
+		SharedInbox string `json:"sharedInbox"`
+	} `json:"endpoints"`
+	PublicKey struct {
+		ID           string `json:"id"`
+		Owner        string `json:"owner"`
+		PublicKeyPem string `json:"publicKeyPem"`
+	} `json:"publicKey"`
+}
+
+type apConfig struct {
+	baseURL  string
+	host     string
+	username string
+	title    string
+	summary  string
+	icon     string
+}
+
+func (c apConfig) actorID() string     { return c.baseURL + "/ap/actor" }
+func (c apConfig) keyID() string       { return c.actorID() + "#main-key" }
+func (c apConfig) followersID() string { return c.baseURL + "/ap/followers" }
+
+func NewActivityPubService(followerRepo *repository.FollowerRepository, postRepo *repository.PostRepository, settingService *SettingService, queue *DeliveryQueue, eventBus *EventBus) *ActivityPubService {
+	s := &ActivityPubService{
+		followerRepo:   followerRepo,
+		postRepo:       postRepo,
+		settingService: settingService,
+		queue:          queue,
+		remoteKeys:     make(map[string]*remoteKey),
+	}
+	queue.Register(DeliveryKindActivityPub, s.deliver)
+	eventBus.Subscribe(s.handleEvent)
+	return s
+}
+
+func (s *ActivityPubService) config() (apConfig, error) {
+	settings, err := s.settingService.GetAllSettings()
+	if err != nil {
+		return apConfig{}, err
+	}
+	if settings[constants.SettingActivityPubEnabled] != "true" {
+		return apConfig{}, ErrActivityPubDisabled
+	}
+	baseURL := strings.TrimRight(settings[constants.SettingSiteURL], "/")
+	u, err := url.Parse(baseURL)
+	if baseURL == "" || err != nil || u.Host == "" {
+		return apConfig{}, ErrActivityPubDisabled
+	}
+
+	cfg := apConfig{
+		baseURL:  baseURL,
+		host:     u.Host,
+		username: settings[constants.SettingActivityPubUsername],
+		title:    settings[constants.SettingSiteTitle],
+		summary:  settings[constants.SettingSiteDescription],
+		icon:     settings[constants.SettingFavicon],
+	}
+	if cfg.username == "" {
+		cfg.username = "blog"
+	}
+	if cfg.title == "" {
+		cfg.title = "Glog"
+	}
+	if strings.HasPrefix(cfg.icon, "/") {
+		cfg.icon = baseURL + cfg.icon
+	}
+	return cfg, nil
+}
+
+// Enabled reports whether federation is switched on and usable.
+func (s *ActivityPubService) Enabled() bool {
+	_, err := s.config()
+	return err == nil
+}
+
+// Handle returns the fediverse handle of the blog, e.g. @blog@example.com.
+func (s *ActivityPubService) Handle() string {
+	cfg, err := s.config()
+	if err != nil {
+		return ""
+	}
+	return "@" + cfg.username + "@" + cfg.host
+}
+
+// FollowerCount returns the number of remote followers.
+func (s *ActivityPubService) FollowerCount() int64 {
+	count, err := s.followerRepo.Count()
+	if err != nil {
+		log.Printf("统计关注者失败: %v", err)
+	}
+	return count
+}
+
+// privateKey returns the actor's signing key, generating and persisting it on first use.
+func (s *ActivityPubService) privateKey() (*rsa.PrivateKey, error) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+
+	keyPEM, _ := s.settingService.GetSetting(constants.SettingActivityPubKey)
+	if keyPEM == "" {
+		generated, err := utils.GenerateRSAKeyPEM()
+		if err != nil {
+			return nil, fmt.Errorf("生成 ActivityPub 密钥失败: %w", err)
+		}
+		if err := s.settingService.UpdateSettings(map[string]string{constants.SettingActivityPubKey: generated}); err != nil {
+			return nil, fmt.Errorf("保存 ActivityPub 密钥失败: %w", err)
+		}
+		keyPEM = generated
+	}
+	return utils.ParseRSAPrivateKeyPEM(keyPEM)
+}
+
+// WebFinger resolves acct:user@host to the actor.
+func (s *ActivityPubService) WebFinger(resource string) (map[string]interface{}, error) {
+	cfg, err := s.config()
+	if err != nil {
+		return nil, err
+	}
+	subject := "acct:" + cfg.username + "@" + cfg.host
+	if resource != subject && resource != cfg.actorID() {
+		return nil, ErrActivityPubNotFound
+	}
+	return map[string]interface{}{
+		"subject": subject,
+		"aliases": []string{cfg.actorID()},
+		"links": []map[string]string{
+			{"rel": "self", "type": activityContentType, "href": cfg.actorID()},
+			{"rel": "http://webfinger.net/rel/profile-page", "type": "text/html", "href": cfg.baseURL + "/"},
+		},
+	}, nil
+}
+
+// Actor returns the actor document.
+func (s *ActivityPubService) Actor() (map[string]interface{}, error) {
+	cfg, err := s.config()
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.privateKey()
+	if err != nil {
+		return nil, err
+	}
+	publicKeyPEM, err := utils.PublicKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	actor := map[string]interface{}{
+		"@context":                  []string{activityStreamsContext, "https://w3id.org/security/v1"},
+		"id":                        cfg.actorID(),
+		"type":                      "Person",
+		"preferredUsername":         cfg.username,
+		"name":                      cfg.title,
+		"summary":                   cfg.summary,
+		"url":                       cfg.baseURL + "/",
+		"inbox":                     cfg.baseURL + "/ap/inbox",
+		"outbox":                    cfg.baseURL + "/ap/outbox",
+		"followers":                 cfg.followersID(),
+		"manuallyApprovesFollowers": false,
+		"discoverable":              true,
+		"publicKey": map[string]string{
+			"id":           cfg.keyID(),
+			"owner":        cfg.actorID(),
+			"publicKeyPem": publicKeyPEM,
+		},
+	}
+	if cfg.icon != "" {
+		actor["icon"] = map[string]string{"type": "Image", "url": cfg.icon}
+	}
+	return actor, nil
+}
+
+// Followers returns the followers collection. Only the total is disclosed.
+func (s *ActivityPubService) Followers() (map[string]interface{}, error) {
+	cfg, err := s.config()
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.followerRepo.Count()
+	if err != nil {
+		return nil, fmt.Errorf("统计关注者失败: %w", err)
+	}
+	return map[string]interface{}{
+		"@context":   activityStreamsContext,
+		"id":         cfg.followersID(),
+		"type":       "OrderedCollection",
+		"totalItems": count,
+	}, nil
+}
+
+// Outbox returns the most recent public posts as Create activities.
+func (s *ActivityPubService) Outbox() (map[string]interface{}, error) {
+	cfg, err := s.config()
+	if err != nil {
+		return nil, err
+	}
+	posts, err := s.postRepo.FindPageWithContent(1, apOutboxLimit, false)
+	if err != nil {
+		return nil, fmt.Errorf("获取文章失败: %w", err)
+	}
+	total, err := s.postRepo.Count(false)
+	if err != nil {
+		return nil, fmt.Errorf("统计文章失败: %w", err)
+	}
+
+	items := make([]map[string]interface{}, 0, len(posts))
+	for i := range posts {
+		items = append(items, s.createActivity(cfg, &posts[i]))
+	}
+	return map[string]interface{}{
+		"@context":     activityStreamsContext,
+		"id":           cfg.baseURL + "/ap/outbox",
+		"type":         "OrderedCollection",
+		"totalItems":   total,
+		"orderedItems": items,
+	}, nil
+}
+
+// ArticleBySlug returns the Article object of a public post, for clients that
+// dereference a post URL with an ActivityPub Accept header.
+func (s *ActivityPubService) ArticleBySlug(slug string) (map[string]interface{}, error) {
+	cfg, err := s.config()
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.postRepo.FindBySlug(slug, false)
+	if err != nil {
+		return nil, ErrActivityPubNotFound
+	}
+	article := s.article(cfg, post)
+	article["@context"] = activityStreamsContext
+	return article, nil
+}
+
+func (s *ActivityPubService) article(cfg apConfig, post *models.Post) map[string]interface{} {
+	postURL := cfg.baseURL + "/post/" + post.Slug
+	content, err := utils.AbsolutizeURLs(post.ContentHTML, cfg.baseURL)
+	if err != nil {
+		content = post.ContentHTML
+	}
+	article := map[string]interface{}{
+		"id":           postURL,
+		"type":         "Article",
+		"attributedTo": cfg.actorID(),
+		"name":         post.Title,
+		"content":      content,
+		"url":          postURL,
+		"published":    post.PublishedAt.Format(time.RFC3339),
+		"to":           []string{activityStreamsPublic},
+		"cc":           []string{cfg.followersID()},
+	}
+	if !post.UpdatedAt.IsZero() && post.UpdatedAt.After(post.PublishedAt) {
+		article["updated"] = post.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	if post.Cover != "" {
+		cover := post.Cover
+		if strings.HasPrefix(cover, "/") {
+			cover = cfg.baseURL + cover
+		}
+		article["image"] = map[string]string{"type": "Image", "url": cover}
+	}
+	return article
+}
+
+func (s *ActivityPubService) createActivity(cfg apConfig, post *models.Post) map[string]interface{} {
+	article := s.article(cfg, post)
+	return map[string]interface{}{
+		"id":        article["id"].(string) + "#create",
+		"type":      "Create",
+		"actor":     cfg.actorID(),
+		"published": article["published"],
+		"to":        article["to"],
+		"cc":        article["cc"],
+		"object":    article,
+	}
+}
+
+func (s *ActivityPubService) handleEvent(event Event) {
+	if event.Type != EventPostPublished {
+		return
+	}
+	cfg, err := s.config()
+	if err != nil {
+		return
+	}
+	if err := s.deliverToFollowers(cfg, event.Post); err != nil {
+		log.Printf("推送文章 ID %d 到 ActivityPub 关注者失败: %v", event.Post.ID, err)
+	}
+}
+
+// deliverToFollowers queues a Create{Article} for every distinct follower
+// inbox, preferring shared inboxes so each server receives it once.
+func (s *ActivityPubService) deliverToFollowers(cfg apConfig, post *models.Post) error {
+	followers, err := s.followerRepo.FindAll()
+	if err != nil {
+		return fmt.Errorf("获取关注者失败: %w", err)
+	}
+	if len(followers) == 0 {
+		return nil
+	}
+
+	activity := s.createActivity(cfg, post)
+	activity["@context"] = activityStreamsContext
+	payload, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, follower := range followers {
+		inbox := follower.SharedInbox
+		if inbox == "" {
+			inbox = follower.Inbox
+		}
+		if seen[inbox] {
+			continue
+		}
+		seen[inbox] = true
+		if err := s.queue.Enqueue(DeliveryKindActivityPub, inbox, string(payload), activity["id"].(string)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver signs and POSTs a queued activity to a remote inbox.
+func (s *ActivityPubService) deliver(job *models.DeliveryJob) (int, error) {
+	cfg, err := s.config()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrDeliveryPermanent, err)
+	}
+	key, err := s.privateKey()
+	if err != nil {
+		return 0, err
+	}
+
+	body := []byte(job.Payload)
+	req, err := http.NewRequest(http.MethodPost, job.Target, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrDeliveryPermanent, err)
+	}
+	req.Header.Set("Content-Type", activityContentType)
+	req.Header.Set("Accept", activityContentType)
+	req.Header.Set("User-Agent", "Glog (+"+cfg.baseURL+"/)")
+	if err := utils.SignRequest(req, cfg.keyID(), key, body); err != nil {
+		return 0, err
+	}
+
+	resp, err := s.queue.Client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, apMaxResponseBytes))
+	return CheckDeliveryResponse(resp)
+}
+
+// fetch retrieves a remote ActivityPub document with a signed GET, which
+// servers running in secure mode require.
+func (s *ActivityPubService) fetch(cfg apConfig, rawURL string) (*remoteActor, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("无效的地址: %s", rawURL)
+	}
+	u.Fragment = ""
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", activityContentType+`, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`)
+	req.Header.Set("User-Agent", "Glog (+"+cfg.baseURL+"/)")
+	if key, err := s.privateKey(); err == nil {
+		if err := utils.SignRequest(req, cfg.keyID(), key, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := s.queue.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取 %s 失败: %s", u, resp.Status)
+	}
+	var actor remoteActor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, apMaxResponseBytes)).Decode(&actor); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", u, err)
+	}
+	return &actor, nil
+}
+
+// lookupKey returns the public key of actorID, fetching and caching it as
+// needed. The key is read from the actor document and only accepted when the
+// actor lists keyID as its key, so a key hosted elsewhere cannot claim to
+// belong to the actor.
+func (s *ActivityPubService) lookupKey(cfg apConfig, keyID, actorID string, refresh bool) (*remoteKey, error) {
+	if !sameOrigin(keyID, actorID) {
+		return nil, errors.New("keyId 与 actor 不在同一站点")
+	}
+
+	s.remoteKeysMu.Lock()
+	cached, ok := s.remoteKeys[keyID]
+	s.remoteKeysMu.Unlock()
+	if ok && !refresh && cached.owner == actorID && time.Since(cached.fetchedAt) < apKeyCacheTTL {
+		return cached, nil
+	}
+
+	doc, err := s.fetch(cfg, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.ID != actorID {
+		return nil, errors.New("actor 文档的 id 不一致")
+	}
+	if doc.PublicKey.ID != keyID {
+		return nil, errors.New("签名所用的密钥不属于该 actor")
+	}
+	if doc.PublicKey.Owner != "" && doc.PublicKey.Owner != actorID {
+		return nil, errors.New("公钥的 owner 与 actor 不一致")
+	}
+	if doc.PublicKey.PublicKeyPem == "" {
+		return nil, errors.New("远程文档中没有公钥")
+	}
+	key, err := utils.ParseRSAPublicKeyPEM(doc.PublicKey.PublicKeyPem)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &remoteKey{key: key, owner: actorID, fetchedAt: time.Now()}
+	s.remoteKeysMu.Lock()
+	s.remoteKeys[keyID] = entry
+	s.remoteKeysMu.Unlock()
+	return entry, nil
+}
+
+// sameOrigin reports whether two URLs share scheme and host.
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Host != "" && ua.Scheme == ub.Scheme && strings.EqualFold(ua.Host, ub.Host)
+}
+
+type inboxActivity struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// objectID extracts the id of an object that may be given inline or as a bare IRI.
+func objectID(raw json.RawMessage) (id string, objectType string) {
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return id, ""
+	}
+	var object struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	json.Unmarshal(raw, &object)
+	return object.ID, object.Type
+}
+
+// HandleInbox verifies and processes an activity POSTed to the inbox.
+// Follow and Undo{Follow} are acted upon; anything else is accepted and ignored.
+func (s *ActivityPubService) HandleInbox(req *http.Request, body []byte) error {
+	cfg, err := s.config()
+	if err != nil {
+		return err
+	}
+
+	var activity inboxActivity
+	if err := json.Unmarshal(body, &activity); err != nil || activity.Type == "" || activity.Actor == "" {
+		return ErrInboxBadRequest
+	}
+	if activity.Type != "Follow" && activity.Type != "Undo" {
+		return nil
+	}
+
+	keyID, err := utils.SignatureKeyID(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInboxUnauthorized, err)
+	}
+	key, err := s.lookupKey(cfg, keyID, activity.Actor, false)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInboxUnauthorized, err)
+	}
+	if err := utils.VerifyRequest(req, body, key.key); err != nil {
+		// 对方可能更换了密钥，重新获取一次
+		if key, err = s.lookupKey(cfg, keyID, activity.Actor, true); err != nil {
+			return fmt.Errorf("%w: %v", ErrInboxUnauthorized, err)
+		}
+		if err := utils.VerifyRequest(req, body, key.key); err != nil {
+			return fmt.Errorf("%w: %v", ErrInboxUnauthorized, err)
+		}
+	}
+	switch activity.Type {
+	case "Follow":
+		if target, _ := objectID(activity.Object); target != cfg.actorID() {
+			return fmt.Errorf("%w: 关注的对象不是本站", ErrInboxBadRequest)
+		}
+		return s.acceptFollow(cfg, &activity, body)
+	case "Undo":
+		if _, objectType := objectID(activity.Object); objectType != "" && objectType != "Follow" {
+			return nil
+		}
+		if err := s.followerRepo.DeleteByActorID(activity.Actor); err != nil {
+			return fmt.Errorf("删除关注者失败: %w", err)
+		}
+		log.Printf("ActivityPub: %s 取消了关注", activity.Actor)
+	}
+	return nil
+}
+
+func (s *ActivityPubService) acceptFollow(cfg apConfig, follow *inboxActivity, body []byte) error {
+	actor, err := s.fetch(cfg, follow.Actor)
+	if err != nil {
+		return fmt.Errorf("获取关注者信息失败: %w", err)
+	}
+	if actor.ID != follow.Actor || actor.Inbox == "" {
+		return fmt.Errorf("%w: 关注者信息不完整", ErrInboxBadRequest)
+	}
+
+	err = s.followerRepo.Upsert(&models.Follower{
+		ActorID:     actor.ID,
+		Inbox:       actor.Inbox,
+		SharedInbox: actor.Endpoints.SharedInbox,
+	})
+	if err != nil {
+		return fmt.Errorf("保存关注者失败: %w", err)
+	}
+	log.Printf("ActivityPub: 新的关注者 %s", actor.ID)
+
+	hash := sha256.Sum256(body)
+	accept, err := json.Marshal(map[string]interface{}{
+		"@context": activityStreamsContext,
+		"id":       cfg.actorID() + "#accepts/" + hex.EncodeToString(hash[:8]),
+		"type":     "Accept",
+		"actor":    cfg.actorID(),
+		"object":   json.RawMessage(body),
+	})
+	if err != nil {
+		return err
+	}
+	return s.queue.Enqueue(DeliveryKindActivityPub, actor.Inbox, string(accept), follow.ID)
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"glog/internal/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testBlogURL = "https://blog.example"
+
+// fakeServer is a remote fediverse server with actors and inboxes.
+type fakeServer struct {
+	server *httptest.Server
+	actors map[string]map[string]interface{}
+	// inbox 收到的投递，签名已用 verifyKey 校验
+	received  chan map[string]interface{}
+	verifyKey *rsa.PublicKey
+	t         *testing.T
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{actors: make(map[string]map[string]interface{}), received: make(chan map[string]interface{}, 10), t: t}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && r.URL.Path == "/inbox" {
+		body, _ := io.ReadAll(r.Body)
+		if err := utils.VerifyRequest(r, body, f.verifyKey); err != nil {
+			f.t.Errorf("投递的签名无效: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var activity map[string]interface{}
+		json.Unmarshal(body, &activity)
+		f.received <- activity
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	actor, ok := f.actors[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", activityContentType)
+	json.NewEncoder(w).Encode(actor)
+}
+
+// addActor publishes an actor whose key has the given id and returns the
+// actor id and its signing key.
+func (f *fakeServer) addActor(t *testing.T, path, keyID string) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemData, err := utils.PublicKeyPEM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actorID := f.server.URL + path
+	if keyID == "" {
+		keyID = actorID + "#main-key"
+	}
+	f.actors[path] = map[string]interface{}{
+		"id":    actorID,
+		"type":  "Person",
+		"inbox": f.server.URL + "/inbox",
+		"publicKey": map[string]string{
+			"id":           keyID,
+			"owner":        actorID,
+			"publicKeyPem": pemData,
+		},
+	}
+	return actorID, key
+}
+
+func newTestActivityPubService(t *testing.T) (*ActivityPubService, *repository.FollowerRepository) {
+	t.Helper()
+	db := newTestDB(t)
+	settingService := newTestSettings(t, db, map[string]string{
+		constants.SettingActivityPubEnabled: "true",
+		constants.SettingSiteURL:            testBlogURL,
+	})
+	queue := NewDeliveryQueue(repository.NewDeliveryRepository(db))
+	// 测试中的远程服务器运行在本机
+	queue.client = &http.Client{Timeout: 5 * time.Second}
+	followerRepo := repository.NewFollowerRepository(db)
+	service := NewActivityPubService(followerRepo, repository.NewPostRepository(db), settingService, queue, NewEventBus())
+	return service, followerRepo
+}
+
+func signedInboxRequest(t *testing.T, keyID string, key *rsa.PrivateKey, body []byte) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, testBlogURL+"/ap/inbox", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", activityContentType)
+	if err := utils.SignRequest(req, keyID, key, body); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func followBody(actorID string) []byte {
+	body, _ := json.Marshal(map[string]interface{}{
+		"@context": activityStreamsContext,
+		"id":       actorID + "#follows/1",
+		"type":     "Follow",
+		"actor":    actorID,
+		"object":   testBlogURL + "/ap/actor",
+	})
+	return body
+}
+
+// waitForDelivery keeps processing the queue until the remote inbox receives
+// an activity. A job enqueued while the queue is busy otherwise waits for the
+// next periodic run.
+func waitForDelivery(t *testing.T, queue *DeliveryQueue, f *fakeServer) map[string]interface{} {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case activity := <-f.received:
+			return activity
+		case <-timeout:
+			t.Fatal("等待投递超时")
+			return nil
+		case <-time.After(50 * time.Millisecond):
+			queue.ProcessDue()
+		}
+	}
+}
+
+func TestInboxAcceptsSignedFollowAndDelivers(t *testing.T) {
+	service, followerRepo := newTestActivityPubService(t)
+	remote := newFakeServer(t)
+	actorID, key := remote.addActor(t, "/users/alice", "")
+	blogKey, err := service.privateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.verifyKey = &blogKey.PublicKey
+
+	body := followBody(actorID)
+	if err := service.HandleInbox(signedInboxRequest(t, actorID+"#main-key", key, body), body); err != nil {
+		t.Fatalf("处理 Follow 失败: %v", err)
+	}
+	followers, err := followerRepo.FindAll()
+	if err != nil || len(followers) != 1 || followers[0].ActorID != actorID {
+		t.Fatalf("应保存关注者 %s，实际 %v (err=%v)", actorID, followers, err)
+	}
+
+	accept := waitForDelivery(t, service.queue, remote)
+	if accept["type"] != "Accept" || accept["actor"] != testBlogURL+"/ap/actor" {
+		t.Errorf("应向关注者投递 Accept，实际 %v", accept)
+	}
+
+	cfg, err := service.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := &models.Post{ID: 1, Title: "Hello", Slug: "hello", ContentHTML: "<p>Hi</p>", PublishedAt: time.Now()}
+	if err := service.deliverToFollowers(cfg, post); err != nil {
+		t.Fatalf("推送文章失败: %v", err)
+	}
+	create := waitForDelivery(t, service.queue, remote)
+	if create["type"] != "Create" {
+		t.Errorf("应向关注者投递 Create，实际 %v", create)
+	}
+
+	undo, _ := json.Marshal(map[string]interface{}{
+		"id":     actorID + "#undo/1",
+		"type":   "Undo",
+		"actor":  actorID,
+		"object": map[string]string{"id": actorID + "#follows/1", "type": "Follow"},
+	})
+	if err := service.HandleInbox(signedInboxRequest(t, actorID+"#main-key", key, undo), undo); err != nil {
+		t.Fatalf("处理 Undo 失败: %v", err)
+	}
+	if count, _ := followerRepo.Count(); count != 0 {
+		t.Errorf("取消关注后应删除关注者，还剩 %d 个", count)
+	}
+}
+
+func TestInboxRejectsForgedSignatures(t *testing.T) {
+	service, followerRepo := newTestActivityPubService(t)
+	remote := newFakeServer(t)
+	aliceID, aliceKey := remote.addActor(t, "/users/alice", "")
+	malloryID, malloryKey := remote.addActor(t, "/users/mallory", "")
+
+	// 另一台服务器上的密钥声称属于 alice
+	attacker := newFakeServer(t)
+	evilKeyID := attacker.server.URL + "/keys/evil"
+	_, evilKey := attacker.addActor(t, "/keys/evil", evilKeyID)
+	attacker.actors["/keys/evil"]["publicKey"].(map[string]string)["owner"] = aliceID
+
+	body := followBody(aliceID)
+	cases := []struct {
+		name  string
+		keyID string
+		key   *rsa.PrivateKey
+		body  []byte
+	}{
+		{"其他站点的密钥冒充 actor", evilKeyID, evilKey, body},
+		{"同一站点其他用户的密钥", malloryID + "#main-key", malloryKey, body},
+		{"不属于 actor 的 keyId", aliceID + "#other-key", aliceKey, body},
+		{"请求体被篡改", aliceID + "#main-key", aliceKey, followBody(aliceID + "?x")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := signedInboxRequest(t, tc.keyID, tc.key, tc.body)
+			err := service.HandleInbox(req, body)
+			if !errors.Is(err, ErrInboxUnauthorized) {
+				t.Fatalf("应拒绝伪造的签名，实际 %v", err)
+			}
+		})
+	}
+	if count, _ := followerRepo.Count(); count != 0 {
+		t.Errorf("伪造的 Follow 不应保存关注者，实际 %d 个", count)
+	}
+}
+
+func TestDeliveryQueueRefusesLocalTargets(t *testing.T) {
+	db := newTestDB(t)
+	queue := NewDeliveryQueue(repository.NewDeliveryRepository(db))
+	remote := newFakeServer(t)
+
+	_, err := queue.Client().Get(remote.server.URL + "/users/alice")
+	if !errors.Is(err, utils.ErrPrivateAddress) {
+		t.Fatalf("投递队列不应访问本机地址，实际 %v", err)
+	}
+}
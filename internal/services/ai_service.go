@@ -1,7 +1,9 @@
 package services
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,6 +30,7 @@ func NewAIService() *AIService {
 type openAIRequest struct {
 	Model    string    `json:"model"`
 	Messages []message `json:"messages"`
+	Stream   bool      `json:"stream,omitempty"`
 }
 
 type message struct {
@@ -118,3 +121,166 @@ func (s *AIService) GenerateSummaryAndTitle(content string, needsTitle bool, bas
 
 	return &aiResp, nil
 }
+
+// OpenAI embeddings API request structure
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// OpenAI embeddings API response structure
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// embeddingsURL derives the embeddings endpoint from the configured base URL.
+// The base URL setting usually points at the chat completions endpoint, so the
+// "/chat/completions" suffix is swapped for "/embeddings"; a bare API root such
+// as "https://api.openai.com/v1" simply gets "/embeddings" appended.
+func embeddingsURL(baseURL string) string {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if strings.HasSuffix(baseURL, "/embeddings") {
+		return baseURL
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/chat/completions")
+	return baseURL + "/embeddings"
+}
+
+// CreateEmbeddings generates one embedding vector per input using the same
+// OpenAI compatible endpoint and token as the chat features.
+func (s *AIService) CreateEmbeddings(inputs []string, baseURL, token, model string) ([][]float32, error) {
+	if baseURL == "" || token == "" || model == "" {
+		return nil, errors.New("AI Embedding 接口未配置！")
+	}
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	jsonData, err := json.Marshal(embeddingRequest{Model: model, Input: inputs})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", embeddingsURL(baseURL), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求至 Embedding API 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Embedding API 返回非 200 状态码 %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var apiResp embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("解码 Embedding API 响应失败: %w", err)
+	}
+	if len(apiResp.Data) != len(inputs) {
+		return nil, fmt.Errorf("Embedding API 返回 %d 个向量，期望 %d 个", len(apiResp.Data), len(inputs))
+	}
+
+	// The API may return items out of order, so place them by index.
+	vectors := make([][]float32, len(inputs))
+	for _, item := range apiResp.Data {
+		if item.Index < 0 || item.Index >= len(inputs) || len(item.Embedding) == 0 {
+			return nil, errors.New("Embedding API 返回无效向量")
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for _, v := range vectors {
+		if v == nil {
+			return nil, errors.New("Embedding API 返回的向量不完整")
+		}
+	}
+	return vectors, nil
+}
+
+// OpenAI streaming chunk structure
+type streamChunk struct {
+	Choices []struct {
+		Delta message `json:"delta"`
+	} `json:"choices"`
+}
+
+// StreamChat sends a chat completion request with streaming enabled and calls
+// onDelta for every piece of generated text. Endpoints that ignore the stream
+// flag and answer with a regular JSON body are handled as a single delta.
+func (s *AIService) StreamChat(ctx context.Context, messages []message, baseURL, token, model string, onDelta func(string) error) error {
+	if baseURL == "" || token == "" || model == "" {
+		return errors.New("AI 接口未配置！")
+	}
+
+	jsonData, err := json.Marshal(openAIRequest{Model: model, Messages: messages, Stream: true})
+	if err != nil {
+		return fmt.Errorf("序列化请求体失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求至 AI API 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("AI API 返回非 200 状态码 %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var apiResp openAIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+			return fmt.Errorf("解码 AI API 响应失败: %w", err)
+		}
+		if len(apiResp.Choices) == 0 {
+			return errors.New("AI API 返回无效回复")
+		}
+		return onDelta(apiResp.Choices[0].Message.Content)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("解析 AI 流式响应失败: %w", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		if err := onDelta(chunk.Choices[0].Delta.Content); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取 AI 流式响应失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/repository"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	askChunkRunes = 600 // 单个检索片段的最大长度
+	askTopChunks  = 6   // 送入模型的片段数量
+	askMaxRunes   = 300 // 问题的最大长度
+
+	// BM25 参数
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+var (
+	ErrAskDisabled   = errors.New("问答功能未开启")
+	ErrAskBadRequest = errors.New("问题不能为空且不能超过 300 字")
+)
+
+// AskSource is a post excerpt the answer is grounded in.
+type AskSource struct {
+	Index int    `json:"index"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// AskService answers natural-language questions from the blog's own posts:
+// it ranks post chunks with BM25 and feeds the best ones to the AI as the
+// only allowed context.
+type AskService struct {
+	postRepo       *repository.PostRepository
+	settingService *SettingService
+	aiService      *AIService
+}
+
+func NewAskService(postRepo *repository.PostRepository, settingService *SettingService, aiService *AIService) *AskService {
+	return &AskService{
+		postRepo:       postRepo,
+		settingService: settingService,
+		aiService:      aiService,
+	}
+}
+
+// Enabled reports whether the feature is switched on and the AI endpoint is configured.
+func (s *AskService) Enabled() bool {
+	settings, err := s.settingService.GetAllSettings()
+	if err != nil {
+		return false
+	}
+	return settings[constants.SettingAskEnabled] == "true" &&
+		settings[constants.SettingOpenAIBaseURL] != "" &&
+		settings[constants.SettingOpenAIToken] != "" &&
+		settings[constants.SettingOpenAIModel] != ""
+}
+
+type askChunk struct {
+	title  string
+	slug   string
+	text   string
+	tokens []string
+	score  float64
+}
+
+var markdownNoiseRegex = regexp.MustCompile("(?m)^#{1,6}\\s+|[*_`>~]|!\\[[^\\]]*\\]\\([^)]*\\)|<!--.*?-->")
+
+// splitIntoChunks cuts a post into paragraph-aligned chunks of bounded length.
+func splitIntoChunks(content string) []string {
+	content = markdownNoiseRegex.ReplaceAllString(content, "")
+	var chunks []string
+	var current []rune
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		runes := []rune(paragraph)
+		if len(current) > 0 && len(current)+len(runes) > askChunkRunes {
+			chunks = append(chunks, string(current))
+			current = nil
+		}
+		for len(runes) > askChunkRunes {
+			chunks = append(chunks, string(runes[:askChunkRunes]))
+			runes = runes[askChunkRunes:]
+		}
+		if len(current) > 0 {
+			current = append(current, '\n')
+		}
+		current = append(current, runes...)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, string(current))
+	}
+	return chunks
+}
+
+// tokenize lowercases Latin words and turns runs of Han characters into
+// overlapping bigrams, which works reasonably for Chinese without a dictionary.
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var han []rune
+
+	flushWord := func() {
+		if len(word) > 1 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+		}
+		word = word[:0]
+	}
+	flushHan := func() {
+		if len(han) == 1 {
+			tokens = append(tokens, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			tokens = append(tokens, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+// retrieve returns the chunks most relevant to the question. Private and
+// scheduled posts are only considered for logged-in visitors.
+func (s *AskService) retrieve(question string, isLoggedIn bool) ([]*askChunk, error) {
+	posts, err := s.postRepo.FindVisibleForRetrieval(isLoggedIn)
+	if err != nil {
+		return nil, fmt.Errorf("获取文章失败: %w", err)
+	}
+
+	var chunks []*askChunk
+	var totalLen int
+	for _, post := range posts {
+		for _, text := range splitIntoChunks(post.Content) {
+			// 标题参与每个片段的匹配，帮助定位主题相关的文章
+			tokens := tokenize(post.Title + " " + text)
+			chunks = append(chunks, &askChunk{title: post.Title, slug: post.Slug, text: text, tokens: tokens})
+			totalLen += len(tokens)
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	avgLen := float64(totalLen) / float64(len(chunks))
+
+	queryTerms := make(map[string]bool)
+	for _, t := range tokenize(question) {
+		queryTerms[t] = true
+	}
+
+	docFreq := make(map[string]int)
+	termFreqs := make([]map[string]int, len(chunks))
+	for i, chunk := range chunks {
+		tf := make(map[string]int)
+		for _, t := range chunk.tokens {
+			if queryTerms[t] {
+				tf[t]++
+			}
+		}
+		for t := range tf {
+			docFreq[t]++
+		}
+		termFreqs[i] = tf
+	}
+
+	n := float64(len(chunks))
+	var ranked []*askChunk
+	for i, chunk := range chunks {
+		for t, f := range termFreqs[i] {
+			idf := math.Log(1 + (n-float64(docFreq[t])+0.5)/(float64(docFreq[t])+0.5))
+			tf := float64(f)
+			chunk.score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(len(chunk.tokens))/avgLen))
+		}
+		if chunk.score > 0 {
+			ranked = append(ranked, chunk)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	if len(ranked) > askTopChunks {
+		ranked = ranked[:askTopChunks]
+	}
+	return ranked, nil
+}
+
+// Ask retrieves the relevant posts, reports them through onSources and then
+// streams the grounded answer through onDelta.
+func (s *AskService) Ask(ctx context.Context, question string, isLoggedIn bool, onSources func([]AskSource) error, onDelta func(string) error) error {
+	question = strings.TrimSpace(question)
+	if question == "" || len([]rune(question)) > askMaxRunes {
+		return ErrAskBadRequest
+	}
+	if !s.Enabled() {
+		return ErrAskDisabled
+	}
+
+	chunks, err := s.retrieve(question, isLoggedIn)
+	if err != nil {
+		return err
+	}
+
+	// 同一篇文章的多个片段共用一个引用编号
+	var sources []AskSource
+	sourceIndex := make(map[string]int)
+	var excerpts strings.Builder
+	for _, chunk := range chunks {
+		index, ok := sourceIndex[chunk.slug]
+		if !ok {
+			index = len(sources) + 1
+			sourceIndex[chunk.slug] = index
+			sources = append(sources, AskSource{Index: index, Title: chunk.title, URL: "/post/" + chunk.slug})
+		}
+		fmt.Fprintf(&excerpts, "[%d] 《%s》\n%s\n\n", index, chunk.title, chunk.text)
+	}
+
+	if err := onSources(sources); err != nil {
+		return err
+	}
+
+	settings, err := s.settingService.GetAllSettings()
+	if err != nil {
+		return err
+	}
+	siteTitle := settings[constants.SettingSiteTitle]
+	if siteTitle == "" {
+		siteTitle = "Glog"
+	}
+
+	systemPrompt := fmt.Sprintf("你是博客「%s」的问答助手。你只能依据用户提供的文章片段回答问题，不得使用片段以外的知识或编造内容。"+
+		"引用片段时在句末用方括号标注其编号，例如 [1]。如果片段中没有足够的信息，请直接说明博客中没有找到相关内容。"+
+		"请使用与问题相同的语言简洁作答。", siteTitle)
+
+	// 没有检索到任何片段时无需调用 AI
+	if len(chunks) == 0 {
+		return onDelta("博客中没有找到与该问题相关的内容。")
+	}
+	userPrompt := "文章片段：\n\n" + excerpts.String() + "问题：" + question
+
+	return s.aiService.StreamChat(ctx, []message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	}, settings[constants.SettingOpenAIBaseURL], settings[constants.SettingOpenAIToken], settings[constants.SettingOpenAIModel], onDelta)
+}
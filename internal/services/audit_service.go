@@ -0,0 +1,202 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AuditLogin            = "login"
+	AuditLogout           = "logout"
+	AuditPasswordChange   = "password.change"
+	AuditSettingsUpdate   = "settings.update"
+	AuditBackupDownload   = "backup.download"
+	AuditBackupRestore    = "backup.restore"
+	AuditPostCreate       = "post.create"
+	AuditPostUpdate       = "post.update"
+	AuditPostDelete       = "post.delete"
+	AuditPostBatch        = "post.batch"
+	AuditTokenCreate      = "token.create"
+	AuditTokenRevoke      = "token.revoke"
+	AuditTwoFactorEnable  = "2fa.enable"
+	AuditTwoFactorDisable = "2fa.disable"
+	AuditRecoveryCodes    = "2fa.recovery_codes"
+	AuditPasskeyAdd       = "passkey.add"
+	AuditPasskeyRename    = "passkey.rename"
+	AuditPasskeyDelete    = "passkey.delete"
+	AuditSessionRevoke    = "session.revoke"
+	AuditSessionRevokeAll = "session.revoke_all"
+	AuditSessionRotate    = "session.rotate_secret"
+	AuditWebhookCreate    = "webhook.create"
+	AuditWebhookDelete    = "webhook.delete"
+	AuditMetaWeblogEnable = "metaweblog.generate"
+	AuditMetaWeblogRevoke = "metaweblog.revoke"
+
+	// 审计日志中单个设置值最多保存的字数
+	auditValueLimit           = 200
+	defaultAuditRetentionDays = 365
+)
+
+// AuditAction describes an action for the filter on the audit page.
+type AuditAction struct {
+	Action string
+	Label  string
+}
+
+// AuditActions lists the recorded actions in the order shown on the page.
+var AuditActions = []AuditAction{
+	{AuditLogin, "登录"},
+	{AuditLogout, "退出登录"},
+	{AuditPasswordChange, "修改密码"},
+	{AuditSettingsUpdate, "修改设置"},
+	{AuditBackupDownload, "下载备份"},
+	{AuditBackupRestore, "恢复备份"},
+	{AuditPostCreate, "发布文章"},
+	{AuditPostUpdate, "修改文章"},
+	{AuditPostDelete, "删除文章"},
+	{AuditPostBatch, "批量操作文章"},
+	{AuditTokenCreate, "创建访问令牌"},
+	{AuditTokenRevoke, "撤销访问令牌"},
+	{AuditTwoFactorEnable, "启用两步验证"},
+	{AuditTwoFactorDisable, "关闭两步验证"},
+	{AuditRecoveryCodes, "重新生成恢复码"},
+	{AuditPasskeyAdd, "添加通行密钥"},
+	{AuditPasskeyRename, "重命名通行密钥"},
+	{AuditPasskeyDelete, "删除通行密钥"},
+	{AuditSessionRevoke, "撤销会话"},
+	{AuditSessionRevokeAll, "在所有设备上退出"},
+	{AuditSessionRotate, "轮换会话密钥"},
+	{AuditWebhookCreate, "添加 Webhook"},
+	{AuditWebhookDelete, "删除 Webhook"},
+	{AuditMetaWeblogEnable, "生成 MetaWeblog 密码"},
+	{AuditMetaWeblogRevoke, "停用 MetaWeblog 密码"},
+}
+
+// AuditService keeps the append-only log of sensitive actions.
+type AuditService struct {
+	repo           *repository.AuditLogRepository
+	settingService *SettingService
+}
+
+func NewAuditService(repo *repository.AuditLogRepository, settingService *SettingService) *AuditService {
+	return &AuditService{repo: repo, settingService: settingService}
+}
+
+// Record appends an entry. A failure is only logged, so that the action
+// itself is not undone by a problem with the log.
+func (s *AuditService) Record(actor, ip, action, target string, changes []models.AuditChange) {
+	entry := &models.AuditLog{Actor: actor, IP: ip, Action: action, Target: target}
+	if len(changes) > 0 {
+		data, err := json.Marshal(changes)
+		if err != nil {
+			log.Printf("编码审计日志失败: %v", err)
+		} else {
+			entry.Changes = string(data)
+		}
+	}
+	if err := s.repo.Create(entry); err != nil {
+		log.Printf("记录审计日志失败: %v", err)
+	}
+}
+
+func (s *AuditService) List(filter repository.AuditLogFilter, page, pageSize int) ([]models.AuditLog, int64, error) {
+	return s.repo.FindPage(filter, page, pageSize)
+}
+
+func (s *AuditService) Actors() ([]string, error) {
+	return s.repo.Actors()
+}
+
+// RetentionDays returns how many days entries are kept; 0 keeps them forever.
+func (s *AuditService) RetentionDays() int {
+	value, _ := s.settingService.GetSetting(constants.SettingAuditRetentionDays)
+	if days, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && days >= 0 {
+		return days
+	}
+	return defaultAuditRetentionDays
+}
+
+// Cleanup deletes entries older than the retention period; it runs as a
+// background job.
+func (s *AuditService) Cleanup() error {
+	days := s.RetentionDays()
+	if days == 0 {
+		return nil
+	}
+	count, err := s.repo.DeleteBefore(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return fmt.Errorf("清理审计日志失败: %w", err)
+	}
+	if count > 0 {
+		log.Printf("已清理 %d 条过期审计日志", count)
+	}
+	return nil
+}
+
+// DiffSettings returns the settings in after whose value differs from
+// before. Values of secret settings are left out.
+func DiffSettings(before, after map[string]string) []models.AuditChange {
+	keys := make([]string, 0, len(after))
+	for key := range after {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var changes []models.AuditChange
+	for _, key := range keys {
+		if before[key] == after[key] {
+			continue
+		}
+		if SettingVisibilityOf(key) == SettingSecret {
+			changes = append(changes, models.AuditChange{Key: key, Secret: true})
+			continue
+		}
+		changes = append(changes, models.AuditChange{Key: key, Old: truncateAuditValue(before[key]), New: truncateAuditValue(after[key])})
+	}
+	return changes
+}
+
+// DiffPost returns the changed fields of a post. The content itself is not
+// stored, only its length.
+func DiffPost(before, after *models.Post) []models.AuditChange {
+	var changes []models.AuditChange
+	add := func(key, old, new string) {
+		if old != new {
+			changes = append(changes, models.AuditChange{Key: key, Old: truncateAuditValue(old), New: truncateAuditValue(new)})
+		}
+	}
+	add("title", before.Title, after.Title)
+	add("is_private", strconv.FormatBool(before.IsPrivate), strconv.FormatBool(after.IsPrivate))
+	add("no_index", strconv.FormatBool(before.NoIndex), strconv.FormatBool(after.NoIndex))
+	add("trusted_html", strconv.FormatBool(before.TrustedHTML), strconv.FormatBool(after.TrustedHTML))
+	add("published_at", before.PublishedAt.Format("2006-01-02 15:04"), after.PublishedAt.Format("2006-01-02 15:04"))
+	if before.Content != after.Content {
+		changes = append(changes, models.AuditChange{
+			Key: "content",
+			Old: fmt.Sprintf("%d 字", len([]rune(before.Content))),
+			New: fmt.Sprintf("%d 字", len([]rune(after.Content))),
+		})
+	}
+	return changes
+}
+
+// PostAuditTarget describes a post in the audit log.
+func PostAuditTarget(post *models.Post) string {
+	return fmt.Sprintf("文章 #%d《%s》", post.ID, post.Title)
+}
+
+func truncateAuditValue(value string) string {
+	value = strings.TrimSpace(value)
+	if runes := []rune(value); len(runes) > auditValueLimit {
+		return string(runes[:auditValueLimit]) + "…"
+	}
+	return value
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/utils"
+	"log"
+	"unicode/utf8"
+)
+
+// DefaultPassword is the password of a fresh installation. It must be
+// changed before the admin area can be used.
+const DefaultPassword = "admin"
+
+const minPasswordLength = 8
+
+var ErrPasswordTooShort = fmt.Errorf("密码至少需要 %d 个字符", minPasswordLength)
+
+// AuthService manages the admin password. Only an argon2id hash is stored;
+// backups are encrypted with a separate backup password so they do not
+// depend on the login password.
+type AuthService struct {
+	settingService *SettingService
+}
+
+func NewAuthService(settingService *SettingService) *AuthService {
+	return &AuthService{settingService: settingService}
+}
+
+// MigratePassword replaces a plaintext password setting with its hash. A
+// missing backup password is set to a random one, which the settings page
+// shows to the admin until they choose their own. The login password is
+// never reused for backups: backup files leave the server.
+func (s *AuthService) MigratePassword() error {
+	stored, _ := s.settingService.GetSetting(constants.SettingPassword)
+	backupPassword, _ := s.settingService.GetSetting(constants.SettingBackupPassword)
+
+	updates := make(map[string]string)
+	if !utils.IsPasswordHash(stored) {
+		if stored == "" {
+			stored = DefaultPassword
+		}
+		hash, err := utils.HashPassword(stored)
+		if err != nil {
+			return err
+		}
+		updates[constants.SettingPassword] = hash
+		updates[constants.SettingPasswordChangeRequired] = fmt.Sprint(stored == DefaultPassword)
+	}
+	if backupPassword == "" {
+		random := make([]byte, 12)
+		if _, err := rand.Read(random); err != nil {
+			return fmt.Errorf("生成备份密码失败: %w", err)
+		}
+		updates[constants.SettingBackupPassword] = hex.EncodeToString(random)
+		updates[constants.SettingBackupPasswordRandom] = "true"
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := s.settingService.UpdateSettings(updates); err != nil {
+		return fmt.Errorf("迁移管理员密码失败: %w", err)
+	}
+	if _, ok := updates[constants.SettingPassword]; ok {
+		log.Println("管理员密码已转换为哈希存储")
+	}
+	if _, ok := updates[constants.SettingBackupPassword]; ok {
+		log.Println("已生成随机备份密码，请在后台设置页面查看或修改")
+	}
+	return nil
+}
+
+// CheckPassword verifies a login attempt. A plaintext value, as found in a
+// restored old backup, is accepted once and migrated to a hash.
+func (s *AuthService) CheckPassword(password string) bool {
+	if password == "" {
+		return false
+	}
+	stored, _ := s.settingService.GetSetting(constants.SettingPassword)
+	if utils.IsPasswordHash(stored) {
+		ok, err := utils.VerifyPassword(stored, password)
+		if err != nil {
+			log.Printf("校验管理员密码失败: %v", err)
+		}
+		return ok
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(password)) != 1 {
+		return false
+	}
+	if err := s.MigratePassword(); err != nil {
+		log.Printf("%v", err)
+	}
+	return true
+}
+
+// ChangeRequired reports whether the default password is still in use.
+func (s *AuthService) ChangeRequired() bool {
+	required, _ := s.settingService.GetSetting(constants.SettingPasswordChangeRequired)
+	return required == "true"
+}
+
+// SetPassword validates and stores a new admin password.
+func (s *AuthService) SetPassword(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return ErrPasswordTooShort
+	}
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.settingService.UpdateSettings(map[string]string{
+		constants.SettingPassword:               hash,
+		constants.SettingPasswordChangeRequired: "false",
+	})
+}
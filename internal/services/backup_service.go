@@ -23,18 +23,61 @@ import (
 
 var ErrBackupNoChange = errors.New("数据无变化，无需备份")
 
+// backupCredentialSettings are the login credentials and session keys. They
+// are left out of backups, which are stored off the server, and a restored
+// backup cannot replace them.
+var backupCredentialSettings = []string{
+	constants.SettingPassword,
+	constants.SettingPasswordChangeRequired,
+	constants.SettingBackupPassword,
+	constants.SettingBackupPasswordRandom,
+	constants.SettingSessionSecret,
+	constants.SettingSessionSecretPrevious,
+	constants.SettingTOTPSecret,
+	constants.SettingTOTPLastStep,
+	constants.SettingTOTPRecoveryCodes,
+	constants.SettingWebAuthnUserID,
+}
+
+// StripBackupCredentials removes the login credentials and the keys generated
+// by the system from backup settings.
+func StripBackupCredentials(settings map[string]string) {
+	for _, key := range backupCredentialSettings {
+		delete(settings, key)
+	}
+	for key := range systemSettings {
+		delete(settings, key)
+	}
+}
+
 type BackupService struct {
 	PostService    *PostService
 	SettingService *SettingService
+	eventBus       *EventBus
 }
 
-func NewBackupService(postService *PostService, settingService *SettingService) *BackupService {
+func NewBackupService(postService *PostService, settingService *SettingService, eventBus *EventBus) *BackupService {
 	return &BackupService{
 		PostService:    postService,
 		SettingService: settingService,
+		eventBus:       eventBus,
 	}
 }
 
+// publishResult announces the outcome of a backup. Skipped backups, where
+// nothing changed since the last one, are not reported.
+func (s *BackupService) publishResult(target string, err error) {
+	if errors.Is(err, ErrBackupNoChange) {
+		return
+	}
+	event := Event{Type: EventBackupSucceeded, Data: map[string]string{"target": target}}
+	if err != nil {
+		event.Type = EventBackupFailed
+		event.Data["error"] = err.Error()
+	}
+	s.eventBus.Publish(event)
+}
+
 func (s *BackupService) generateBackupDataAndHash() (*models.SiteBackup, string, error) {
 	posts, err := s.PostService.GetAllPostsForBackup()
 	if err != nil {
@@ -48,6 +91,7 @@ func (s *BackupService) generateBackupDataAndHash() (*models.SiteBackup, string,
 
 	delete(settings, constants.SettingGithubLastBackupHash)
 	delete(settings, constants.SettingWebdavLastBackupHash)
+	StripBackupCredentials(settings)
 
 	backupData := &models.SiteBackup{
 		Posts:    posts,
@@ -76,6 +120,12 @@ func (s *BackupService) generateBackupDataAndHash() (*models.SiteBackup, string,
 }
 
 func (s *BackupService) BackupToGithub(repoName, branch, token string) error {
+	err := s.backupToGithub(repoName, branch, token)
+	s.publishResult("github", err)
+	return err
+}
+
+func (s *BackupService) backupToGithub(repoName, branch, token string) error {
 	backupData, newHash, err := s.generateBackupDataAndHash()
 	if err != nil {
 		return err
@@ -129,6 +179,12 @@ func (s *BackupService) BackupToGithub(repoName, branch, token string) error {
 }
 
 func (s *BackupService) BackupToWebdav(url, user, password string) error {
+	err := s.backupToWebdav(url, user, password)
+	s.publishResult("webdav", err)
+	return err
+}
+
+func (s *BackupService) backupToWebdav(url, user, password string) error {
 	backupData, newHash, err := s.generateBackupDataAndHash()
 	if err != nil {
 		return err
@@ -178,12 +234,12 @@ func (s *BackupService) BackupToWebdav(url, user, password string) error {
 }
 
 func (s *BackupService) createEncryptedBackup(backupData *models.SiteBackup) ([]byte, error) {
-	password, err := s.SettingService.GetSetting(constants.SettingPassword)
+	password, err := s.SettingService.GetSetting(constants.SettingBackupPassword)
 	if err != nil {
-		return nil, fmt.Errorf("获取站点密码失败: %w", err)
+		return nil, fmt.Errorf("获取备份密码失败: %w", err)
 	}
 	if password == "" {
-		return nil, fmt.Errorf("站点密码未设置，无法创建加密备份")
+		return nil, fmt.Errorf("备份密码未设置，无法创建加密备份")
 	}
 
 	jsonData, err := json.MarshalIndent(backupData, "", "  ")
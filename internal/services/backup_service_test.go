@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/json"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigratePasswordNeverReusesLoginPasswordForBackups(t *testing.T) {
+	db := newTestDB(t)
+	settingService := newTestSettings(t, db, map[string]string{
+		constants.SettingPassword:       "old-plaintext",
+		constants.SettingBackupPassword: "",
+	})
+	authService := NewAuthService(settingService)
+	if err := authService.MigratePassword(); err != nil {
+		t.Fatal(err)
+	}
+
+	backupPassword, _ := settingService.GetSetting(constants.SettingBackupPassword)
+	if backupPassword == "" || backupPassword == "old-plaintext" {
+		t.Errorf("应生成随机备份密码，实际 %q", backupPassword)
+	}
+	if random, _ := settingService.GetSetting(constants.SettingBackupPasswordRandom); random != "true" {
+		t.Error("随机生成的备份密码应标记出来，以便在设置页显示")
+	}
+	if !authService.CheckPassword("old-plaintext") {
+		t.Error("迁移后应能用原密码登录")
+	}
+}
+
+func TestBackupsLeaveOutCredentials(t *testing.T) {
+	db := newTestDB(t)
+	settingService := newTestSettings(t, db, map[string]string{
+		constants.SettingBackupPassword: "backup-secret",
+		constants.SettingTOTPSecret:     "JBSWY3DPEHPK3PXP",
+		constants.SettingSiteTitle:      "My Blog",
+		constants.SettingIndexNowKey:    "indexnow-key",
+	})
+	authService := NewAuthService(settingService)
+	if err := authService.SetPassword("current-password"); err != nil {
+		t.Fatal(err)
+	}
+	postService := NewPostService(repository.NewPostRepository(db), settingService, nil, NewEventBus())
+	backupService := NewBackupService(postService, settingService, NewEventBus())
+
+	backup, _, err := backupService.generateBackupDataAndHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range append(backupCredentialSettings, constants.SettingIndexNowKey) {
+		if _, ok := backup.Settings[key]; ok {
+			t.Errorf("备份中不应包含 %s", key)
+		}
+	}
+	if backup.Settings[constants.SettingSiteTitle] != "My Blog" {
+		t.Error("备份中应包含普通设置")
+	}
+
+	// 伪造的备份不能替换登录凭据
+	data, _ := json.Marshal(models.SiteBackup{Posts: []models.PostBackup{{Title: "Hello", Content: "Hi", PublishedAt: time.Now()}}, Settings: map[string]string{
+		constants.SettingPassword:               "attacker",
+		constants.SettingBackupPassword:         "attacker",
+		constants.SettingTOTPSecret:             "",
+		constants.SettingMetaWeblogPasswordHash: "attacker-hash",
+		constants.SettingActivityPubKey:         "attacker-key",
+		constants.SettingIndexNowKey:            "attacker",
+		constants.SettingSiteTitle:              "Restored",
+	}})
+	if _, err := postService.CreatePostsFromBackupStream(strings.NewReader(string(data))); err != nil {
+		t.Fatal(err)
+	}
+	if !authService.CheckPassword("current-password") || authService.CheckPassword("attacker") {
+		t.Error("恢复备份不应修改管理员密码")
+	}
+	if value, _ := settingService.GetSetting(constants.SettingBackupPassword); value != "backup-secret" {
+		t.Errorf("恢复备份不应修改备份密码，实际 %q", value)
+	}
+	if value, _ := settingService.GetSetting(constants.SettingTOTPSecret); value == "" {
+		t.Error("恢复备份不应关闭两步验证")
+	}
+	for _, key := range []string{constants.SettingMetaWeblogPasswordHash, constants.SettingActivityPubKey} {
+		if value, _ := settingService.GetSetting(key); value != "" {
+			t.Errorf("恢复备份不应写入系统生成的 %s，实际 %q", key, value)
+		}
+	}
+	if value, _ := settingService.GetSetting(constants.SettingIndexNowKey); value != "indexnow-key" {
+		t.Errorf("恢复备份不应修改 IndexNow 密钥，实际 %q", value)
+	}
+	if value, _ := settingService.GetSetting(constants.SettingSiteTitle); value != "Restored" {
+		t.Errorf("恢复备份应更新普通设置，实际 %q", value)
+	}
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"glog/internal/utils"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	deliveryMaxAttempts = 8                   // 超过该次数后放弃投递
+	deliveryBaseBackoff = time.Minute         // 首次重试的等待时间，之后指数增长
+	deliveryMaxBackoff  = 12 * time.Hour      // 单次等待的上限
+	deliveryBatchSize   = 50                  // 每轮处理的任务数
+	deliveryLogKeep     = 30 * 24 * time.Hour // 已完成任务在日志中保留的时间
+	deliveryTimeout     = 20 * time.Second
+	deliveryMaxErrorLen = 500
+)
+
+// ErrDeliveryPermanent marks a failure that retrying cannot fix, such as a
+// 4xx response. Handlers wrap it to stop further attempts.
+var ErrDeliveryPermanent = errors.New("投递被拒绝")
+
+// DeliveryHandler sends one job and returns the HTTP status code of the
+// response, if any.
+type DeliveryHandler func(job *models.DeliveryJob) (int, error)
+
+// DeliveryQueue is a persistent outbox for HTTP deliveries. Jobs are stored
+// in SQLite and retried with exponential backoff, so nothing is lost when the
+// remote end is down or the process restarts.
+type DeliveryQueue struct {
+	repo     *repository.DeliveryRepository
+	client   *http.Client
+	mu       sync.RWMutex
+	handlers map[string]DeliveryHandler
+
+	processMu sync.Mutex // 保证同一时间只有一轮投递在运行
+}
+
+func NewDeliveryQueue(repo *repository.DeliveryRepository) *DeliveryQueue {
+	return &DeliveryQueue{
+		repo:     repo,
+		client:   utils.NewPublicHTTPClient(deliveryTimeout),
+		handlers: make(map[string]DeliveryHandler),
+	}
+}
+
+// Client returns the HTTP client handlers should use for deliveries. Targets
+// of ActivityPub and Webmention come from remote servers, so the client
+// refuses to connect to addresses inside the local network.
+func (q *DeliveryQueue) Client() *http.Client {
+	return q.client
+}
+
+// Register sets the handler for a job kind.
+func (q *DeliveryQueue) Register(kind string, handler DeliveryHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = handler
+}
+
+// HasPending reports whether a matching job is still waiting, so callers can
+// avoid queueing the same delivery twice.
+func (q *DeliveryQueue) HasPending(kind, target, ref string) (bool, error) {
+	return q.repo.HasPending(kind, target, ref)
+}
+
+// Enqueue stores a job and starts processing in the background.
+func (q *DeliveryQueue) Enqueue(kind, target, payload, ref string) error {
+	job := &models.DeliveryJob{
+		Kind:          kind,
+		Target:        target,
+		Payload:       payload,
+		Ref:           ref,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := q.repo.Create(job); err != nil {
+		return fmt.Errorf("保存投递任务失败: %w", err)
+	}
+	go func() {
+		if err := q.ProcessDue(); err != nil {
+			log.Printf("处理投递队列失败: %v", err)
+		}
+	}()
+	return nil
+}
+
+// ProcessDue attempts every job that is due. It is safe to call concurrently;
+// overlapping calls return immediately.
+func (q *DeliveryQueue) ProcessDue() error {
+	if !q.processMu.TryLock() {
+		return nil
+	}
+	defer q.processMu.Unlock()
+
+	for {
+		jobs, err := q.repo.FindDue(time.Now(), deliveryBatchSize)
+		if err != nil {
+			return fmt.Errorf("获取投递任务失败: %w", err)
+		}
+		for i := range jobs {
+			q.attempt(&jobs[i])
+		}
+		if len(jobs) < deliveryBatchSize {
+			break
+		}
+	}
+
+	return q.repo.DeleteFinishedBefore(time.Now().Add(-deliveryLogKeep))
+}
+
+// List returns a page of jobs of the given kinds, newest first, and their total.
+func (q *DeliveryQueue) List(kinds []string, page, pageSize int) ([]models.DeliveryJob, int64, error) {
+	total, err := q.repo.Count(kinds)
+	if err != nil {
+		return nil, 0, fmt.Errorf("统计投递任务失败: %w", err)
+	}
+	jobs, err := q.repo.FindPage(kinds, page, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("获取投递任务失败: %w", err)
+	}
+	return jobs, total, nil
+}
+
+// Retry resets a job of one of the given kinds so it is attempted again
+// right away with a fresh attempt budget.
+func (q *DeliveryQueue) Retry(id uint, kinds []string) error {
+	job, err := q.repo.FindByID(id)
+	if err != nil {
+		return fmt.Errorf("投递任务不存在: %w", err)
+	}
+	known := false
+	for _, kind := range kinds {
+		if job.Kind == kind {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return errors.New("投递任务不存在")
+	}
+
+	job.Status = models.DeliveryPending
+	job.Attempts = 0
+	job.LastError = ""
+	job.NextAttemptAt = time.Now()
+	if err := q.repo.Update(job); err != nil {
+		return fmt.Errorf("更新投递任务失败: %w", err)
+	}
+	go func() {
+		if err := q.ProcessDue(); err != nil {
+			log.Printf("处理投递队列失败: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (q *DeliveryQueue) attempt(job *models.DeliveryJob) {
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	var code int
+	var err error
+	if !ok {
+		err = fmt.Errorf("%w: 未知的投递类型 %s", ErrDeliveryPermanent, job.Kind)
+	} else {
+		code, err = runDeliveryHandler(handler, job)
+	}
+
+	job.Attempts++
+	job.ResponseCode = code
+	switch {
+	case err == nil:
+		job.Status = models.DeliverySucceeded
+		job.LastError = ""
+	case errors.Is(err, ErrDeliveryPermanent) || job.Attempts >= deliveryMaxAttempts:
+		job.Status = models.DeliveryFailed
+		job.LastError = truncateError(err)
+	default:
+		job.LastError = truncateError(err)
+		job.NextAttemptAt = time.Now().Add(deliveryBackoff(job.Attempts))
+	}
+
+	if err := q.repo.Update(job); err != nil {
+		log.Printf("更新投递任务 ID %d 失败: %v", job.ID, err)
+	}
+}
+
+func runDeliveryHandler(handler DeliveryHandler, job *models.DeliveryJob) (code int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("投递任务 ID %d 发生 panic: %v\n%s", job.ID, r, debug.Stack())
+			err = fmt.Errorf("%w: 处理器异常", ErrDeliveryPermanent)
+		}
+	}()
+	return handler(job)
+}
+
+// deliveryBackoff returns the wait before the next attempt: 1m, 2m, 4m, ...
+func deliveryBackoff(attempts int) time.Duration {
+	backoff := deliveryBaseBackoff << (attempts - 1)
+	if backoff <= 0 || backoff > deliveryMaxBackoff {
+		backoff = deliveryMaxBackoff
+	}
+	return backoff
+}
+
+func truncateError(err error) string {
+	msg := []rune(err.Error())
+	if len(msg) > deliveryMaxErrorLen {
+		msg = msg[:deliveryMaxErrorLen]
+	}
+	return string(msg)
+}
+
+// CheckDeliveryResponse converts an HTTP response status into a delivery
+// error: 2xx succeeds, 4xx other than 408 and 429 fails permanently and
+// everything else is retried.
+func CheckDeliveryResponse(resp *http.Response) (int, error) {
+	code := resp.StatusCode
+	switch {
+	case code >= 200 && code < 300:
+		return code, nil
+	case code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests:
+		return code, fmt.Errorf("%w: 对方返回 %s", ErrDeliveryPermanent, resp.Status)
+	default:
+		return code, fmt.Errorf("对方返回 %s", resp.Status)
+	}
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	embeddingBatchSize   = 16   // 每次请求 Embedding API 的文章数
+	embeddingMaxRunes    = 6000 // 送入模型的文本上限，避免超出模型的 token 限制
+	semanticMaxResults   = 50   // 语义搜索最多返回的结果数
+	semanticMinScore     = 0.2  // 低于该相似度的结果视为不相关
+	relatedPostsMinScore = 0.3
+	// 缓存的查询向量条数，重复的搜索和翻页不再请求 Embedding API
+	queryCacheSize = 256
+)
+
+// ErrEmbeddingDisabled is returned when no embedding model is configured.
+var ErrEmbeddingDisabled = errors.New("未配置 Embedding 模型，语义搜索不可用")
+
+// EmbeddingService maintains per-post embedding vectors and answers semantic
+// queries with brute-force cosine similarity, which is plenty fast for the
+// few thousand posts a personal blog has.
+type EmbeddingService struct {
+	repo           *repository.EmbeddingRepository
+	postRepo       *repository.PostRepository
+	postService    *PostService
+	settingService *SettingService
+	aiService      *AIService
+
+	refreshMu sync.Mutex // 保证同一时间只有一个刷新任务在运行
+
+	indexMu    sync.RWMutex
+	index      map[uint][]float32 // post ID -> 归一化后的向量
+	indexModel string
+	indexValid bool
+
+	queryMu    sync.Mutex
+	queryCache map[string][]float32 // 模型 + 查询 -> 归一化后的向量
+	queryOrder []string             // 按写入顺序淘汰最早的查询
+}
+
+func NewEmbeddingService(repo *repository.EmbeddingRepository, postRepo *repository.PostRepository, postService *PostService, settingService *SettingService, aiService *AIService) *EmbeddingService {
+	return &EmbeddingService{
+		repo:           repo,
+		postRepo:       postRepo,
+		postService:    postService,
+		settingService: settingService,
+		aiService:      aiService,
+		queryCache:     make(map[string][]float32),
+	}
+}
+
+type embeddingConfig struct {
+	baseURL, token, model string
+}
+
+func (s *EmbeddingService) config() (embeddingConfig, error) {
+	settings, err := s.settingService.GetAllSettings()
+	if err != nil {
+		return embeddingConfig{}, err
+	}
+	cfg := embeddingConfig{
+		baseURL: settings[constants.SettingOpenAIBaseURL],
+		token:   settings[constants.SettingOpenAIToken],
+		model:   settings[constants.SettingOpenAIEmbeddingModel],
+	}
+	if cfg.model == "" || cfg.baseURL == "" || cfg.token == "" {
+		return cfg, ErrEmbeddingDisabled
+	}
+	return cfg, nil
+}
+
+// Enabled reports whether an embedding model is configured.
+func (s *EmbeddingService) Enabled() bool {
+	_, err := s.config()
+	return err == nil
+}
+
+// embeddingInput builds the text sent to the model for a post.
+func embeddingInput(post *models.Post) string {
+	text := post.Title + "\n\n" + post.Content
+	if runes := []rune(text); len(runes) > embeddingMaxRunes {
+		text = string(runes[:embeddingMaxRunes])
+	}
+	return text
+}
+
+func embeddingHash(model string, post *models.Post) string {
+	hash := sha256.Sum256([]byte(model + "\n" + embeddingInput(post)))
+	return hex.EncodeToString(hash[:])
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return v
+}
+
+// normalize scales v to unit length in place so that cosine similarity
+// reduces to a dot product.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, f := range v {
+		sum += float64(f) * float64(f)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+func dot(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// RefreshStale (re)generates embeddings for posts whose content changed since
+// their vector was computed and drops vectors of deleted posts. It is meant to
+// be run periodically from the background scheduler.
+func (s *EmbeddingService) RefreshStale() error {
+	cfg, err := s.config()
+	if err != nil {
+		return err
+	}
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	posts, err := s.postRepo.FindAllForEmbedding()
+	if err != nil {
+		return fmt.Errorf("获取文章失败: %w", err)
+	}
+	hashes, err := s.repo.FindHashes()
+	if err != nil {
+		return fmt.Errorf("获取向量失败: %w", err)
+	}
+
+	var stale []models.Post
+	existing := make(map[uint]bool, len(posts))
+	for _, post := range posts {
+		existing[post.ID] = true
+		if hashes[post.ID] != embeddingHash(cfg.model, &post) {
+			stale = append(stale, post)
+		}
+	}
+
+	var orphans []uint
+	for id := range hashes {
+		if !existing[id] {
+			orphans = append(orphans, id)
+		}
+	}
+	if err := s.repo.DeleteByPostIDs(orphans); err != nil {
+		return fmt.Errorf("清理失效向量失败: %w", err)
+	}
+
+	changed := len(orphans) > 0
+	defer func() {
+		if changed {
+			s.invalidateIndex()
+		}
+	}()
+
+	for start := 0; start < len(stale); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(stale) {
+			end = len(stale)
+		}
+		batch := stale[start:end]
+
+		inputs := make([]string, len(batch))
+		for i := range batch {
+			inputs[i] = embeddingInput(&batch[i])
+		}
+		vectors, err := s.aiService.CreateEmbeddings(inputs, cfg.baseURL, cfg.token, cfg.model)
+		if err != nil {
+			return err
+		}
+
+		for i := range batch {
+			err := s.repo.Upsert(&models.PostEmbedding{
+				PostID:      batch[i].ID,
+				UpdatedAt:   time.Now(),
+				Model:       cfg.model,
+				ContentHash: embeddingHash(cfg.model, &batch[i]),
+				Vector:      encodeVector(vectors[i]),
+			})
+			if err != nil {
+				return fmt.Errorf("保存文章 ID %d 的向量失败: %w", batch[i].ID, err)
+			}
+			changed = true
+		}
+	}
+
+	if len(stale) > 0 {
+		log.Printf("已更新 %d 篇文章的语义向量。", len(stale))
+	}
+	return nil
+}
+
+func (s *EmbeddingService) invalidateIndex() {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	s.indexValid = false
+	s.index = nil
+}
+
+// loadIndex returns the in-memory vector index for the given model, loading it
+// from the database on first use or after a refresh.
+func (s *EmbeddingService) loadIndex(model string) (map[uint][]float32, error) {
+	s.indexMu.RLock()
+	if s.indexValid && s.indexModel == model {
+		index := s.index
+		s.indexMu.RUnlock()
+		return index, nil
+	}
+	s.indexMu.RUnlock()
+
+	embeddings, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[uint][]float32, len(embeddings))
+	for _, e := range embeddings {
+		if e.Model != model {
+			continue
+		}
+		index[e.PostID] = normalize(decodeVector(e.Vector))
+	}
+
+	s.indexMu.Lock()
+	s.index, s.indexModel, s.indexValid = index, model, true
+	s.indexMu.Unlock()
+	return index, nil
+}
+
+type scoredPost struct {
+	id    uint
+	score float32
+}
+
+// rank scores every visible post against query and returns the best matches.
+func (s *EmbeddingService) rank(query []float32, model string, isLoggedIn bool, exclude uint, minScore float32, limit int) ([]uint, error) {
+	index, err := s.loadIndex(model)
+	if err != nil {
+		return nil, err
+	}
+	visibleIDs, err := s.postRepo.FindVisibleIDs(isLoggedIn)
+	if err != nil {
+		return nil, err
+	}
+
+	scored := make([]scoredPost, 0, len(visibleIDs))
+	for _, id := range visibleIDs {
+		vector, ok := index[id]
+		if !ok || id == exclude {
+			continue
+		}
+		if score := dot(query, vector); score >= minScore {
+			scored = append(scored, scoredPost{id: id, score: score})
+		}
+	}
+	sort.Slice(scored, func(i, j int) bool { return scored[i].score > scored[j].score })
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+
+	ids := make([]uint, len(scored))
+	for i, sp := range scored {
+		ids[i] = sp.id
+	}
+	return ids, nil
+}
+
+// queryVector returns the normalized embedding of a search query, calling the
+// embedding API only for queries that are not cached yet.
+func (s *EmbeddingService) queryVector(cfg embeddingConfig, query string) ([]float32, error) {
+	key := cfg.model + "\n" + query
+	s.queryMu.Lock()
+	vector, ok := s.queryCache[key]
+	s.queryMu.Unlock()
+	if ok {
+		return vector, nil
+	}
+
+	vectors, err := s.aiService.CreateEmbeddings([]string{query}, cfg.baseURL, cfg.token, cfg.model)
+	if err != nil {
+		return nil, err
+	}
+	vector = normalize(vectors[0])
+
+	s.queryMu.Lock()
+	defer s.queryMu.Unlock()
+	if _, ok := s.queryCache[key]; !ok {
+		if len(s.queryOrder) >= queryCacheSize {
+			delete(s.queryCache, s.queryOrder[0])
+			s.queryOrder = s.queryOrder[1:]
+		}
+		s.queryOrder = append(s.queryOrder, key)
+	}
+	s.queryCache[key] = vector
+	return vector, nil
+}
+
+// SemanticSearchPage returns a page of posts ranked by similarity to the query.
+func (s *EmbeddingService) SemanticSearchPage(query string, page, pageSize int, isLoggedIn bool) ([]models.RenderedPost, int, error) {
+	cfg, err := s.config()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	vector, err := s.queryVector(cfg, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids, err := s.rank(vector, cfg.model, isLoggedIn, 0, semanticMinScore, semanticMaxResults)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := (page - 1) * pageSize
+	if start < 0 || start >= len(ids) {
+		return []models.RenderedPost{}, len(ids), nil
+	}
+	end := start + pageSize
+	if end > len(ids) {
+		end = len(ids)
+	}
+
+	posts, err := s.postService.GetPostsByIDs(ids[start:end], isLoggedIn)
+	if err != nil {
+		return nil, 0, err
+	}
+	return posts, len(ids), nil
+}
+
+// RelatedPosts returns the posts most similar to the given one. It never calls
+// the embedding API and returns nothing when the post has no vector yet.
+func (s *EmbeddingService) RelatedPosts(postID uint, limit int, isLoggedIn bool) ([]models.RenderedPost, error) {
+	cfg, err := s.config()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := s.loadIndex(cfg.model)
+	if err != nil {
+		return nil, err
+	}
+	vector, ok := index[postID]
+	if !ok {
+		return nil, nil
+	}
+
+	ids, err := s.rank(vector, cfg.model, isLoggedIn, postID, relatedPostsMinScore, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.postService.GetPostsByIDs(ids, isLoggedIn)
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"encoding/json"
+	"glog/internal/constants"
+	"glog/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockEmbeddingServer answers the OpenAI embeddings API with one dimension
+// per keyword, and counts how often each input was requested.
+func mockEmbeddingServer(t *testing.T) (*httptest.Server, func(input string) int) {
+	t.Helper()
+	keywords := []string{"cat", "dog", "fish"}
+	var mu sync.Mutex
+	calls := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" || r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var req embeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var resp embeddingResponse
+		for i, input := range req.Input {
+			mu.Lock()
+			calls[input]++
+			mu.Unlock()
+			vector := make([]float32, len(keywords))
+			for k, keyword := range keywords {
+				if strings.Contains(strings.ToLower(input), keyword) {
+					vector[k] = 1
+				}
+			}
+			resp.Data = append(resp.Data, struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}{Index: i, Embedding: vector})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func(input string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls[input]
+	}
+}
+
+func TestSemanticSearchAgainstMockEmbeddingServer(t *testing.T) {
+	server, calls := mockEmbeddingServer(t)
+	db := newTestDB(t)
+	settingService := newTestSettings(t, db, map[string]string{
+		constants.SettingOpenAIBaseURL:        server.URL,
+		constants.SettingOpenAIToken:          "test-token",
+		constants.SettingOpenAIEmbeddingModel: "test-model",
+	})
+	postRepo := repository.NewPostRepository(db)
+	aiService := NewAIService()
+	postService := NewPostService(postRepo, settingService, aiService, NewEventBus())
+	embeddingService := NewEmbeddingService(repository.NewEmbeddingRepository(db), postRepo, postService, settingService, aiService)
+
+	publishedAt := time.Now().Add(-time.Hour)
+	catPost, _, err := postService.CreatePost("Cat care", "How to feed a cat.", false, false, false, false, publishedAt)
+	if err != nil {
+		t.Fatalf("创建文章失败: %v", err)
+	}
+	if _, _, err := postService.CreatePost("Dog walks", "Walking the dog.", false, false, false, false, publishedAt); err != nil {
+		t.Fatalf("创建文章失败: %v", err)
+	}
+	if _, _, err := postService.CreatePost("Secret cat", "A private cat.", true, false, false, false, publishedAt); err != nil {
+		t.Fatalf("创建文章失败: %v", err)
+	}
+
+	if err := embeddingService.RefreshStale(); err != nil {
+		t.Fatalf("生成向量失败: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		posts, total, err := embeddingService.SemanticSearchPage("cat", 1, 10, false)
+		if err != nil {
+			t.Fatalf("语义搜索失败: %v", err)
+		}
+		if total != 1 || len(posts) != 1 || posts[0].ID != catPost.ID {
+			t.Fatalf("访客搜索 cat 应只返回公开的猫文章，实际 total=%d posts=%v", total, posts)
+		}
+	}
+	if n := calls("cat"); n != 1 {
+		t.Errorf("重复的查询应命中缓存，Embedding API 被请求了 %d 次", n)
+	}
+
+	posts, total, err := embeddingService.SemanticSearchPage("cat", 1, 10, true)
+	if err != nil {
+		t.Fatalf("语义搜索失败: %v", err)
+	}
+	if total != 2 || len(posts) != 2 {
+		t.Errorf("登录后应能搜到私密文章，实际 total=%d", total)
+	}
+
+	if _, total, err := embeddingService.SemanticSearchPage("fish", 1, 10, false); err != nil || total != 0 {
+		t.Errorf("不相关的查询不应返回结果，实际 total=%d err=%v", total, err)
+	}
+}
+
+func TestQueryVectorCacheEvictsOldestQuery(t *testing.T) {
+	server, calls := mockEmbeddingServer(t)
+	embeddingService := NewEmbeddingService(nil, nil, nil, nil, NewAIService())
+	cfg := embeddingConfig{baseURL: server.URL, token: "test-token", model: "test-model"}
+
+	for i := 0; i <= queryCacheSize; i++ {
+		query := "query " + strings.Repeat("x", i)
+		if _, err := embeddingService.queryVector(cfg, query); err != nil {
+			t.Fatalf("获取查询向量失败: %v", err)
+		}
+	}
+	if len(embeddingService.queryCache) != queryCacheSize {
+		t.Fatalf("缓存应保持 %d 条，实际 %d 条", queryCacheSize, len(embeddingService.queryCache))
+	}
+	if _, err := embeddingService.queryVector(cfg, "query "); err != nil {
+		t.Fatalf("获取查询向量失败: %v", err)
+	}
+	if n := calls("query "); n != 2 {
+		t.Errorf("最早的查询应已被淘汰并重新请求，实际请求了 %d 次", n)
+	}
+}
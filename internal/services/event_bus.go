@@ -0,0 +1,70 @@
+package services
+
+import (
+	"glog/internal/models"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Event types published on the EventBus.
+const (
+	EventPostCreated   = "post.created"
+	EventPostUpdated   = "post.updated"
+	EventPostPublished = "post.published" // 文章首次对所有人可见，包括定时发布到期
+	EventPostDeleted   = "post.deleted"
+
+	EventBackupSucceeded = "backup.succeeded"
+	EventBackupFailed    = "backup.failed"
+)
+
+// Event describes something that happened to the site's content.
+type Event struct {
+	Type string
+	Time time.Time
+	Post *models.Post      // 文章事件的快照，删除事件中为删除前的内容
+	Data map[string]string // 其他事件的附加信息，如备份目标和错误
+}
+
+// EventBus fans events out to subscribers such as federation, notifications
+// and webhooks. Subscribers run asynchronously so a slow consumer never
+// blocks the request that triggered the event.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers []func(Event)
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers fn to be called for every published event.
+func (b *EventBus) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, fn)
+}
+
+// Publish delivers the event to all subscribers in the background.
+func (b *EventBus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	subscribers := make([]func(Event), len(b.subscribers))
+	copy(subscribers, b.subscribers)
+	b.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		go func(fn func(Event)) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("处理事件 %s 时发生 panic: %v\n%s", event.Type, r, debug.Stack())
+				}
+			}()
+			fn(event)
+		}(fn)
+	}
+}
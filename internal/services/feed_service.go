@@ -0,0 +1,372 @@
+package services
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/repository"
+	"glog/internal/utils"
+	"log"
+	"strings"
+	"time"
+)
+
+const feedItemLimit = 20
+
+// FeedService builds RSS 2.0, Atom 1.0 and JSON Feed 1.1 documents from the
+// latest public posts.
+type FeedService struct {
+	postRepo       *repository.PostRepository
+	settingService *SettingService
+}
+
+func NewFeedService(postRepo *repository.PostRepository, settingService *SettingService) *FeedService {
+	return &FeedService{postRepo: postRepo, settingService: settingService}
+}
+
+// Hubs returns the WebSub hubs the feeds point subscribers to.
+func (s *FeedService) Hubs() []string {
+	raw, _ := s.settingService.GetSetting(constants.SettingWebSubHubs)
+	return ParseHubs(raw)
+}
+
+// feedEntry is the format-independent representation of a feed item.
+type feedEntry struct {
+	title     string
+	url       string
+	summary   string
+	content   string // HTML，仅在全文输出时非空
+	image     string
+	published time.Time
+	updated   time.Time
+}
+
+type feedData struct {
+	title        string
+	description  string
+	author       string
+	homeURL      string
+	hubs         []string // WebSub hubs
+	entries      []feedEntry
+	lastModified time.Time
+}
+
+func (s *FeedService) load(baseURL string) (*feedData, error) {
+	settings, err := s.settingService.GetAllSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	// 订阅源只包含公开且已到发布时间的文章
+	posts, err := s.postRepo.FindPageWithContent(1, feedItemLimit, false)
+	if err != nil {
+		return nil, fmt.Errorf("获取文章失败: %w", err)
+	}
+
+	data := &feedData{
+		title:       settings[constants.SettingSiteTitle],
+		description: settings[constants.SettingSiteDescription],
+		author:      settings[constants.SettingSiteAuthor],
+		homeURL:     baseURL + "/",
+		hubs:        ParseHubs(settings[constants.SettingWebSubHubs]),
+	}
+	if data.title == "" {
+		data.title = "Glog"
+	}
+	if data.author == "" {
+		data.author = data.title
+	}
+	fullContent := settings[constants.SettingFeedContent] != "summary"
+
+	for _, post := range posts {
+		entry := feedEntry{
+			title:     post.Title,
+			url:       baseURL + "/post/" + post.Slug,
+			summary:   post.Excerpt,
+			published: post.PublishedAt,
+			updated:   post.UpdatedAt,
+		}
+		if entry.summary == "" {
+			entry.summary = utils.Summarize(post.Content, 200)
+		}
+		if post.Cover != "" {
+			entry.image = post.Cover
+			if strings.HasPrefix(post.Cover, "/") {
+				entry.image = baseURL + post.Cover
+			}
+		}
+		if fullContent {
+			content, err := utils.AbsolutizeURLs(post.ContentHTML, baseURL)
+			if err != nil {
+				log.Printf("处理订阅源文章内容失败 for post ID %d: %v", post.ID, err)
+				content = post.ContentHTML
+			}
+			entry.content = content
+		}
+		if entry.updated.Before(entry.published) {
+			entry.updated = entry.published
+		}
+		if entry.updated.After(data.lastModified) {
+			data.lastModified = entry.updated
+		}
+		data.entries = append(data.entries, entry)
+	}
+	return data, nil
+}
+
+// --- RSS 2.0 ---
+
+type rssFeed struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLinks     []rssLink `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+	Content     *cdata  `xml:"content:encoded,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+// BuildRSS returns the RSS 2.0 document and the time of its newest change.
+func (s *FeedService) BuildRSS(baseURL string) ([]byte, time.Time, error) {
+	data, err := s.load(baseURL)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	feed := rssFeed{
+		Version:   "2.0",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		Channel: rssChannel{
+			Title:       data.title,
+			Link:        data.homeURL,
+			Description: data.description,
+			Language:    "zh-CN",
+			AtomLinks:   []rssLink{{Href: baseURL + "/feed.xml", Rel: "self", Type: "application/rss+xml"}},
+		},
+	}
+	for _, hub := range data.hubs {
+		feed.Channel.AtomLinks = append(feed.Channel.AtomLinks, rssLink{Href: hub, Rel: "hub"})
+	}
+	if !data.lastModified.IsZero() {
+		feed.Channel.LastBuildDate = data.lastModified.Format(time.RFC1123Z)
+	}
+	for _, entry := range data.entries {
+		item := rssItem{
+			Title:       entry.title,
+			Link:        entry.url,
+			GUID:        rssGUID{IsPermaLink: "true", Value: entry.url},
+			PubDate:     entry.published.Format(time.RFC1123Z),
+			Description: entry.summary,
+		}
+		if entry.content != "" {
+			item.Content = &cdata{Value: entry.content}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("生成 RSS 失败: %w", err)
+	}
+	return append([]byte(xml.Header), body...), data.lastModified, nil
+}
+
+// --- Atom 1.0 ---
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   atomAuthor  `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string    `xml:"title"`
+	ID        string    `xml:"id"`
+	Link      atomLink  `xml:"link"`
+	Published string    `xml:"published"`
+	Updated   string    `xml:"updated"`
+	Summary   atomText  `xml:"summary"`
+	Content   *atomText `xml:"content,omitempty"`
+}
+
+// BuildAtom returns the Atom 1.0 document and the time of its newest change.
+func (s *FeedService) BuildAtom(baseURL string) ([]byte, time.Time, error) {
+	data, err := s.load(baseURL)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	updated := data.lastModified
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	feed := atomFeed{
+		Title:    data.title,
+		Subtitle: data.description,
+		ID:       data.homeURL,
+		Updated:  updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: data.homeURL, Rel: "alternate", Type: "text/html"},
+			{Href: baseURL + "/atom.xml", Rel: "self", Type: "application/atom+xml"},
+		},
+		Author: atomAuthor{Name: data.author},
+	}
+	for _, hub := range data.hubs {
+		feed.Links = append(feed.Links, atomLink{Href: hub, Rel: "hub"})
+	}
+	for _, entry := range data.entries {
+		item := atomEntry{
+			Title:     entry.title,
+			ID:        entry.url,
+			Link:      atomLink{Href: entry.url, Rel: "alternate", Type: "text/html"},
+			Published: entry.published.Format(time.RFC3339),
+			Updated:   entry.updated.Format(time.RFC3339),
+			Summary:   atomText{Type: "text", Value: entry.summary},
+		}
+		if entry.content != "" {
+			item.Content = &atomText{Type: "html", Value: entry.content}
+		}
+		feed.Entries = append(feed.Entries, item)
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("生成 Atom 失败: %w", err)
+	}
+	return append([]byte(xml.Header), body...), data.lastModified, nil
+}
+
+// --- JSON Feed 1.1 ---
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description,omitempty"`
+	Language    string           `json:"language"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Hubs        []jsonFeedHub    `json:"hubs,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedHub struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	ContentHTML   string `json:"content_html,omitempty"`
+	ContentText   string `json:"content_text,omitempty"`
+	Summary       string `json:"summary,omitempty"`
+	Image         string `json:"image,omitempty"`
+	DatePublished string `json:"date_published"`
+	DateModified  string `json:"date_modified"`
+}
+
+// BuildJSONFeed returns the JSON Feed 1.1 document and the time of its newest change.
+func (s *FeedService) BuildJSONFeed(baseURL string) ([]byte, time.Time, error) {
+	data, err := s.load(baseURL)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       data.title,
+		HomePageURL: data.homeURL,
+		FeedURL:     baseURL + "/feed.json",
+		Description: data.description,
+		Language:    "zh-CN",
+		Authors:     []jsonFeedAuthor{{Name: data.author}},
+		Items:       []jsonFeedItem{},
+	}
+	for _, hub := range data.hubs {
+		feed.Hubs = append(feed.Hubs, jsonFeedHub{Type: "WebSub", URL: hub})
+	}
+	for _, entry := range data.entries {
+		item := jsonFeedItem{
+			ID:            entry.url,
+			URL:           entry.url,
+			Title:         entry.title,
+			Summary:       entry.summary,
+			Image:         entry.image,
+			DatePublished: entry.published.Format(time.RFC3339),
+			DateModified:  entry.updated.Format(time.RFC3339),
+		}
+		// JSON Feed 要求每个条目至少包含 content_html 或 content_text 之一
+		if entry.content != "" {
+			item.ContentHTML = entry.content
+		} else {
+			item.ContentText = entry.summary
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	body, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("生成 JSON Feed 失败: %w", err)
+	}
+	return body, data.lastModified, nil
+}
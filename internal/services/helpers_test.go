@@ -0,0 +1,37 @@
+package services
+
+import (
+	"glog/internal/repository"
+	"glog/internal/utils"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB opens a migrated and seeded database in a temporary directory.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "glog.db"))
+	db, err := utils.InitDatabase()
+	if err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// newTestSettings returns a setting service on db with the given settings
+// applied on top of the defaults.
+func newTestSettings(t *testing.T, db *gorm.DB, settings map[string]string) *SettingService {
+	t.Helper()
+	settingService := NewSettingService(repository.NewSettingRepository(db))
+	if err := settingService.UpdateSettings(settings); err != nil {
+		t.Fatalf("保存设置失败: %v", err)
+	}
+	return settingService
+}
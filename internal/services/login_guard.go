@@ -0,0 +1,175 @@
+package services
+
+import (
+	"fmt"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LoginSourceWeb = "login"
+	// LoginSourceTwoFactor is the second step of a login with 2FA enabled.
+	LoginSourceTwoFactor = "2fa"
+	LoginSourcePasskey   = "passkey"
+	LoginSourceOIDC      = "oidc"
+	// LoginSourcePasswordChange is the current password asked for when
+	// changing it.
+	LoginSourcePasswordChange = "password"
+	// LoginSourcePasswordConfirm is the password asked for before sensitive
+	// changes such as adding a passkey.
+	LoginSourcePasswordConfirm = "confirm"
+	// LoginSourceMetaWeblog is the editor password checked by /xmlrpc.
+	LoginSourceMetaWeblog = "xmlrpc"
+
+	// 同一 IP 在窗口内失败超过 loginFreeFailures 次后，每次失败的等待时间翻倍
+	loginFailureWindow   = 15 * time.Minute
+	loginFreeFailures    = 3
+	loginBackoffBase     = time.Second
+	loginBackoffMax      = 5 * time.Minute
+	loginLockoutFailures = 10
+	loginLockoutDuration = 15 * time.Minute
+
+	// 所有 IP 的失败总数过多时（分布式猜测）整体放慢，但不完全锁死，以免管理员被拒之门外
+	globalFreeFailures = 50
+	globalBackoffMax   = 30 * time.Second
+
+	loginAttemptRetention = 30 * 24 * time.Hour
+)
+
+// LoginBlockedError is returned while an address must wait before trying again.
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("失败次数过多，登录已暂时锁定，请在 %d 分钟后重试", int(e.RetryAfter.Minutes())+1)
+	}
+	return fmt.Sprintf("尝试过于频繁，请在 %d 秒后重试", int(e.RetryAfter.Seconds())+1)
+}
+
+// LoginGuard throttles password guesses per client IP and globally, and keeps
+// the log of attempts shown in the admin area.
+type LoginGuard struct {
+	repo *repository.LoginAttemptRepository
+	// 串行处理认证，避免并发请求在失败被记录前绕过计数，同时限制 argon2 的内存占用
+	mu sync.Mutex
+}
+
+func NewLoginGuard(repo *repository.LoginAttemptRepository) *LoginGuard {
+	return &LoginGuard{repo: repo}
+}
+
+// Attempt runs verify unless ip has to wait, and records the outcome. The
+// returned error is a *LoginBlockedError when the attempt was refused.
+func (g *LoginGuard) Attempt(ip, userAgent, source string, verify func() bool) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.check(ip, time.Now()); err != nil {
+		return false, err
+	}
+
+	ok := verify()
+	attempt := &models.LoginAttempt{IP: ip, UserAgent: userAgent, Source: source, Success: ok}
+	if !ok {
+		switch source {
+		case LoginSourceTwoFactor:
+			attempt.Reason = "验证码错误"
+		case LoginSourcePasskey:
+			attempt.Reason = "通行密钥验证失败"
+		case LoginSourceOIDC:
+			attempt.Reason = "单点登录验证失败"
+		case LoginSourceMetaWeblog:
+			attempt.Reason = "写作客户端密码错误"
+		default:
+			attempt.Reason = "密码错误"
+		}
+	}
+	if err := g.repo.Create(attempt); err != nil {
+		log.Printf("记录登录尝试失败: %v", err)
+	}
+	return ok, nil
+}
+
+func (g *LoginGuard) check(ip string, now time.Time) error {
+	since := now.Add(-loginFailureWindow)
+	// 成功登录后重新计数
+	if lastSuccess, err := g.repo.LastSuccess(ip); err == nil && lastSuccess.After(since) {
+		since = lastSuccess
+	}
+
+	var until time.Time
+	locked := false
+	failures, last, err := g.repo.RecentFailures(ip, since)
+	if err != nil {
+		log.Printf("统计登录失败次数失败: %v", err)
+	}
+	switch {
+	case failures >= loginLockoutFailures:
+		until, locked = last.Add(loginLockoutDuration), true
+	case failures >= loginFreeFailures:
+		until = last.Add(backoff(failures-loginFreeFailures, loginBackoffMax))
+	}
+
+	globalFailures, globalLast, err := g.repo.RecentFailures("", now.Add(-loginFailureWindow))
+	if err != nil {
+		log.Printf("统计登录失败次数失败: %v", err)
+	}
+	if globalFailures >= globalFreeFailures {
+		if globalUntil := globalLast.Add(backoff((globalFailures-globalFreeFailures)/10, globalBackoffMax)); globalUntil.After(until) {
+			until, locked = globalUntil, false
+		}
+	}
+
+	if now.Before(until) {
+		return &LoginBlockedError{RetryAfter: until.Sub(now), Locked: locked}
+	}
+	return nil
+}
+
+func backoff(step int64, max time.Duration) time.Duration {
+	delay := loginBackoffBase
+	for i := int64(0); i < step && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+func (g *LoginGuard) List(page, pageSize int) ([]models.LoginAttempt, int64, error) {
+	return g.repo.FindPage(page, pageSize)
+}
+
+// Cleanup deletes old attempts; it runs as a background job.
+func (g *LoginGuard) Cleanup() error {
+	count, err := g.repo.DeleteBefore(time.Now().Add(-loginAttemptRetention))
+	if err != nil {
+		return fmt.Errorf("清理登录记录失败: %w", err)
+	}
+	if count > 0 {
+		log.Printf("已清理 %d 条过期登录记录", count)
+	}
+	return nil
+}
+
+// ParseTrustedProxies parses the trusted proxy setting: IP addresses or CIDR
+// ranges separated by whitespace or commas.
+func ParseTrustedProxies(raw string) ([]string, error) {
+	var proxies []string
+	for _, entry := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t' }) {
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return nil, fmt.Errorf("无效的代理地址: %s", entry)
+		}
+		proxies = append(proxies, entry)
+	}
+	return proxies, nil
+}
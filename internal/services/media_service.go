@@ -0,0 +1,86 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MediaMaxBytes is the largest file accepted by the upload endpoints.
+const MediaMaxBytes = 20 << 20
+
+var (
+	ErrMediaTooLarge = errors.New("文件过大")
+	ErrMediaType     = errors.New("不支持的文件类型")
+)
+
+// mediaTypes maps the sniffed content type of an accepted upload to the
+// extension it is stored with. SVG and HTML are refused because they could
+// run scripts on the site's origin.
+var mediaTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/bmp":       ".bmp",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+	"application/pdf": ".pdf",
+}
+
+// MediaService stores uploaded files under the data directory. Files are
+// served from /uploads/ and organised by year and month.
+type MediaService struct {
+	dir string
+}
+
+func NewMediaService(dir string) *MediaService {
+	return &MediaService{dir: dir}
+}
+
+// Dir returns the directory uploads are stored in.
+func (s *MediaService) Dir() string {
+	return s.dir
+}
+
+// Save stores data and returns its site-relative URL, e.g.
+// /uploads/2024/05/3f9c….png. The extension is derived from the content,
+// not from the client-supplied name.
+func (s *MediaService) Save(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", fmt.Errorf("%w: 文件为空", ErrMediaType)
+	}
+	if len(data) > MediaMaxBytes {
+		return "", ErrMediaTooLarge
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	ext, ok := mediaTypes[contentType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrMediaType, contentType)
+	}
+
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	month := time.Now().Format("2006/01")
+	name := hex.EncodeToString(random) + ext
+
+	dir := filepath.Join(s.dir, filepath.FromSlash(month))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("创建上传目录失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return "", fmt.Errorf("保存文件失败: %w", err)
+	}
+	return path.Join("/uploads", month, name), nil
+}
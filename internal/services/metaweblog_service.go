@@ -0,0 +1,171 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMetaWeblogAuth is returned when the MetaWeblog password is wrong or none is set up.
+	ErrMetaWeblogAuth     = errors.New("用户名或密码错误")
+	ErrMetaWeblogNotFound = errors.New("文章不存在")
+	ErrMetaWeblogEmpty    = errors.New("文章内容不能为空")
+)
+
+// MetaWeblogService backs the XML-RPC endpoint used by desktop blog editors.
+// Editors authenticate with a generated password that is separate from the
+// admin password; only its SHA-256 hash is stored.
+type MetaWeblogService struct {
+	postService    *PostService
+	settingService *SettingService
+	mediaService   *MediaService
+}
+
+// MetaWeblogPost is the editable part of a post as sent by an editor.
+type MetaWeblogPost struct {
+	Title       string
+	Content     string
+	Status      string // publish、draft、private，留空时由 publish 参数决定
+	PublishedAt time.Time
+}
+
+func NewMetaWeblogService(postService *PostService, settingService *SettingService, mediaService *MediaService) *MetaWeblogService {
+	return &MetaWeblogService{postService: postService, settingService: settingService, mediaService: mediaService}
+}
+
+func hashCredential(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// HasCredential reports whether a MetaWeblog password has been generated.
+func (s *MetaWeblogService) HasCredential() bool {
+	hash, _ := s.settingService.GetSetting(constants.SettingMetaWeblogPasswordHash)
+	return hash != ""
+}
+
+// GenerateCredential creates a new password, replacing any previous one. The
+// plaintext is returned once and never stored.
+func (s *MetaWeblogService) GenerateCredential() (string, error) {
+	random := make([]byte, 18)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("生成密码失败: %w", err)
+	}
+	password := hex.EncodeToString(random)
+	if err := s.settingService.UpdateSettings(map[string]string{constants.SettingMetaWeblogPasswordHash: hashCredential(password)}); err != nil {
+		return "", fmt.Errorf("保存密码失败: %w", err)
+	}
+	return password, nil
+}
+
+// RevokeCredential removes the password, which disables the endpoint.
+func (s *MetaWeblogService) RevokeCredential() error {
+	return s.settingService.UpdateSettings(map[string]string{constants.SettingMetaWeblogPasswordHash: ""})
+}
+
+// Authenticate checks the password supplied by an editor. The user name is
+// not checked since the blog has a single author.
+func (s *MetaWeblogService) Authenticate(password string) error {
+	hash, _ := s.settingService.GetSetting(constants.SettingMetaWeblogPasswordHash)
+	if hash == "" || password == "" {
+		return ErrMetaWeblogAuth
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashCredential(password))) != 1 {
+		return ErrMetaWeblogAuth
+	}
+	return nil
+}
+
+// isPrivate maps the editor's status and publish flag to the post's privacy.
+// Glog has no drafts, so unpublished posts are saved as private.
+func (p MetaWeblogPost) isPrivate(publish bool) bool {
+	switch p.Status {
+	case "publish":
+		return false
+	case "draft", "private", "pending":
+		return true
+	}
+	return !publish
+}
+
+// NewPost creates a post and returns its ID.
+func (s *MetaWeblogService) NewPost(input MetaWeblogPost, publish bool) (uint, error) {
+	if strings.TrimSpace(input.Content) == "" {
+		return 0, ErrMetaWeblogEmpty
+	}
+	publishedAt := input.PublishedAt
+	if publishedAt.IsZero() {
+		publishedAt = time.Now()
+	}
+	post, _, err := s.postService.CreatePost(input.Title, input.Content, input.isPrivate(publish), false, false, false, publishedAt)
+	if err != nil {
+		return 0, fmt.Errorf("创建文章失败: %w", err)
+	}
+	return post.ID, nil
+}
+
+// EditPost replaces the title and content of a post. Fields the editor did
+// not send keep their current values.
+func (s *MetaWeblogService) EditPost(id uint, input MetaWeblogPost, publish bool) error {
+	post, err := s.postService.GetPostByID(id)
+	if err != nil {
+		return ErrMetaWeblogNotFound
+	}
+	if strings.TrimSpace(input.Content) == "" {
+		return ErrMetaWeblogEmpty
+	}
+	publishedAt := input.PublishedAt
+	if publishedAt.IsZero() {
+		publishedAt = post.PublishedAt
+	}
+	if _, _, err := s.postService.UpdatePost(id, input.Title, input.Content, input.isPrivate(publish), post.NoIndex, false, false, publishedAt); err != nil {
+		return fmt.Errorf("更新文章失败: %w", err)
+	}
+	return nil
+}
+
+func (s *MetaWeblogService) GetPost(id uint) (*models.Post, error) {
+	post, err := s.postService.GetPostByID(id)
+	if err != nil {
+		return nil, ErrMetaWeblogNotFound
+	}
+	return post, nil
+}
+
+// RecentPosts returns the latest posts including private and scheduled ones.
+func (s *MetaWeblogService) RecentPosts(limit int) ([]models.Post, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	return s.postService.GetRecentPostsWithContent(limit)
+}
+
+func (s *MetaWeblogService) DeletePost(id uint) error {
+	if _, err := s.postService.GetPostByID(id); err != nil {
+		return ErrMetaWeblogNotFound
+	}
+	return s.postService.DeletePost(id)
+}
+
+// NewMediaObject stores an uploaded file and returns its site-relative URL.
+func (s *MetaWeblogService) NewMediaObject(data []byte) (string, error) {
+	return s.mediaService.Save(data)
+}
+
+// ParsePostID converts the string post ID used by MetaWeblog.
+func ParsePostID(raw string) (uint, error) {
+	id, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+	if err != nil || id == 0 {
+		return 0, ErrMetaWeblogNotFound
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"glog/internal/models"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMicropubInvalid maps to the invalid_request error of the spec.
+	ErrMicropubInvalid  = errors.New("invalid_request")
+	ErrMicropubNotFound = errors.New("文章不存在")
+)
+
+// MicropubProperties holds microformats2 properties, each a list of values
+// that are either strings or nested objects such as {"html": "..."}.
+type MicropubProperties map[string][]interface{}
+
+// MicropubService maps Micropub h-entry requests onto posts.
+type MicropubService struct {
+	postService *PostService
+}
+
+func NewMicropubService(postService *PostService) *MicropubService {
+	return &MicropubService{postService: postService}
+}
+
+func (p MicropubProperties) first(name string) (interface{}, bool) {
+	if values := p[name]; len(values) > 0 {
+		return values[0], true
+	}
+	return nil, false
+}
+
+func (p MicropubProperties) firstString(name string) string {
+	value, _ := p.first(name)
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if s, ok := v["value"].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// content returns the post body. HTML content is kept as is, since the
+// Markdown renderer passes raw HTML through.
+func (p MicropubProperties) content() string {
+	value, _ := p.first("content")
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if html, ok := v["html"].(string); ok {
+			return html
+		}
+		if text, ok := v["value"].(string); ok {
+			return text
+		}
+	}
+	return ""
+}
+
+// photos renders photo properties as Markdown images.
+func (p MicropubProperties) photos() string {
+	var images []string
+	for _, value := range p["photo"] {
+		var src, alt string
+		switch v := value.(type) {
+		case string:
+			src = v
+		case map[string]interface{}:
+			src, _ = v["value"].(string)
+			alt, _ = v["alt"].(string)
+		}
+		if src != "" {
+			images = append(images, fmt.Sprintf("![%s](%s)", alt, src))
+		}
+	}
+	return strings.Join(images, "\n\n")
+}
+
+// isPrivate treats drafts and private or unlisted visibility as private,
+// since Glog has no separate draft state.
+func (p MicropubProperties) isPrivate() bool {
+	status := p.firstString("post-status")
+	visibility := p.firstString("visibility")
+	return status == "draft" || visibility == "private" || visibility == "unlisted"
+}
+
+func (p MicropubProperties) published() (time.Time, error) {
+	raw := p.firstString("published")
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: 无效的发布时间 %s", ErrMicropubInvalid, raw)
+}
+
+func joinContent(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, "\n\n")
+}
+
+// Create publishes a new post from an h-entry.
+func (s *MicropubService) Create(entryType string, props MicropubProperties) (*models.Post, error) {
+	if entryType != "" && entryType != "entry" && entryType != "h-entry" {
+		return nil, fmt.Errorf("%w: 只支持 h-entry", ErrMicropubInvalid)
+	}
+	content := joinContent(props.content(), props.photos())
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("%w: 文章内容不能为空", ErrMicropubInvalid)
+	}
+	publishedAt, err := props.published()
+	if err != nil {
+		return nil, err
+	}
+	if publishedAt.IsZero() {
+		publishedAt = time.Now()
+	}
+
+	post, _, err := s.postService.CreatePost(props.firstString("name"), content, props.isPrivate(), false, false, false, publishedAt)
+	if err != nil {
+		return nil, fmt.Errorf("创建文章失败: %w", err)
+	}
+	return post, nil
+}
+
+// FindByURL resolves a post URL on this site.
+func (s *MicropubService) FindByURL(rawURL string) (*models.Post, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的 url", ErrMicropubInvalid)
+	}
+	slug, ok := strings.CutPrefix(u.Path, "/post/")
+	if !ok || slug == "" {
+		return nil, ErrMicropubNotFound
+	}
+	post, err := s.postService.GetRawPostBySlug(slug)
+	if err != nil {
+		return nil, ErrMicropubNotFound
+	}
+	return post, nil
+}
+
+// Update applies the replace, add and delete operations of an update request.
+func (s *MicropubService) Update(rawURL string, replace, add MicropubProperties, remove interface{}) (*models.Post, error) {
+	post, err := s.FindByURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	title, content, isPrivate, publishedAt := post.Title, post.Content, post.IsPrivate, post.PublishedAt
+
+	if _, ok := replace["name"]; ok {
+		title = replace.firstString("name")
+	}
+	_, replaceContent := replace["content"]
+	if _, ok := replace["photo"]; ok {
+		// 图片写在正文中，无法单独找出原有图片，只能连同正文一起替换
+		if !replaceContent {
+			return nil, fmt.Errorf("%w: 替换 photo 时需要同时替换 content", ErrMicropubInvalid)
+		}
+		content = joinContent(replace.content(), replace.photos())
+	} else if replaceContent {
+		content = replace.content()
+	}
+	if _, ok := replace["published"]; ok {
+		if publishedAt, err = replace.published(); err != nil {
+			return nil, err
+		}
+	}
+	_, hasStatus := replace["post-status"]
+	_, hasVisibility := replace["visibility"]
+	if hasStatus || hasVisibility {
+		isPrivate = replace.isPrivate()
+	}
+
+	if _, ok := add["name"]; ok && title == "" {
+		title = add.firstString("name")
+	}
+	if _, ok := add["content"]; ok {
+		content = joinContent(content, add.content())
+	}
+	content = joinContent(content, add.photos())
+
+	switch r := remove.(type) {
+	case nil:
+	case []interface{}:
+		for _, name := range r {
+			switch name {
+			case "name":
+				title = ""
+			case "content":
+				return nil, fmt.Errorf("%w: 不能删除文章内容", ErrMicropubInvalid)
+			}
+		}
+	case map[string]interface{}:
+		// 只删除某个属性的部分取值，对不支持分类的 Glog 没有意义
+	default:
+		return nil, fmt.Errorf("%w: 无效的 delete", ErrMicropubInvalid)
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("%w: 文章内容不能为空", ErrMicropubInvalid)
+	}
+	updated, _, err := s.postService.UpdatePost(post.ID, title, content, isPrivate, post.NoIndex, false, false, publishedAt)
+	if err != nil {
+		return nil, fmt.Errorf("更新文章失败: %w", err)
+	}
+	return updated, nil
+}
+
+func (s *MicropubService) Delete(rawURL string) error {
+	post, err := s.FindByURL(rawURL)
+	if err != nil {
+		return err
+	}
+	return s.postService.DeletePost(post.ID)
+}
+
+// Source returns the h-entry of a post for q=source, limited to the
+// requested properties when any are given.
+func (s *MicropubService) Source(rawURL string, properties []string) (map[string]interface{}, error) {
+	post, err := s.FindByURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	status := "published"
+	visibility := "public"
+	if post.IsPrivate {
+		visibility = "private"
+	}
+	all := map[string][]interface{}{
+		"name":        {post.Title},
+		"content":     {post.Content},
+		"published":   {post.PublishedAt.Format(time.RFC3339)},
+		"post-status": {status},
+		"visibility":  {visibility},
+	}
+
+	if len(properties) == 0 {
+		return map[string]interface{}{"type": []string{"h-entry"}, "properties": all}, nil
+	}
+	selected := make(map[string][]interface{})
+	for _, name := range properties {
+		if values, ok := all[name]; ok {
+			selected[name] = values
+		}
+	}
+	return map[string]interface{}{"properties": selected}, nil
+}
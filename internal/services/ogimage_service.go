@@ -0,0 +1,249 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/fonts"
+	"glog/internal/models"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// 分享卡片尺寸采用 Open Graph 推荐的 1200x630
+const (
+	ogImageWidth    = 1200
+	ogImageHeight   = 630
+	ogImagePadding  = 80
+	ogTitleSize     = 64
+	ogTitleMaxLines = 3
+	ogFooterSize    = 30
+)
+
+var (
+	ogBackground = color.RGBA{0xfa, 0xfa, 0xfa, 0xff}
+	ogText       = color.RGBA{0x22, 0x27, 0x2a, 0xff}
+	ogSecondary  = color.RGBA{0x84, 0x8a, 0x8f, 0xff}
+	ogAccent     = color.RGBA{0xcb, 0x2a, 0x42, 0xff}
+)
+
+// OGImageService renders per-post social share cards and caches them on
+// disk. A cached file is reused until the post's UpdatedAt or the site title
+// changes.
+type OGImageService struct {
+	settingService *SettingService
+	cacheDir       string
+
+	fontOnce sync.Once
+	font     *opentype.Font
+	fontErr  error
+
+	renderMu sync.Mutex // 避免同一张图被并发重复生成
+}
+
+func NewOGImageService(settingService *SettingService, cacheDir string) *OGImageService {
+	return &OGImageService{settingService: settingService, cacheDir: cacheDir}
+}
+
+func (s *OGImageService) loadFont() (*opentype.Font, error) {
+	s.fontOnce.Do(func() {
+		s.font, s.fontErr = opentype.Parse(fonts.WQYMicroHei)
+		if s.fontErr != nil {
+			s.fontErr = fmt.Errorf("解析字体失败: %w", s.fontErr)
+		}
+	})
+	return s.font, s.fontErr
+}
+
+// PostImage returns the path of the PNG card for the post, rendering it
+// first when no up-to-date cached copy exists.
+func (s *OGImageService) PostImage(post *models.RenderedPost) (string, error) {
+	siteTitle, _ := s.settingService.GetSetting(constants.SettingSiteTitle)
+	if siteTitle == "" {
+		siteTitle = "Glog"
+	}
+
+	hash := sha256.Sum256([]byte(siteTitle))
+	name := fmt.Sprintf("%d-%d-%s.png", post.ID, post.UpdatedAt.UnixNano(), hex.EncodeToString(hash[:4]))
+	path := filepath.Join(s.cacheDir, name)
+
+	s.renderMu.Lock()
+	defer s.renderMu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	img, err := s.render(post.Title, siteTitle, post.PublishedAt.Format("2006年01月02日"))
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.cacheDir, 0755); err != nil {
+		return "", fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	// 先写临时文件再重命名，避免并发读取到写了一半的图片
+	tmp, err := os.CreateTemp(s.cacheDir, "og-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("创建缓存文件失败: %w", err)
+	}
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("编码图片失败: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("保存缓存文件失败: %w", err)
+	}
+
+	// 清理同一篇文章的旧版本
+	if stale, err := filepath.Glob(filepath.Join(s.cacheDir, fmt.Sprintf("%d-*.png", post.ID))); err == nil {
+		for _, old := range stale {
+			if old != path {
+				os.Remove(old)
+			}
+		}
+	}
+	return path, nil
+}
+
+func (s *OGImageService) render(title, siteTitle, date string) (image.Image, error) {
+	f, err := s.loadFont()
+	if err != nil {
+		return nil, err
+	}
+	titleFace, err := opentype.NewFace(f, &opentype.FaceOptions{Size: ogTitleSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("创建字体失败: %w", err)
+	}
+	defer titleFace.Close()
+	footerFace, err := opentype.NewFace(f, &opentype.FaceOptions{Size: ogFooterSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("创建字体失败: %w", err)
+	}
+	defer footerFace.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, ogImageWidth, ogImageHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(ogBackground), image.Point{}, draw.Src)
+	// 顶部强调色条
+	draw.Draw(img, image.Rect(0, 0, ogImageWidth, 16), image.NewUniform(ogAccent), image.Point{}, draw.Src)
+
+	contentWidth := ogImageWidth - 2*ogImagePadding
+	lines := wrapText(titleFace, title, contentWidth, ogTitleMaxLines)
+	lineHeight := titleFace.Metrics().Height.Ceil() * 5 / 4
+
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(ogText), Face: titleFace}
+	y := ogImagePadding + 40 + titleFace.Metrics().Ascent.Ceil()
+	for _, line := range lines {
+		drawer.Dot = fixed.P(ogImagePadding, y)
+		drawer.DrawString(line)
+		y += lineHeight
+	}
+
+	// 底部：左侧站点标题，右侧发布日期
+	footerY := ogImageHeight - ogImagePadding
+
+	drawer = &font.Drawer{Dst: img, Src: image.NewUniform(ogText), Face: footerFace}
+	siteLine := wrapText(footerFace, siteTitle, contentWidth*2/3, 1)
+	if len(siteLine) > 0 {
+		drawer.Dot = fixed.P(ogImagePadding, footerY)
+		drawer.DrawString(siteLine[0])
+	}
+	drawer.Src = image.NewUniform(ogSecondary)
+	dateWidth := drawer.MeasureString(date).Ceil()
+	drawer.Dot = fixed.P(ogImageWidth-ogImagePadding-dateWidth, footerY)
+	drawer.DrawString(date)
+
+	return img, nil
+}
+
+// wrapText breaks text into at most maxLines lines no wider than maxWidth.
+// Latin words are kept whole while CJK characters may break anywhere; an
+// ellipsis marks truncated text.
+func wrapText(face font.Face, text string, maxWidth, maxLines int) []string {
+	var tokens []string
+	var word []rune
+	for _, r := range strings.TrimSpace(text) {
+		switch {
+		case unicode.IsSpace(r):
+			if len(word) > 0 {
+				tokens = append(tokens, string(word))
+				word = nil
+			}
+			tokens = append(tokens, " ")
+		case r < 0x2E80: // 拉丁字母、数字及西文标点按单词处理
+			word = append(word, r)
+		default:
+			if len(word) > 0 {
+				tokens = append(tokens, string(word))
+				word = nil
+			}
+			tokens = append(tokens, string(r))
+		}
+	}
+	if len(word) > 0 {
+		tokens = append(tokens, string(word))
+	}
+
+	width := func(s string) int { return font.MeasureString(face, s).Ceil() }
+	limit := fixed.I(maxWidth)
+
+	var lines []string
+	var current string
+	truncated := false
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if current == "" && token == " " {
+			continue
+		}
+		if font.MeasureString(face, current+token) <= limit {
+			current += token
+			continue
+		}
+		if current == "" {
+			// 单个超长单词，按字符强制断开
+			runes := []rune(token)
+			n := len(runes)
+			for n > 1 && width(string(runes[:n])) > maxWidth {
+				n--
+			}
+			current = string(runes[:n])
+			tokens[i] = string(runes[n:])
+			i--
+		} else {
+			i--
+		}
+		lines = append(lines, strings.TrimRight(current, " "))
+		current = ""
+		if len(lines) == maxLines {
+			truncated = true
+			break
+		}
+	}
+	if !truncated && current != "" {
+		lines = append(lines, strings.TrimRight(current, " "))
+	}
+
+	if truncated && len(lines) > 0 {
+		last := []rune(lines[len(lines)-1])
+		for len(last) > 0 && width(string(last)+"…") > maxWidth {
+			last = last[:len(last)-1]
+		}
+		lines[len(lines)-1] = string(last) + "…"
+	}
+	return lines
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrOIDCDisabled   = errors.New("单点登录未启用")
+	ErrOIDCExpired    = errors.New("登录已过期，请重试")
+	ErrOIDCNotAllowed = errors.New("该账号不在允许登录的名单中")
+)
+
+const (
+	// OIDCCallbackPath is the redirect URI to register at the provider,
+	// relative to the site URL.
+	OIDCCallbackPath = "/login/oidc/callback"
+	// oidcLoginTimeout limits how long the admin may stay at the provider.
+	oidcLoginTimeout = 10 * time.Minute
+	oidcHTTPTimeout  = 10 * time.Second
+)
+
+// OIDCService implements single sign-on for the admin with an OpenID Connect
+// provider: discovery, the authorization code flow with PKCE and validation
+// of the ID token. Only identities on the configured allowlist may sign in.
+type OIDCService struct {
+	settingService *SettingService
+
+	mu sync.Mutex
+	// 按 issuer 缓存发现文档，签名公钥由 go-oidc 按需刷新
+	providers map[string]*oidc.Provider
+}
+
+func NewOIDCService(settingService *SettingService) *OIDCService {
+	return &OIDCService{settingService: settingService, providers: make(map[string]*oidc.Provider)}
+}
+
+// OIDCIdentity is the identity from a verified ID token.
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Groups        []string
+}
+
+// String describes the identity for the audit log.
+func (i *OIDCIdentity) String() string {
+	if i.Email != "" {
+		return i.Email
+	}
+	return i.Subject
+}
+
+// oidcLogin is kept in the session between the redirect to the provider and
+// the callback.
+type oidcLogin struct {
+	State       string `json:"state"`
+	Nonce       string `json:"nonce"`
+	Verifier    string `json:"verifier"`
+	RedirectURL string `json:"redirect_url"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+type oidcConfig struct {
+	siteURL      string
+	issuer       string
+	clientID     string
+	clientSecret string
+	groupsClaim  string
+	subjects     []string
+	emails       []string
+	groups       []string
+}
+
+func (s *OIDCService) config() *oidcConfig {
+	get := func(key string) string {
+		value, _ := s.settingService.GetSetting(key)
+		return strings.TrimSpace(value)
+	}
+	config := &oidcConfig{
+		siteURL:      strings.TrimRight(get(constants.SettingSiteURL), "/"),
+		issuer:       get(constants.SettingOIDCIssuer),
+		clientID:     get(constants.SettingOIDCClientID),
+		clientSecret: get(constants.SettingOIDCClientSecret),
+		groupsClaim:  get(constants.SettingOIDCGroupsClaim),
+		subjects:     oidcList(get(constants.SettingOIDCAllowedSubjects)),
+		emails:       oidcList(get(constants.SettingOIDCAllowedEmails)),
+		groups:       oidcList(get(constants.SettingOIDCAllowedGroups)),
+	}
+	if config.groupsClaim == "" {
+		config.groupsClaim = "groups"
+	}
+	return config
+}
+
+// Enabled reports whether single sign-on is turned on and configured. The
+// redirect URI is built from the site address, never from the Host header
+// of the request.
+func (s *OIDCService) Enabled() bool {
+	enabled, _ := s.settingService.GetSetting(constants.SettingOIDCEnabled)
+	config := s.config()
+	return enabled == "true" && config.siteURL != "" && config.issuer != "" && config.clientID != ""
+}
+
+// Name returns the provider name shown on the login button.
+func (s *OIDCService) Name() string {
+	name, _ := s.settingService.GetSetting(constants.SettingOIDCName)
+	if name = strings.TrimSpace(name); name == "" {
+		return "单点登录"
+	}
+	return name
+}
+
+// oidcContext makes go-oidc and oauth2 use an HTTP client with a timeout.
+func oidcContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Timeout: oidcHTTPTimeout})
+}
+
+// provider fetches the discovery document of issuer. Failures are not
+// cached, so a provider that was down is retried on the next login.
+func (s *OIDCService) provider(ctx context.Context, issuer string) (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if provider, ok := s.providers[issuer]; ok {
+		return provider, nil
+	}
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("获取身份提供方配置失败: %w", err)
+	}
+	s.providers[issuer] = provider
+	return provider, nil
+}
+
+func (s *OIDCService) oauth2Config(provider *oidc.Provider, config *oidcConfig, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     config.clientID,
+		ClientSecret: config.clientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+	}
+}
+
+// Begin starts a login. It returns the provider's authorization URL and the
+// session data that must be kept until Finish.
+func (s *OIDCService) Begin(ctx context.Context) (string, string, error) {
+	if !s.Enabled() {
+		return "", "", ErrOIDCDisabled
+	}
+	config := s.config()
+	redirectURL := config.siteURL + OIDCCallbackPath
+	provider, err := s.provider(oidcContext(ctx), config.issuer)
+	if err != nil {
+		return "", "", err
+	}
+
+	login := oidcLogin{
+		Verifier:    oauth2.GenerateVerifier(),
+		RedirectURL: redirectURL,
+		CreatedAt:   time.Now().Unix(),
+	}
+	if login.State, err = randomOIDCValue(); err != nil {
+		return "", "", err
+	}
+	if login.Nonce, err = randomOIDCValue(); err != nil {
+		return "", "", err
+	}
+	data, err := json.Marshal(login)
+	if err != nil {
+		return "", "", fmt.Errorf("编码登录状态失败: %w", err)
+	}
+
+	authURL := s.oauth2Config(provider, config, redirectURL).AuthCodeURL(login.State,
+		oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.Verifier))
+	return authURL, string(data), nil
+}
+
+// Finish exchanges the authorization code from the callback, verifies the
+// ID token and checks the identity against the allowlist.
+func (s *OIDCService) Finish(ctx context.Context, sessionData, state, code string) (*OIDCIdentity, error) {
+	if !s.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+	var login oidcLogin
+	if err := json.Unmarshal([]byte(sessionData), &login); err != nil {
+		return nil, fmt.Errorf("解析登录状态失败: %w", err)
+	}
+	if time.Since(time.Unix(login.CreatedAt, 0)) > oidcLoginTimeout {
+		return nil, ErrOIDCExpired
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(login.State)) != 1 {
+		return nil, errors.New("state 不匹配")
+	}
+	if code == "" {
+		return nil, errors.New("缺少授权码")
+	}
+
+	ctx = oidcContext(ctx)
+	config := s.config()
+	provider, err := s.provider(ctx, config.issuer)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.oauth2Config(provider, config, login.RedirectURL).Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("换取令牌失败: %w", err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("身份提供方未返回 ID Token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: config.clientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("验证 ID Token 失败: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(login.Nonce)) != 1 {
+		return nil, errors.New("nonce 不匹配")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("解析 ID Token 失败: %w", err)
+	}
+	identity := &OIDCIdentity{Subject: idToken.Subject, Groups: claimStrings(claims[config.groupsClaim])}
+	identity.Email, _ = claims["email"].(string)
+	// 部分身份提供方以字符串形式返回 email_verified
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	if !config.allows(identity) {
+		return identity, fmt.Errorf("%w: %s", ErrOIDCNotAllowed, identity)
+	}
+	return identity, nil
+}
+
+// allows reports whether the identity matches any allowlist entry. An email
+// only counts when the provider has verified it.
+func (c *oidcConfig) allows(identity *OIDCIdentity) bool {
+	for _, subject := range c.subjects {
+		if subject == identity.Subject {
+			return true
+		}
+	}
+	if identity.Email != "" && identity.EmailVerified {
+		for _, email := range c.emails {
+			if strings.EqualFold(email, identity.Email) {
+				return true
+			}
+		}
+	}
+	for _, group := range c.groups {
+		for _, member := range identity.Groups {
+			if group == member {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimStrings reads a claim that is either a string or a list of strings.
+func claimStrings(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		var values []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func randomOIDCValue() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("生成随机值失败: %w", err)
+	}
+	return hex.EncodeToString(random), nil
+}
+
+// oidcList splits an allowlist setting into its lines. Group names may
+// contain spaces, so lines are not split further.
+func oidcList(raw string) []string {
+	var values []string
+	for _, line := range strings.Split(raw, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			values = append(values, line)
+		}
+	}
+	return values
+}
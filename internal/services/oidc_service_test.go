@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// fakeOIDCProvider is an OpenID Connect provider that issues an ID token for
+// every authorization request. claims may change the token before it is signed.
+type fakeOIDCProvider struct {
+	server *httptest.Server
+	signer jose.Signer
+	key    *rsa.PrivateKey
+	// discoveryIssuer overrides the issuer announced in the discovery document.
+	discoveryIssuer string
+	claims          func(claims map[string]any)
+
+	mu     sync.Mutex
+	grants map[string]url.Values
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: "test"}}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeOIDCProvider{signer: signer, key: key, grants: make(map[string]url.Values)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *fakeOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := p.server.URL
+	if p.discoveryIssuer != "" {
+		issuer = p.discoveryIssuer
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                issuer,
+		"authorization_endpoint":                p.server.URL + "/authorize",
+		"token_endpoint":                        p.server.URL + "/token",
+		"jwks_uri":                              p.server.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *fakeOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &p.key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}}})
+}
+
+func (p *fakeOIDCProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "需要使用 PKCE 的授权码流程", http.StatusBadRequest)
+		return
+	}
+	code := fmt.Sprintf("code-%d", time.Now().UnixNano())
+	p.mu.Lock()
+	p.grants[code] = query
+	p.mu.Unlock()
+
+	redirect, _ := url.Parse(query.Get("redirect_uri"))
+	redirect.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *fakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	p.mu.Lock()
+	grant, ok := p.grants[r.PostForm.Get("code")]
+	delete(p.grants, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	clientID, secret, _ := r.BasicAuth()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || clientID != grant.Get("client_id") || secret != "s3cret" ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != grant.Get("code_challenge") ||
+		r.PostForm.Get("redirect_uri") != grant.Get("redirect_uri") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := map[string]any{
+		"iss":   p.server.URL,
+		"sub":   "alice",
+		"aud":   grant.Get("client_id"),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": grant.Get("nonce"),
+	}
+	if p.claims != nil {
+		p.claims(claims)
+	}
+	idToken, err := jwt.Signed(p.signer).Claims(claims).Serialize()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"access_token": "at", "token_type": "Bearer", "expires_in": 3600, "id_token": idToken})
+}
+
+func newTestOIDCService(t *testing.T, provider *fakeOIDCProvider, settings map[string]string) *OIDCService {
+	t.Helper()
+	defaults := map[string]string{
+		constants.SettingSiteURL:             testBlogURL,
+		constants.SettingOIDCEnabled:         "true",
+		constants.SettingOIDCIssuer:          provider.server.URL,
+		constants.SettingOIDCClientID:        "glog",
+		constants.SettingOIDCClientSecret:    "s3cret",
+		constants.SettingOIDCAllowedSubjects: "alice",
+		constants.SettingOIDCAllowedEmails:   "bob@example.com",
+		constants.SettingOIDCAllowedGroups:   "blog admins",
+	}
+	for key, value := range settings {
+		defaults[key] = value
+	}
+	return NewOIDCService(newTestSettings(t, newTestDB(t), defaults))
+}
+
+// oidcLoginAt runs Begin, follows the authorization URL like a browser and
+// returns the session data, state and code that reach the callback.
+func oidcLoginAt(t *testing.T, service *OIDCService) (string, string, string) {
+	t.Helper()
+	authURL, sessionData, err := service.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("开始登录失败: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("授权请求被拒绝: %s", resp.Status)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := callback.Scheme + "://" + callback.Host + callback.Path; got != testBlogURL+OIDCCallbackPath {
+		t.Fatalf("回调地址应基于站点地址，实际 %s", got)
+	}
+	return sessionData, callback.Query().Get("state"), callback.Query().Get("code")
+}
+
+func TestOIDCLoginWithPKCE(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	service := newTestOIDCService(t, provider, nil)
+
+	sessionData, state, code := oidcLoginAt(t, service)
+	identity, err := service.Finish(context.Background(), sessionData, state, code)
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if identity.Subject != "alice" {
+		t.Errorf("应返回 ID Token 中的用户，实际 %s", identity.Subject)
+	}
+
+	// 授权码只能使用一次
+	if _, err := service.Finish(context.Background(), sessionData, state, code); err == nil {
+		t.Error("重复使用的授权码应被拒绝")
+	}
+}
+
+func TestOIDCRejectsInvalidResponses(t *testing.T) {
+	cases := []struct {
+		name   string
+		claims func(map[string]any)
+		tamper func(sessionData, state, code string) (string, string, string)
+	}{
+		{name: "nonce 不匹配", claims: func(c map[string]any) { c["nonce"] = "other" }},
+		{name: "aud 不匹配", claims: func(c map[string]any) { c["aud"] = "other-client" }},
+		{name: "iss 不匹配", claims: func(c map[string]any) { c["iss"] = "https://evil.example" }},
+		{name: "已过期", claims: func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "state 不匹配", tamper: func(sessionData, state, code string) (string, string, string) {
+			return sessionData, state + "x", code
+		}},
+		{name: "PKCE verifier 不匹配", tamper: func(sessionData, state, code string) (string, string, string) {
+			var login oidcLogin
+			json.Unmarshal([]byte(sessionData), &login)
+			login.Verifier = "wrong-verifier-wrong-verifier-wrong-verifier"
+			data, _ := json.Marshal(login)
+			return string(data), state, code
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newFakeOIDCProvider(t)
+			provider.claims = tc.claims
+			service := newTestOIDCService(t, provider, nil)
+
+			sessionData, state, code := oidcLoginAt(t, service)
+			if tc.tamper != nil {
+				sessionData, state, code = tc.tamper(sessionData, state, code)
+			}
+			if identity, err := service.Finish(context.Background(), sessionData, state, code); err == nil {
+				t.Fatalf("应拒绝登录，实际以 %s 登录", identity)
+			}
+		})
+	}
+}
+
+func TestOIDCAllowlist(t *testing.T) {
+	cases := []struct {
+		name    string
+		claims  map[string]any
+		allowed bool
+	}{
+		{"名单中的 sub", map[string]any{"sub": "alice"}, true},
+		{"已验证的邮箱", map[string]any{"sub": "u2", "email": "Bob@example.com", "email_verified": true}, true},
+		{"字符串形式的已验证", map[string]any{"sub": "u3", "email": "bob@example.com", "email_verified": "true"}, true},
+		{"未验证的邮箱", map[string]any{"sub": "u4", "email": "bob@example.com", "email_verified": false}, false},
+		{"名单中的组", map[string]any{"sub": "u5", "groups": []string{"readers", "blog admins"}}, true},
+		{"不在名单中", map[string]any{"sub": "mallory", "email": "mallory@example.com", "email_verified": true, "groups": []string{"readers"}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newFakeOIDCProvider(t)
+			provider.claims = func(claims map[string]any) {
+				for key, value := range tc.claims {
+					claims[key] = value
+				}
+			}
+			service := newTestOIDCService(t, provider, nil)
+
+			sessionData, state, code := oidcLoginAt(t, service)
+			_, err := service.Finish(context.Background(), sessionData, state, code)
+			if tc.allowed && err != nil {
+				t.Fatalf("应允许登录: %v", err)
+			}
+			if !tc.allowed && !errors.Is(err, ErrOIDCNotAllowed) {
+				t.Fatalf("应以不在名单中拒绝，实际 %v", err)
+			}
+		})
+	}
+}
+
+func TestOIDCDiscoveryAndSiteURL(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	provider.discoveryIssuer = "https://other.example"
+	service := newTestOIDCService(t, provider, nil)
+	if _, _, err := service.Begin(context.Background()); err == nil {
+		t.Error("发现文档中的 issuer 与配置不一致时应拒绝")
+	}
+
+	provider = newFakeOIDCProvider(t)
+	service = newTestOIDCService(t, provider, map[string]string{constants.SettingSiteURL: ""})
+	if service.Enabled() {
+		t.Error("未设置站点地址时不应启用单点登录")
+	}
+	if _, _, err := service.Begin(context.Background()); !errors.Is(err, ErrOIDCDisabled) {
+		t.Errorf("未设置站点地址时应拒绝开始登录，实际 %v", err)
+	}
+}
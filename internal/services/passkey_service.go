@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var (
+	ErrPasskeySiteURL  = errors.New("请先在设置中填写站点地址，通行密钥绑定在该域名上")
+	ErrPasskeyNotFound = errors.New("通行密钥不存在")
+)
+
+// PasskeyService implements WebAuthn registration and passwordless login for
+// the admin. The relying party is derived from the configured site URL, so
+// passkeys only work when the admin area is opened under that address.
+type PasskeyService struct {
+	repo           *repository.PasskeyRepository
+	settingService *SettingService
+}
+
+func NewPasskeyService(repo *repository.PasskeyRepository, settingService *SettingService) *PasskeyService {
+	return &PasskeyService{repo: repo, settingService: settingService}
+}
+
+// passkeyUser is the single admin account as a WebAuthn user.
+type passkeyUser struct {
+	id          []byte
+	displayName string
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte                         { return u.id }
+func (u *passkeyUser) WebAuthnName() string                       { return "admin" }
+func (u *passkeyUser) WebAuthnDisplayName() string                { return u.displayName }
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+// RelyingParty returns the WebAuthn configuration for the configured site URL.
+func (s *PasskeyService) RelyingParty() (*webauthn.WebAuthn, error) {
+	siteURL, _ := s.settingService.GetSetting(constants.SettingSiteURL)
+	u, err := url.Parse(strings.TrimSpace(siteURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, ErrPasskeySiteURL
+	}
+	title, _ := s.settingService.GetSetting(constants.SettingSiteTitle)
+	if title == "" {
+		title = "Glog"
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          u.Hostname(),
+		RPDisplayName: title,
+		RPOrigins:     []string{u.Scheme + "://" + u.Host},
+	})
+}
+
+// user loads the admin's user handle, generated on first use, and passkeys.
+func (s *PasskeyService) user() (*passkeyUser, error) {
+	userID, _ := s.settingService.GetSetting(constants.SettingWebAuthnUserID)
+	if userID == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("生成用户标识失败: %w", err)
+		}
+		userID = hex.EncodeToString(random)
+		if err := s.settingService.UpdateSettings(map[string]string{constants.SettingWebAuthnUserID: userID}); err != nil {
+			return nil, fmt.Errorf("保存用户标识失败: %w", err)
+		}
+	}
+	id, err := hex.DecodeString(userID)
+	if err != nil {
+		return nil, fmt.Errorf("无效的用户标识: %w", err)
+	}
+
+	passkeys, err := s.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("加载通行密钥失败: %w", err)
+	}
+	user := &passkeyUser{id: id, displayName: "管理员"}
+	for _, passkey := range passkeys {
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(passkey.Credential), &credential); err != nil {
+			log.Printf("解析通行密钥 ID %d 失败: %v", passkey.ID, err)
+			continue
+		}
+		user.credentials = append(user.credentials, credential)
+	}
+	return user, nil
+}
+
+// BeginRegistration starts adding a passkey. The returned session data must
+// be kept until FinishRegistration.
+func (s *PasskeyService) BeginRegistration() (*protocol.CredentialCreation, string, error) {
+	rp, err := s.RelyingParty()
+	if err != nil {
+		return nil, "", err
+	}
+	user, err := s.user()
+	if err != nil {
+		return nil, "", err
+	}
+	creation, session, err := rp.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("创建注册请求失败: %w", err)
+	}
+	data, err := json.Marshal(session)
+	return creation, string(data), err
+}
+
+// FinishRegistration verifies the authenticator's response and stores the passkey.
+func (s *PasskeyService) FinishRegistration(sessionData, name string, r *http.Request) (*models.Passkey, error) {
+	rp, err := s.RelyingParty()
+	if err != nil {
+		return nil, err
+	}
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(sessionData), &session); err != nil {
+		return nil, errors.New("注册已过期，请重试")
+	}
+	user, err := s.user()
+	if err != nil {
+		return nil, err
+	}
+	credential, err := rp.FinishRegistration(user, session, r)
+	if err != nil {
+		return nil, fmt.Errorf("验证通行密钥失败: %w", err)
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "通行密钥 " + time.Now().Format("2006-01-02")
+	}
+	passkey := &models.Passkey{Name: name, CredentialID: credential.ID, Credential: string(data)}
+	if err := s.repo.Create(passkey); err != nil {
+		return nil, fmt.Errorf("保存通行密钥失败: %w", err)
+	}
+	return passkey, nil
+}
+
+// BeginLogin starts a passwordless login. No credentials are listed, so the
+// browser offers every passkey it holds for this site.
+func (s *PasskeyService) BeginLogin() (*protocol.CredentialAssertion, string, error) {
+	rp, err := s.RelyingParty()
+	if err != nil {
+		return nil, "", err
+	}
+	assertion, session, err := rp.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, "", fmt.Errorf("创建登录请求失败: %w", err)
+	}
+	data, err := json.Marshal(session)
+	return assertion, string(data), err
+}
+
+// FinishLogin verifies an assertion from one of the admin's passkeys.
+func (s *PasskeyService) FinishLogin(sessionData string, r *http.Request) error {
+	rp, err := s.RelyingParty()
+	if err != nil {
+		return err
+	}
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(sessionData), &session); err != nil {
+		return errors.New("登录已过期，请重试")
+	}
+	user, err := s.user()
+	if err != nil {
+		return err
+	}
+
+	credential, err := rp.FinishDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		if !bytes.Equal(userHandle, user.id) {
+			return nil, ErrPasskeyNotFound
+		}
+		return user, nil
+	}, session, r)
+	if err != nil {
+		return fmt.Errorf("验证通行密钥失败: %w", err)
+	}
+	// 签名计数器倒退说明凭据可能被复制
+	if credential.Authenticator.CloneWarning {
+		return errors.New("检测到通行密钥可能被复制，已拒绝登录")
+	}
+
+	passkey, err := s.repo.FindByCredentialID(credential.ID)
+	if err != nil {
+		return ErrPasskeyNotFound
+	}
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdateUsage(passkey.ID, string(data), time.Now()); err != nil {
+		log.Printf("更新通行密钥使用记录失败: %v", err)
+	}
+	return nil
+}
+
+func (s *PasskeyService) List() ([]models.Passkey, error) {
+	return s.repo.FindAll()
+}
+
+// HasPasskeys reports whether the login page should offer passkey login.
+func (s *PasskeyService) HasPasskeys() bool {
+	count, err := s.repo.Count()
+	return err == nil && count > 0
+}
+
+func (s *PasskeyService) Rename(id uint, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("名称不能为空")
+	}
+	if err := s.repo.UpdateName(id, name); err != nil {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
+
+func (s *PasskeyService) Delete(id uint) error {
+	return s.repo.Delete(id)
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"glog/internal/constants"
+	"glog/internal/repository"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+)
+
+// softAuthenticator is a platform authenticator in software. It creates one
+// P-256 credential with "none" attestation and signs assertions with it.
+type softAuthenticator struct {
+	origin       string
+	rpID         string
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, origin, rpID string) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+	return &softAuthenticator{origin: origin, rpID: rpID, key: key, credentialID: credentialID}
+}
+
+var b64 = base64.RawURLEncoding
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony, challenge string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"type": ceremony, "challenge": challenge, "origin": a.origin, "crossOrigin": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// authenticatorData builds the authenticator data with user presence and
+// verification, and with the attested credential when attested is set.
+func (a *softAuthenticator) authenticatorData(t *testing.T, attested bool) []byte {
+	t.Helper()
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	if attested {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if !attested {
+		return data
+	}
+
+	publicKey, err := webauthncbor.Marshal(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, make([]byte, 16)...) // AAGUID
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	return append(data, publicKey...)
+}
+
+// register answers a registration request like navigator.credentials.create.
+func (a *softAuthenticator) register(t *testing.T, creation *protocol.CredentialCreation) *http.Request {
+	t.Helper()
+	if creation.Response.RelyingParty.ID != a.rpID {
+		t.Fatalf("RP ID 应为 %s，实际 %s", a.rpID, creation.Response.RelyingParty.ID)
+	}
+	userID, ok := creation.Response.User.ID.(protocol.URLEncodedBase64)
+	if !ok {
+		t.Fatalf("无法读取用户标识: %T", creation.Response.User.ID)
+	}
+	a.userHandle = userID
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authenticatorData(t, true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.request(t, map[string]any{
+		"clientDataJSON":    b64.EncodeToString(a.clientData(t, "webauthn.create", creation.Response.Challenge.String())),
+		"attestationObject": b64.EncodeToString(attestation),
+	})
+}
+
+// login answers a login request like navigator.credentials.get.
+func (a *softAuthenticator) login(t *testing.T, assertion *protocol.CredentialAssertion) *http.Request {
+	t.Helper()
+	a.signCount++
+	authData := a.authenticatorData(t, false)
+	clientData := a.clientData(t, "webauthn.get", assertion.Response.Challenge.String())
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.request(t, map[string]any{
+		"clientDataJSON":    b64.EncodeToString(clientData),
+		"authenticatorData": b64.EncodeToString(authData),
+		"signature":         b64.EncodeToString(signature),
+		"userHandle":        b64.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) request(t *testing.T, response map[string]any) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{
+		"id":                     b64.EncodeToString(a.credentialID),
+		"rawId":                  b64.EncodeToString(a.credentialID),
+		"type":                   "public-key",
+		"response":               response,
+		"clientExtensionResults": map[string]any{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, a.origin+"/login/passkey/finish", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func newTestPasskeyService(t *testing.T) *PasskeyService {
+	t.Helper()
+	db := newTestDB(t)
+	settingService := newTestSettings(t, db, map[string]string{constants.SettingSiteURL: testBlogURL})
+	return NewPasskeyService(repository.NewPasskeyRepository(db), settingService)
+}
+
+// registerPasskey adds a passkey held by a new software authenticator.
+func registerPasskey(t *testing.T, service *PasskeyService) *softAuthenticator {
+	t.Helper()
+	authenticator := newSoftAuthenticator(t, testBlogURL, "blog.example")
+	creation, sessionData, err := service.BeginRegistration()
+	if err != nil {
+		t.Fatalf("开始注册失败: %v", err)
+	}
+	if _, err := service.FinishRegistration(sessionData, "测试密钥", authenticator.register(t, creation)); err != nil {
+		t.Fatalf("注册通行密钥失败: %v", err)
+	}
+	return authenticator
+}
+
+func passkeyLogin(t *testing.T, service *PasskeyService, authenticator *softAuthenticator) error {
+	t.Helper()
+	assertion, sessionData, err := service.BeginLogin()
+	if err != nil {
+		t.Fatalf("开始登录失败: %v", err)
+	}
+	return service.FinishLogin(sessionData, authenticator.login(t, assertion))
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	service := newTestPasskeyService(t)
+	authenticator := registerPasskey(t, service)
+
+	passkeys, err := service.List()
+	if err != nil || len(passkeys) != 1 || passkeys[0].Name != "测试密钥" {
+		t.Fatalf("应保存一个通行密钥，实际 %v (err=%v)", passkeys, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := passkeyLogin(t, service, authenticator); err != nil {
+			t.Fatalf("第 %d 次登录失败: %v", i+1, err)
+		}
+	}
+	passkeys, _ = service.List()
+	if passkeys[0].LastUsedAt == nil {
+		t.Error("登录后应记录使用时间")
+	}
+	if !strings.Contains(passkeys[0].Credential, `"signCount":2`) {
+		t.Errorf("登录后应保存签名计数器，实际 %s", passkeys[0].Credential)
+	}
+}
+
+func TestPasskeyLoginRejectsClonedAuthenticator(t *testing.T) {
+	service := newTestPasskeyService(t)
+	authenticator := registerPasskey(t, service)
+	if err := passkeyLogin(t, service, authenticator); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if err := passkeyLogin(t, service, authenticator); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+
+	// 复制出的凭据从较小的计数器继续签名
+	authenticator.signCount = 0
+	err := passkeyLogin(t, service, authenticator)
+	if err == nil || !strings.Contains(err.Error(), "复制") {
+		t.Fatalf("签名计数器倒退时应以凭据被复制拒绝登录，实际 %v", err)
+	}
+}
+
+func TestPasskeyLoginRejectsOtherUserHandle(t *testing.T) {
+	service := newTestPasskeyService(t)
+	authenticator := registerPasskey(t, service)
+
+	authenticator.userHandle = []byte("someone-else")
+	if err := passkeyLogin(t, service, authenticator); !errors.Is(err, ErrPasskeyNotFound) {
+		t.Fatalf("用户标识不属于管理员时应拒绝登录，实际 %v", err)
+	}
+
+	// 未注册的凭据即使带有管理员的用户标识也不能登录
+	user, err := service.user()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger := newSoftAuthenticator(t, testBlogURL, "blog.example")
+	stranger.userHandle = user.id
+	if err := passkeyLogin(t, service, stranger); err == nil {
+		t.Fatal("未注册的通行密钥不应能登录")
+	}
+}
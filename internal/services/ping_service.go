@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	DeliveryKindWebSub    = "websub"
+	DeliveryKindIndexNow  = "indexnow"
+	DeliveryKindBaiduPush = "baidu-push"
+
+	DefaultIndexNowEndpoint = "https://api.indexnow.org/indexnow"
+
+	pingMaxResponseBytes = 64 << 10
+)
+
+// PingKinds lists the delivery kinds shown in the submission log.
+var PingKinds = []string{DeliveryKindWebSub, DeliveryKindIndexNow, DeliveryKindBaiduPush}
+
+// pingFeedPaths are the feeds announced to WebSub hubs.
+var pingFeedPaths = []string{"/feed.xml", "/atom.xml", "/feed.json"}
+
+// PingService tells feed hubs and search engines about new posts as soon as
+// they become public. Every notification goes through the delivery queue, so
+// failures are retried and recorded in the submission log.
+type PingService struct {
+	settingService *SettingService
+	queue          *DeliveryQueue
+}
+
+func NewPingService(settingService *SettingService, queue *DeliveryQueue, eventBus *EventBus) *PingService {
+	s := &PingService{settingService: settingService, queue: queue}
+	queue.Register(DeliveryKindWebSub, s.sendWebSub)
+	queue.Register(DeliveryKindIndexNow, s.sendIndexNow)
+	queue.Register(DeliveryKindBaiduPush, s.sendBaiduPush)
+	eventBus.Subscribe(s.handleEvent)
+	return s
+}
+
+// ParseHubs splits the configured hub list, one URL per line, and drops
+// anything that is not an http(s) URL.
+func ParseHubs(raw string) []string {
+	var hubs []string
+	for _, line := range strings.Fields(raw) {
+		u, err := url.Parse(line)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		hubs = append(hubs, u.String())
+	}
+	return hubs
+}
+
+// Hubs returns the WebSub hubs advertised in feeds and pinged on publish.
+func (s *PingService) Hubs() []string {
+	raw, _ := s.settingService.GetSetting(constants.SettingWebSubHubs)
+	return ParseHubs(raw)
+}
+
+// IndexNowKey returns the IndexNow key, generating one the first time
+// IndexNow is used. It is empty while IndexNow is switched off.
+func (s *PingService) IndexNowKey() string {
+	enabled, _ := s.settingService.GetSetting(constants.SettingIndexNowEnabled)
+	if enabled != "true" {
+		return ""
+	}
+	key, _ := s.settingService.GetSetting(constants.SettingIndexNowKey)
+	if key != "" {
+		return key
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		log.Printf("生成 IndexNow 密钥失败: %v", err)
+		return ""
+	}
+	key = hex.EncodeToString(random)
+	if err := s.settingService.UpdateSettings(map[string]string{constants.SettingIndexNowKey: key}); err != nil {
+		log.Printf("保存 IndexNow 密钥失败: %v", err)
+		return ""
+	}
+	return key
+}
+
+func (s *PingService) handleEvent(event Event) {
+	if event.Type != EventPostPublished || event.Post.IsPrivate {
+		return
+	}
+	if err := s.submitPost(event.Post); err != nil {
+		log.Printf("提交文章 ID %d 失败: %v", event.Post.ID, err)
+	}
+}
+
+// submitPost queues a notification to every configured service.
+func (s *PingService) submitPost(post *models.Post) error {
+	siteURL, _ := s.settingService.GetSetting(constants.SettingSiteURL)
+	siteURL = strings.TrimRight(siteURL, "/")
+	if siteURL == "" {
+		// 搜索引擎需要可公开访问的固定地址
+		return nil
+	}
+	postURL := siteURL + "/post/" + post.Slug
+
+	for _, hub := range s.Hubs() {
+		for _, path := range pingFeedPaths {
+			if err := s.queue.Enqueue(DeliveryKindWebSub, hub, siteURL+path, postURL); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.IndexNowKey() != "" {
+		endpoint, _ := s.settingService.GetSetting(constants.SettingIndexNowEndpoint)
+		if endpoint == "" {
+			endpoint = DefaultIndexNowEndpoint
+		}
+		if err := s.queue.Enqueue(DeliveryKindIndexNow, endpoint, postURL, postURL); err != nil {
+			return err
+		}
+	}
+
+	if api, _ := s.settingService.GetSetting(constants.SettingBaiduPushAPI); api != "" {
+		// 接口地址中含有 token，日志里只记录不带参数的部分，发送时再读取完整地址
+		target := api
+		if u, err := url.Parse(api); err == nil {
+			u.RawQuery = ""
+			target = u.String()
+		}
+		if err := s.queue.Enqueue(DeliveryKindBaiduPush, target, postURL, postURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PingService) post(endpoint, contentType string, body []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrDeliveryPermanent, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "Glog")
+
+	resp, err := s.queue.Client().Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, pingMaxResponseBytes))
+	return resp, respBody, nil
+}
+
+// sendWebSub tells a hub that one of the feeds (the payload) has changed.
+func (s *PingService) sendWebSub(job *models.DeliveryJob) (int, error) {
+	form := url.Values{"hub.mode": {"publish"}, "hub.url": {job.Payload}}
+	resp, _, err := s.post(job.Target, "application/x-www-form-urlencoded", []byte(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	return CheckDeliveryResponse(resp)
+}
+
+// sendIndexNow submits the post URL with the current key. The key file is
+// served from the site root, so no keyLocation is needed.
+func (s *PingService) sendIndexNow(job *models.DeliveryJob) (int, error) {
+	key := s.IndexNowKey()
+	if key == "" {
+		return 0, fmt.Errorf("%w: IndexNow 已关闭", ErrDeliveryPermanent)
+	}
+	u, err := url.Parse(job.Payload)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrDeliveryPermanent, err)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"host":    u.Host,
+		"key":     key,
+		"urlList": []string{job.Payload},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrDeliveryPermanent, err)
+	}
+
+	resp, _, err := s.post(job.Target, "application/json; charset=utf-8", body)
+	if err != nil {
+		return 0, err
+	}
+	return CheckDeliveryResponse(resp)
+}
+
+// baiduPushResult is the response of Baidu's link submission API.
+type baiduPushResult struct {
+	Success     int      `json:"success"`
+	Remain      int      `json:"remain"`
+	NotSameSite []string `json:"not_same_site"`
+	NotValid    []string `json:"not_valid"`
+	Error       int      `json:"error"`
+	Message     string   `json:"message"`
+}
+
+// sendBaiduPush submits the post URL to a Baidu-style link submission API,
+// which takes a plain-text list of URLs.
+func (s *PingService) sendBaiduPush(job *models.DeliveryJob) (int, error) {
+	api, _ := s.settingService.GetSetting(constants.SettingBaiduPushAPI)
+	if api == "" {
+		return 0, fmt.Errorf("%w: 未配置推送接口", ErrDeliveryPermanent)
+	}
+	resp, body, err := s.post(api, "text/plain", []byte(job.Payload+"\n"))
+	if err != nil {
+		return 0, err
+	}
+
+	var result baiduPushResult
+	if json.Unmarshal(body, &result) == nil {
+		switch {
+		case result.Error != 0:
+			if resp.StatusCode >= 500 {
+				return resp.StatusCode, fmt.Errorf("推送接口返回错误 %d: %s", result.Error, result.Message)
+			}
+			return resp.StatusCode, fmt.Errorf("%w: 推送接口返回错误 %d: %s", ErrDeliveryPermanent, result.Error, result.Message)
+		case len(result.NotSameSite) > 0 || len(result.NotValid) > 0:
+			return resp.StatusCode, fmt.Errorf("%w: 链接不属于该站点或无效", ErrDeliveryPermanent)
+		}
+	}
+	return CheckDeliveryResponse(resp)
+}
+
+// ListSubmissions returns a page of the submission log.
+func (s *PingService) ListSubmissions(page, pageSize int) ([]models.DeliveryJob, int64, error) {
+	return s.queue.List(PingKinds, page, pageSize)
+}
+
+// RetrySubmission schedules a logged submission for another attempt.
+func (s *PingService) RetrySubmission(id uint) error {
+	return s.queue.Retry(id, PingKinds)
+}
@@ -9,6 +9,7 @@ import (
 	"glog/internal/utils"
 	"html/template"
 	"io"
+	"net/url"
 	"regexp"
 	"strings"
 	"sync"
@@ -26,17 +27,67 @@ type PostService struct {
 	repo           *repository.PostRepository
 	settingService *SettingService
 	aiService      *AIService
+	eventBus       *EventBus
 }
 
-func NewPostService(repo *repository.PostRepository, settingService *SettingService, aiService *AIService) *PostService {
+func NewPostService(repo *repository.PostRepository, settingService *SettingService, aiService *AIService, eventBus *EventBus) *PostService {
 	return &PostService{
 		repo:           repo,
 		settingService: settingService,
 		aiService:      aiService,
+		eventBus:       eventBus,
 	}
 }
 
-func (s *PostService) processAndRenderContent(md string) (string, error) {
+// publish emits a post event carrying a snapshot of the post.
+func (s *PostService) publish(eventType string, post *models.Post) {
+	snapshot := *post
+	s.eventBus.Publish(Event{Type: eventType, Post: &snapshot})
+}
+
+// AnnouncePublished emits post.published for every post that has become
+// publicly visible since the last call: newly created or updated public posts
+// as well as scheduled posts whose published_at has passed. It is called
+// after each save and periodically by the scheduler.
+func (s *PostService) AnnouncePublished() error {
+	posts, err := s.repo.FindUnannounced()
+	if err != nil {
+		return fmt.Errorf("获取待发布文章失败: %w", err)
+	}
+	for i := range posts {
+		ok, err := s.repo.MarkAnnounced(posts[i].ID, time.Now())
+		if err != nil {
+			return fmt.Errorf("标记文章 ID %d 已发布失败: %w", posts[i].ID, err)
+		}
+		if ok {
+			s.publish(EventPostPublished, &posts[i])
+		}
+	}
+	return nil
+}
+
+func (s *PostService) announceAfterSave() {
+	if err := s.AnnouncePublished(); err != nil {
+		fmt.Printf("发布事件处理失败: %v\n", err)
+	}
+}
+
+// htmlPolicy returns the sanitization policy for a post from the settings.
+func (s *PostService) htmlPolicy(trusted bool) utils.HTMLPolicy {
+	hosts, _ := s.settingService.GetSetting(constants.SettingHTMLIframeHosts)
+	policy := utils.HTMLPolicy{
+		Trusted:     trusted,
+		IframeHosts: strings.FieldsFunc(hosts, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t' }),
+	}
+	siteURL, _ := s.settingService.GetSetting(constants.SettingSiteURL)
+	if u, err := url.Parse(siteURL); err == nil {
+		policy.SiteHost = u.Hostname()
+	}
+	return policy
+}
+
+func (s *PostService) processAndRenderContent(md string, trusted bool) (string, error) {
+	policy := s.htmlPolicy(trusted)
 	separatorRegex := regexp.MustCompile(`<!--\s*more\s*-->`)
 	parts := separatorRegex.Split(md, 2)
 
@@ -44,12 +95,12 @@ func (s *PostService) processAndRenderContent(md string) (string, error) {
 		summaryMd := parts[0]
 		bodyMd := parts[1]
 
-		summaryHtml, err := utils.RenderMarkdown(summaryMd)
+		summaryHtml, err := utils.RenderMarkdown(summaryMd, policy)
 		if err != nil {
 			return "", fmt.Errorf("摘要渲染失败: %w", err)
 		}
 
-		bodyHtml, err := utils.RenderMarkdown(bodyMd)
+		bodyHtml, err := utils.RenderMarkdown(bodyMd, policy)
 		if err != nil {
 			return "", fmt.Errorf("正文渲染失败: %w", err)
 		}
@@ -57,13 +108,42 @@ func (s *PostService) processAndRenderContent(md string) (string, error) {
 		return finalHtml, nil
 	}
 
-	fullHtml, err := utils.RenderMarkdown(md)
+	fullHtml, err := utils.RenderMarkdown(md, policy)
 	if err != nil {
 		return "", fmt.Errorf("全文渲染失败: %w", err)
 	}
 	return string(fullHtml), nil
 }
 
+// RerenderAll renders the HTML of every post again. It runs at startup and
+// when the sanitization settings change, so that stored HTML always follows
+// the current policy.
+func (s *PostService) RerenderAll() error {
+	posts, err := s.repo.FindAllForRendering()
+	if err != nil {
+		return fmt.Errorf("加载文章失败: %w", err)
+	}
+	updated := 0
+	for _, post := range posts {
+		html, err := s.processAndRenderContent(post.Content, post.TrustedHTML)
+		if err != nil {
+			fmt.Printf("重新渲染文章 ID %d 失败: %v\n", post.ID, err)
+			continue
+		}
+		if html == post.ContentHTML {
+			continue
+		}
+		if err := s.repo.UpdateContentHTML(post.ID, html); err != nil {
+			return fmt.Errorf("保存文章 ID %d 的 HTML 失败: %w", post.ID, err)
+		}
+		updated++
+	}
+	if updated > 0 {
+		fmt.Printf("已重新渲染 %d 篇文章\n", updated)
+	}
+	return nil
+}
+
 func (s *PostService) LockPost(postID uint) {
 	postLocksMu.Lock()
 	defer postLocksMu.Unlock()
@@ -82,7 +162,9 @@ func (s *PostService) CheckPostLock(postID uint) bool {
 	return postLocks[postID]
 }
 
-func (s *PostService) CreatePost(title, content string, isPrivate bool, aiSummary bool, publishedAt time.Time) (*models.Post, bool, error) {
+// CreatePost creates a post. trustedHTML outputs raw HTML in the content
+// without sanitization and must only be set for content from the editor.
+func (s *PostService) CreatePost(title, content string, isPrivate, noIndex, trustedHTML bool, aiSummary bool, publishedAt time.Time) (*models.Post, bool, error) {
 	if title == "" {
 		title = "未命名标题"
 	}
@@ -95,7 +177,7 @@ func (s *PostService) CreatePost(title, content string, isPrivate bool, aiSummar
 		return nil, false, err
 	}
 
-	htmlContent, err := s.processAndRenderContent(content)
+	htmlContent, err := s.processAndRenderContent(content, trustedHTML)
 	if err != nil {
 		return nil, false, err
 	}
@@ -108,6 +190,8 @@ func (s *PostService) CreatePost(title, content string, isPrivate bool, aiSummar
 		Excerpt:     excerpt,
 		Cover:       coverURL, // 保存封面
 		IsPrivate:   isPrivate,
+		NoIndex:     noIndex,
+		TrustedHTML: trustedHTML,
 		PublishedAt: publishedAt,
 	}
 
@@ -115,6 +199,8 @@ func (s *PostService) CreatePost(title, content string, isPrivate bool, aiSummar
 	if err != nil {
 		return nil, false, err
 	}
+	s.publish(EventPostCreated, post)
+	s.announceAfterSave()
 
 	aiTriggered := false
 	if aiSummary {
@@ -158,7 +244,7 @@ func (s *PostService) CreatePost(title, content string, isPrivate bool, aiSummar
 
 					if contentChanged {
 						updateMap["content"] = newContent
-						newHtmlContent, err := s.processAndRenderContent(newContent)
+						newHtmlContent, err := s.processAndRenderContent(newContent, post.TrustedHTML)
 						if err == nil {
 							updateMap["content_html"] = newHtmlContent
 						}
@@ -184,7 +270,9 @@ func (s *PostService) CreatePost(title, content string, isPrivate bool, aiSummar
 	return post, aiTriggered, nil
 }
 
-func (s *PostService) UpdatePost(id uint, title, content string, isPrivate bool, aiSummary bool, publishedAt time.Time) (*models.Post, bool, error) {
+// UpdatePost updates a post. Like CreatePost, trustedHTML must only be set
+// for content from the editor; other clients clear it.
+func (s *PostService) UpdatePost(id uint, title, content string, isPrivate, noIndex, trustedHTML bool, aiSummary bool, publishedAt time.Time) (*models.Post, bool, error) {
 	if strings.TrimSpace(content) == "" {
 		return nil, false, s.DeletePost(id)
 	}
@@ -197,7 +285,7 @@ func (s *PostService) UpdatePost(id uint, title, content string, isPrivate bool,
 		title = "未命名标题"
 	}
 
-	htmlContent, err := s.processAndRenderContent(content)
+	htmlContent, err := s.processAndRenderContent(content, trustedHTML)
 	if err != nil {
 		return nil, false, err
 	}
@@ -216,12 +304,20 @@ func (s *PostService) UpdatePost(id uint, title, content string, isPrivate bool,
 	post.Excerpt = utils.GenerateExcerpt(content, 150)
 	post.Cover = utils.ExtractFirstImageURL(content) // 提取封面
 	post.IsPrivate = isPrivate
+	post.NoIndex = noIndex
+	post.TrustedHTML = trustedHTML
 	post.PublishedAt = publishedAt
+	if isPrivate || publishedAt.After(time.Now()) {
+		// 文章重新变为不可见，之后再公开时需要重新发出发布事件
+		post.AnnouncedAt = nil
+	}
 
 	err = s.repo.Update(post)
 	if err != nil {
 		return nil, false, err
 	}
+	s.publish(EventPostUpdated, post)
+	s.announceAfterSave()
 
 	aiTriggered := false
 	if aiSummary {
@@ -263,7 +359,7 @@ func (s *PostService) UpdatePost(id uint, title, content string, isPrivate bool,
 
 					if contentChanged {
 						updateMap["content"] = newContent
-						newHtmlContent, err := s.processAndRenderContent(newContent)
+						newHtmlContent, err := s.processAndRenderContent(newContent, post.TrustedHTML)
 						if err == nil {
 							updateMap["content_html"] = newHtmlContent
 						}
@@ -290,13 +386,32 @@ func (s *PostService) UpdatePost(id uint, title, content string, isPrivate bool,
 }
 
 func (s *PostService) DeletePost(id uint) error {
-	return s.repo.Delete(id)
+	post, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.publish(EventPostDeleted, post)
+	return nil
 }
 
 func (s *PostService) GetPostByID(id uint) (*models.Post, error) {
 	return s.repo.FindByID(id)
 }
 
+// GetRecentPostsWithContent returns the newest posts of every visibility,
+// including their Markdown source, for remote editing clients.
+func (s *PostService) GetRecentPostsWithContent(limit int) ([]models.Post, error) {
+	return s.repo.FindPageWithContent(1, limit, true)
+}
+
+// GetRawPostBySlug returns the stored post for slug regardless of visibility.
+func (s *PostService) GetRawPostBySlug(slug string) (*models.Post, error) {
+	return s.repo.FindBySlug(slug, true)
+}
+
 func (s *PostService) GetPostBySlug(slug string, isLoggedIn bool) (*models.RenderedPost, error) {
 	post, err := s.repo.FindBySlug(slug, isLoggedIn)
 	if err != nil {
@@ -327,18 +442,45 @@ func (s *PostService) GetPostsPage(page, pageSize int, isLoggedIn bool) ([]model
 	return renderedPosts, int(total), nil
 }
 
-func (s *PostService) GetPostsPageByAdmin(page, pageSize int, query, status string) ([]models.Post, int, error) {
-	posts, err := s.repo.FindAllByAdmin(page, pageSize, query, status)
+func (s *PostService) GetPostsPageByAdmin(page, pageSize int, filter repository.AdminPostFilter) ([]models.Post, int, error) {
+	posts, err := s.repo.FindAllByAdmin(page, pageSize, filter)
 	if err != nil {
 		return nil, 0, err
 	}
-	total, err := s.repo.CountAllByAdmin(query, status)
+	total, err := s.repo.CountAllByAdmin(filter)
 	if err != nil {
 		return nil, 0, err
 	}
 	return posts, int(total), nil
 }
 
+// GetPostsByIDs retrieves the visible posts among ids, keeping the order of ids.
+func (s *PostService) GetPostsByIDs(ids []uint, isLoggedIn bool) ([]models.RenderedPost, error) {
+	posts, err := s.repo.FindByIDs(ids, isLoggedIn)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]*models.Post, len(posts))
+	for i := range posts {
+		byID[posts[i].ID] = &posts[i]
+	}
+
+	renderedPosts := make([]models.RenderedPost, 0, len(posts))
+	for _, id := range ids {
+		post, ok := byID[id]
+		if !ok {
+			continue
+		}
+		renderedPost, err := s.renderPost(post)
+		if err != nil {
+			return nil, fmt.Errorf("渲染文章失败 ID %d: %w", post.ID, err)
+		}
+		renderedPosts = append(renderedPosts, *renderedPost)
+	}
+	return renderedPosts, nil
+}
+
 func (s *PostService) SearchPostsPage(query string, page, pageSize int, isLoggedIn bool) ([]models.RenderedPost, int, error) {
 	re := regexp.MustCompile(`[\s,，]+`)
 	keywords := re.Split(strings.TrimSpace(query), -1)
@@ -376,7 +518,7 @@ func (s *PostService) SearchPostsPage(query string, page, pageSize int, isLogged
 
 func (s *PostService) renderPost(post *models.Post) (*models.RenderedPost, error) {
 	if post.ContentHTML == "" && post.Content != "" {
-		html, err := s.processAndRenderContent(post.Content)
+		html, err := s.processAndRenderContent(post.Content, post.TrustedHTML)
 		if err != nil {
 			fmt.Printf("按需渲染 Markdown 失败 for post ID %d: %v\n", post.ID, err)
 		} else {
@@ -395,6 +537,7 @@ func (s *PostService) renderPost(post *models.Post) (*models.RenderedPost, error
 		Body:        template.HTML(post.ContentHTML),
 		Excerpt:     post.Excerpt,
 		IsPrivate:   post.IsPrivate,
+		NoIndex:     post.NoIndex,
 	}
 	return renderedPost, nil
 }
@@ -439,6 +582,7 @@ func (s *PostService) GetAllPostsForBackup() ([]models.PostBackup, error) {
 			Title:       p.Title,
 			Content:     p.Content,
 			IsPrivate:   p.IsPrivate,
+			NoIndex:     p.NoIndex,
 			PublishedAt: p.PublishedAt,
 		}
 	}
@@ -447,12 +591,14 @@ func (s *PostService) GetAllPostsForBackup() ([]models.PostBackup, error) {
 
 func (s *PostService) CreatePostsFromBackup(posts []models.PostBackup) error {
 	newPosts := make([]models.Post, 0, len(posts))
+	now := time.Now()
 	for _, p := range posts {
 		slugStr, err := s.generateUniqueSlug(p.Title, 0)
 		if err != nil {
 			return fmt.Errorf("为导入的文章 '%s' 生成 slug 失败: %w", p.Title, err)
 		}
-		htmlContent, err := s.processAndRenderContent(p.Content)
+		// 导入的内容一律按不可信处理
+		htmlContent, err := s.processAndRenderContent(p.Content, false)
 		if err != nil {
 			return fmt.Errorf("为导入的文章 '%s' 渲染 HTML 失败: %w", p.Title, err)
 		}
@@ -462,10 +608,16 @@ func (s *PostService) CreatePostsFromBackup(posts []models.PostBackup) error {
 			Content:     p.Content,
 			ContentHTML: htmlContent,
 			IsPrivate:   p.IsPrivate,
+			NoIndex:     p.NoIndex,
 			PublishedAt: p.PublishedAt,
 			Excerpt:     utils.GenerateExcerpt(p.Content, 150),
 			Cover:       utils.ExtractFirstImageURL(p.Content), // 导入时也提取封面
 		})
+		// 恢复的已公开文章不再重复推送，定时文章仍在到期时发布
+		if !p.IsPrivate && !p.PublishedAt.After(now) {
+			announcedAt := p.PublishedAt
+			newPosts[len(newPosts)-1].AnnouncedAt = &announcedAt
+		}
 	}
 
 	if err := s.repo.CreateBatchFromBackup(newPosts); err != nil {
@@ -485,24 +637,48 @@ func (s *PostService) CreatePostsFromBackupStream(backupReader io.Reader) (int,
 		return 0, err
 	}
 
-	if len(backupData.Settings) > 0 {
-		if newPass, ok := backupData.Settings[constants.SettingPassword]; !ok || newPass == "" {
-			delete(backupData.Settings, constants.SettingPassword)
-		}
-		if err := s.settingService.UpdateSettings(backupData.Settings); err != nil {
-			return 0, fmt.Errorf("恢复设置失败: %w", err)
-		}
+	if err := s.RestoreSettings(backupData.Settings); err != nil {
+		return 0, err
 	}
-
 	return len(backupData.Posts), nil
 }
 
+// RestoreSettings saves the settings of a restored backup. Login credentials
+// and keys generated by the system are kept as they are.
+func (s *PostService) RestoreSettings(settings map[string]string) error {
+	StripBackupCredentials(settings)
+	if len(settings) == 0 {
+		return nil
+	}
+	if err := s.settingService.UpdateSettings(settings); err != nil {
+		return fmt.Errorf("恢复设置失败: %w", err)
+	}
+	// 恢复的设置可能修改了 HTML 过滤规则或站点地址
+	return s.RerenderAll()
+}
+
 func (s *PostService) BatchUpdatePosts(ids []uint, action string, isPrivate bool) error {
 	switch action {
 	case "delete":
-		return s.repo.DeleteByIDs(ids)
+		posts, err := s.repo.FindAllByIDs(ids)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.DeleteByIDs(ids); err != nil {
+			return err
+		}
+		for i := range posts {
+			s.publish(EventPostDeleted, &posts[i])
+		}
+		return nil
 	case "set-private":
-		return s.repo.UpdatePrivacyByIDs(ids, isPrivate)
+		if err := s.repo.UpdatePrivacyByIDs(ids, isPrivate); err != nil {
+			return err
+		}
+		if !isPrivate {
+			s.announceAfterSave()
+		}
+		return nil
 	default:
 		return fmt.Errorf("不支持的操作: %s", action)
 	}
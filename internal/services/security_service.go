@@ -0,0 +1,367 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CSPModeEnforce    = "enforce"
+	CSPModeReportOnly = "report-only"
+	CSPModeOff        = "off"
+
+	// CSPReportPath is where browsers send violation reports.
+	CSPReportPath = "/csp-report"
+
+	// 同一违规在窗口内只记一行并累加次数
+	cspReportDedupWindow = 24 * time.Hour
+	cspReportRetention   = 30 * 24 * time.Hour
+	// 报告接口无需认证，限制每分钟写入次数以免被刷库
+	cspReportRateLimit  = 60
+	cspReportFieldLimit = 512
+)
+
+var (
+	cspDirectivePattern = regexp.MustCompile(`^[a-z-]+$`)
+	// 来源中不能出现分隔符，否则可以借此注入新的指令
+	cspSourcePattern = regexp.MustCompile(`^[^;,\s'"]+$|^'[a-zA-Z0-9+/=_-]+'$`)
+)
+
+// cspDirective is one directive of a policy with its sources.
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+// SecurityService builds the security headers sent with every response from
+// the settings, and collects the CSP violation reports browsers send back.
+type SecurityService struct {
+	settingService *SettingService
+	reportRepo     *repository.CSPReportRepository
+
+	mu          sync.Mutex
+	windowStart time.Time
+	windowCount int
+}
+
+func NewSecurityService(settingService *SettingService, reportRepo *repository.CSPReportRepository) *SecurityService {
+	return &SecurityService{settingService: settingService, reportRepo: reportRepo}
+}
+
+// Headers returns the security headers for a response. nonce is allowed to
+// run inline scripts; https says whether the request arrived over TLS.
+func (s *SecurityService) Headers(nonce string, https bool) http.Header {
+	header := http.Header{}
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+	header.Set("Permissions-Policy", "camera=(), microphone=(), geolocation=(), payment=(), usb=(), browsing-topics=()")
+
+	if https {
+		maxAge, _ := s.settingService.GetSetting(constants.SettingHSTSMaxAge)
+		if seconds, err := strconv.Atoi(strings.TrimSpace(maxAge)); err == nil && seconds > 0 {
+			header.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(seconds))
+		}
+	}
+
+	frameAncestors, _ := s.settingService.GetSetting(constants.SettingFrameAncestors)
+	frameAncestors = strings.TrimSpace(frameAncestors)
+	mode, _ := s.settingService.GetSetting(constants.SettingCSPMode)
+	switch mode {
+	case CSPModeOff:
+	case CSPModeEnforce:
+		policy := s.contentSecurityPolicy(nonce)
+		if frameAncestors != "" {
+			policy += "; frame-ancestors " + frameAncestors
+		}
+		header.Set("Content-Security-Policy", policy)
+		header.Set("Reporting-Endpoints", `csp-endpoint="`+CSPReportPath+`"`)
+		return header
+	default:
+		// 未设置时不拦截，以免破坏信任 HTML 的文章
+		header.Set("Content-Security-Policy-Report-Only", s.contentSecurityPolicy(nonce))
+		header.Set("Reporting-Endpoints", `csp-endpoint="`+CSPReportPath+`"`)
+	}
+	// 浏览器会忽略仅报告策略中的 frame-ancestors，因此单独强制执行
+	if frameAncestors != "" {
+		header.Set("Content-Security-Policy", "frame-ancestors "+frameAncestors)
+	}
+	return header
+}
+
+// contentSecurityPolicy builds the policy without frame-ancestors.
+func (s *SecurityService) contentSecurityPolicy(nonce string) string {
+	frameSources := []string{}
+	hosts, _ := s.settingService.GetSetting(constants.SettingHTMLIframeHosts)
+	for _, host := range strings.FieldsFunc(hosts, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t' }) {
+		if cspSourcePattern.MatchString(host) {
+			frameSources = append(frameSources, "https://"+host)
+		}
+	}
+	if len(frameSources) == 0 {
+		frameSources = []string{"'none'"}
+	}
+
+	directives := []cspDirective{
+		{"default-src", []string{"'self'"}},
+		{"script-src", []string{"'self'", "'nonce-" + nonce + "'"}},
+		// 模板和脚本生成的元素使用了 style 属性
+		{"style-src", []string{"'self'", "'unsafe-inline'"}},
+		// 文章可以引用外部图片和音视频
+		{"img-src", []string{"'self'", "data:", "blob:", "https:"}},
+		{"media-src", []string{"'self'", "https:"}},
+		{"font-src", []string{"'self'", "data:"}},
+		{"connect-src", []string{"'self'"}},
+		{"frame-src", frameSources},
+		{"object-src", []string{"'none'"}},
+		{"base-uri", []string{"'self'"}},
+		{"form-action", []string{"'self'"}},
+	}
+
+	extra, _ := s.settingService.GetSetting(constants.SettingCSPExtra)
+	extraDirectives, err := parseCSPExtra(extra)
+	if err != nil {
+		log.Printf("CSP 附加来源无效，已忽略: %v", err)
+	}
+	for _, extraDirective := range extraDirectives {
+		merged := false
+		for i := range directives {
+			if directives[i].name != extraDirective.name {
+				continue
+			}
+			if len(directives[i].sources) == 1 && directives[i].sources[0] == "'none'" {
+				directives[i].sources = nil
+			}
+			directives[i].sources = append(directives[i].sources, extraDirective.sources...)
+			merged = true
+			break
+		}
+		if !merged {
+			directives = append(directives, extraDirective)
+		}
+	}
+
+	parts := make([]string, 0, len(directives)+2)
+	for _, directive := range directives {
+		parts = append(parts, directive.name+" "+strings.Join(directive.sources, " "))
+	}
+	parts = append(parts, "report-uri "+CSPReportPath, "report-to csp-endpoint")
+	return strings.Join(parts, "; ")
+}
+
+// parseCSPExtra parses the extra CSP sources setting: one directive per line
+// followed by the sources to add to it.
+func parseCSPExtra(raw string) ([]cspDirective, error) {
+	var directives []cspDirective
+	for _, line := range strings.Split(raw, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if !cspDirectivePattern.MatchString(name) || name == "frame-ancestors" || name == "report-uri" || name == "report-to" {
+			return nil, fmt.Errorf("无效的 CSP 指令: %s", fields[0])
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("CSP 指令 %s 缺少来源", name)
+		}
+		for _, source := range fields[1:] {
+			if !cspSourcePattern.MatchString(source) {
+				return nil, fmt.Errorf("无效的 CSP 来源: %s", source)
+			}
+		}
+		directives = append(directives, cspDirective{name: name, sources: fields[1:]})
+	}
+	return directives, nil
+}
+
+// ValidateSecuritySetting checks the value of a security header, audit or
+// single sign-on setting before it is saved. Other settings are accepted unchanged.
+func ValidateSecuritySetting(key, value string) error {
+	switch key {
+	case constants.SettingCSPMode:
+		if value != CSPModeEnforce && value != CSPModeReportOnly && value != CSPModeOff {
+			return errors.New("无效的 CSP 模式")
+		}
+	case constants.SettingCSPExtra:
+		_, err := parseCSPExtra(value)
+		return err
+	case constants.SettingFrameAncestors:
+		for _, source := range strings.Fields(value) {
+			if !cspSourcePattern.MatchString(source) {
+				return fmt.Errorf("无效的嵌入来源: %s", source)
+			}
+		}
+	case constants.SettingHSTSMaxAge:
+		if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err != nil || seconds < 0 {
+			return errors.New("HSTS 有效期必须是非负整数")
+		}
+	case constants.SettingAuditRetentionDays:
+		if days, err := strconv.Atoi(strings.TrimSpace(value)); err != nil || days < 0 {
+			return errors.New("审计日志保留天数必须是非负整数")
+		}
+	case constants.SettingOIDCIssuer:
+		if value = strings.TrimSpace(value); value != "" {
+			u, err := url.Parse(value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return errors.New("Issuer 必须是 http 或 https 地址")
+			}
+		}
+	}
+	return nil
+}
+
+// legacyCSPReport is the body of an application/csp-report request.
+type legacyCSPReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		BlockedURI         string `json:"blocked-uri"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		ScriptSample       string `json:"script-sample"`
+		Disposition        string `json:"disposition"`
+	} `json:"csp-report"`
+}
+
+// reportingAPIReport is one entry of an application/reports+json request.
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		Sample             string `json:"sample"`
+		Disposition        string `json:"disposition"`
+	} `json:"body"`
+}
+
+// RecordReports stores the violations in a report request body, which may
+// use either the report-uri or the Reporting API format.
+func (s *SecurityService) RecordReports(body []byte) error {
+	var reports []models.CSPReport
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		var entries []reportingAPIReport
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return fmt.Errorf("解析报告失败: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.Type != "csp-violation" {
+				continue
+			}
+			reports = append(reports, models.CSPReport{
+				DocumentURI: entry.Body.DocumentURL,
+				Directive:   entry.Body.EffectiveDirective,
+				BlockedURI:  entry.Body.BlockedURL,
+				SourceFile:  entry.Body.SourceFile,
+				LineNumber:  entry.Body.LineNumber,
+				Sample:      entry.Body.Sample,
+				Disposition: entry.Body.Disposition,
+			})
+		}
+	} else {
+		var legacy legacyCSPReport
+		if err := json.Unmarshal(body, &legacy); err != nil {
+			return fmt.Errorf("解析报告失败: %w", err)
+		}
+		directive := legacy.Report.EffectiveDirective
+		if directive == "" {
+			directive = legacy.Report.ViolatedDirective
+		}
+		reports = append(reports, models.CSPReport{
+			DocumentURI: legacy.Report.DocumentURI,
+			Directive:   directive,
+			BlockedURI:  legacy.Report.BlockedURI,
+			SourceFile:  legacy.Report.SourceFile,
+			LineNumber:  legacy.Report.LineNumber,
+			Sample:      legacy.Report.ScriptSample,
+			Disposition: legacy.Report.Disposition,
+		})
+	}
+
+	now := time.Now()
+	for i := range reports {
+		report := &reports[i]
+		if report.Directive == "" {
+			continue
+		}
+		if !s.allowReport(now) {
+			return nil
+		}
+		report.DocumentURI = truncateReportField(report.DocumentURI)
+		report.Directive = truncateReportField(report.Directive)
+		report.BlockedURI = truncateReportField(report.BlockedURI)
+		report.SourceFile = truncateReportField(report.SourceFile)
+		report.Sample = truncateReportField(report.Sample)
+		report.Disposition = truncateReportField(report.Disposition)
+		report.LastSeenAt = now
+		report.Count = 1
+
+		existed, err := s.reportRepo.IncrementSame(report, now.Add(-cspReportDedupWindow))
+		if err != nil {
+			return fmt.Errorf("保存报告失败: %w", err)
+		}
+		if !existed {
+			if err := s.reportRepo.Create(report); err != nil {
+				return fmt.Errorf("保存报告失败: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// allowReport applies the per-minute limit on stored reports.
+func (s *SecurityService) allowReport(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.windowStart) >= time.Minute {
+		s.windowStart, s.windowCount = now, 0
+	}
+	if s.windowCount >= cspReportRateLimit {
+		return false
+	}
+	s.windowCount++
+	return true
+}
+
+func truncateReportField(value string) string {
+	if runes := []rune(value); len(runes) > cspReportFieldLimit {
+		return string(runes[:cspReportFieldLimit])
+	}
+	return value
+}
+
+func (s *SecurityService) ListReports(page, pageSize int) ([]models.CSPReport, int64, error) {
+	return s.reportRepo.FindPage(page, pageSize)
+}
+
+func (s *SecurityService) ClearReports() error {
+	return s.reportRepo.DeleteAll()
+}
+
+// CleanupReports deletes old reports; it runs as a background job.
+func (s *SecurityService) CleanupReports() error {
+	count, err := s.reportRepo.DeleteBefore(time.Now().Add(-cspReportRetention))
+	if err != nil {
+		return fmt.Errorf("清理 CSP 报告失败: %w", err)
+	}
+	if count > 0 {
+		log.Printf("已清理 %d 条过期 CSP 报告", count)
+	}
+	return nil
+}
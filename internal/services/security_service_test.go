@@ -0,0 +1,29 @@
+package services
+
+import (
+	"glog/internal/constants"
+	"glog/internal/repository"
+	"testing"
+)
+
+func TestCSPModeDefaultsToReportOnly(t *testing.T) {
+	db := newTestDB(t)
+	settingService := newTestSettings(t, db, map[string]string{constants.SettingCSPMode: ""})
+	security := NewSecurityService(settingService, repository.NewCSPReportRepository(db))
+
+	header := security.Headers("nonce", false)
+	if header.Get("Content-Security-Policy-Report-Only") == "" {
+		t.Error("未设置 CSP 模式时应只报告")
+	}
+	if policy := header.Get("Content-Security-Policy"); policy != "frame-ancestors 'self'" {
+		t.Errorf("未设置 CSP 模式时只应强制执行 frame-ancestors，实际 %q", policy)
+	}
+
+	if err := settingService.UpdateSettings(map[string]string{constants.SettingCSPMode: CSPModeEnforce}); err != nil {
+		t.Fatal(err)
+	}
+	header = security.Headers("nonce", false)
+	if header.Get("Content-Security-Policy-Report-Only") != "" || header.Get("Content-Security-Policy") == "" {
+		t.Error("拦截模式下应发送 Content-Security-Policy")
+	}
+}
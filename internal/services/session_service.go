@@ -0,0 +1,317 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+)
+
+const (
+	// SessionIdleTimeout logs out sessions that have not been used for a while.
+	SessionIdleTimeout = 7 * 24 * time.Hour
+	// SessionMaxLifetime is the absolute lifetime of a session, counted from login.
+	SessionMaxLifetime = 30 * 24 * time.Hour
+
+	// 最近访问时间只需要大致准确，避免每个请求都写数据库
+	sessionTouchInterval = time.Minute
+	sessionSecretBytes   = 64
+)
+
+type clientIPKey struct{}
+
+// WithClientIP stores the client IP resolved by gin in the request context,
+// since the session store only sees the *http.Request.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func requestClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// SessionService is a gin-contrib sessions store that keeps session data in
+// the database. The cookie carries a random token signed with a key that is
+// generated on first run; the previous key stays valid after a rotation so
+// that rotating does not log everyone out.
+type SessionService struct {
+	repo           *repository.SessionRepository
+	settingService *SettingService
+	options        *gsessions.Options
+
+	mu     sync.RWMutex
+	codecs []securecookie.Codec
+}
+
+func NewSessionService(repo *repository.SessionRepository, settingService *SettingService) *SessionService {
+	return &SessionService{
+		repo:           repo,
+		settingService: settingService,
+		options:        &gsessions.Options{Path: "/", HttpOnly: true},
+	}
+}
+
+// LoadSecret loads the cookie signing keys, generating one on first run.
+func (s *SessionService) LoadSecret() error {
+	current, _ := s.settingService.GetSetting(constants.SettingSessionSecret)
+	previous, _ := s.settingService.GetSetting(constants.SettingSessionSecretPrevious)
+	if current == "" {
+		secret, err := generateSessionSecret()
+		if err != nil {
+			return err
+		}
+		if err := s.settingService.UpdateSettings(map[string]string{constants.SettingSessionSecret: secret}); err != nil {
+			return fmt.Errorf("保存会话密钥失败: %w", err)
+		}
+		log.Println("已生成新的会话签名密钥")
+		current = secret
+	}
+	return s.setCodecs(current, previous)
+}
+
+// RotateSecret signs new cookies with a fresh key. Cookies signed with the
+// key being replaced keep working until the next rotation.
+func (s *SessionService) RotateSecret() error {
+	current, _ := s.settingService.GetSetting(constants.SettingSessionSecret)
+	secret, err := generateSessionSecret()
+	if err != nil {
+		return err
+	}
+	if err := s.settingService.UpdateSettings(map[string]string{
+		constants.SettingSessionSecret:         secret,
+		constants.SettingSessionSecretPrevious: current,
+	}); err != nil {
+		return fmt.Errorf("保存会话密钥失败: %w", err)
+	}
+	return s.setCodecs(secret, current)
+}
+
+func generateSessionSecret() (string, error) {
+	secret := make([]byte, sessionSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("生成会话密钥失败: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func (s *SessionService) setCodecs(secrets ...string) error {
+	var codecs []securecookie.Codec
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		key, err := hex.DecodeString(secret)
+		if err != nil {
+			return fmt.Errorf("无效的会话密钥: %w", err)
+		}
+		codec := securecookie.New(key, nil)
+		codec.MaxAge(int(SessionMaxLifetime.Seconds()))
+		codecs = append(codecs, codec)
+	}
+	s.mu.Lock()
+	s.codecs = codecs
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *SessionService) getCodecs() []securecookie.Codec {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.codecs
+}
+
+// SessionID returns the database ID of the session with the given token.
+func SessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// List returns the sessions that are still valid, most recently used first.
+func (s *SessionService) List() ([]models.Session, error) {
+	now := time.Now()
+	return s.repo.FindActive(now.Add(-SessionIdleTimeout), now)
+}
+
+func (s *SessionService) Revoke(id string) error {
+	return s.repo.Delete(id)
+}
+
+// RevokeOthers logs out every session except the one with the given cookie
+// token, e.g. after the password was changed.
+func (s *SessionService) RevokeOthers(token string) error {
+	return s.repo.DeleteAllExcept(SessionID(token))
+}
+
+// RevokeAll logs out every session, including the current one.
+func (s *SessionService) RevokeAll() error {
+	return s.repo.DeleteAll()
+}
+
+// CleanupExpired deletes expired sessions; it runs as a background job.
+func (s *SessionService) CleanupExpired() error {
+	now := time.Now()
+	count, err := s.repo.DeleteExpired(now.Add(-SessionIdleTimeout), now)
+	if err != nil {
+		return fmt.Errorf("清理过期会话失败: %w", err)
+	}
+	if count > 0 {
+		log.Printf("已清理 %d 个过期会话", count)
+	}
+	return nil
+}
+
+func sessionExpired(record *models.Session, now time.Time) bool {
+	return !now.Before(record.ExpiresAt) || !now.Before(record.LastSeenAt.Add(SessionIdleTimeout))
+}
+
+// Options implements sessions.Store.
+func (s *SessionService) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+	if s.options.Path == "" {
+		s.options.Path = "/"
+	}
+}
+
+// Get implements sessions.Store.
+func (s *SessionService) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New implements sessions.Store. An invalid, revoked or expired cookie
+// yields an empty session rather than an error.
+func (s *SessionService) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	options := *s.options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var token string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &token, s.getCodecs()...); err != nil {
+		return session, nil
+	}
+	record, err := s.repo.FindByID(SessionID(token))
+	if err != nil {
+		return session, nil
+	}
+	now := time.Now()
+	if sessionExpired(record, now) {
+		s.repo.Delete(record.ID)
+		return session, nil
+	}
+	if err := decodeSessionValues(record.Data, &session.Values); err != nil {
+		log.Printf("读取会话数据失败: %v", err)
+		return session, nil
+	}
+	session.ID = token
+	session.IsNew = false
+
+	ip, userAgent := requestClientIP(r), r.UserAgent()
+	if now.Sub(record.LastSeenAt) > sessionTouchInterval || record.IP != ip || record.UserAgent != userAgent {
+		if err := s.repo.Touch(record.ID, ip, userAgent, now); err != nil {
+			log.Printf("更新会话访问时间失败: %v", err)
+		}
+	}
+	return session, nil
+}
+
+// Save implements sessions.Store. Empty sessions are not stored, so logging
+// out deletes the session. The token is replaced whenever the login state
+// changes, which prevents session fixation.
+func (s *SessionService) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge < 0 || len(session.Values) == 0 {
+		if session.ID != "" {
+			if err := s.repo.Delete(SessionID(session.ID)); err != nil {
+				return fmt.Errorf("删除会话失败: %w", err)
+			}
+			session.ID = ""
+		}
+		options := *session.Options
+		options.MaxAge = -1
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", &options))
+		return nil
+	}
+
+	now := time.Now()
+	record := &models.Session{CreatedAt: now, ExpiresAt: now.Add(SessionMaxLifetime)}
+	if session.ID != "" {
+		existing, err := s.repo.FindByID(SessionID(session.ID))
+		if err != nil {
+			// 会话在请求处理期间被撤销，不能重新写回
+			return nil
+		}
+		var stored map[interface{}]interface{}
+		if decodeSessionValues(existing.Data, &stored) == nil &&
+			stored[constants.SessionKeyAuthenticated] == session.Values[constants.SessionKeyAuthenticated] {
+			record = existing
+		} else {
+			if err := s.repo.Delete(existing.ID); err != nil {
+				return fmt.Errorf("删除会话失败: %w", err)
+			}
+			session.ID = ""
+		}
+	}
+	if session.ID == "" {
+		token := make([]byte, 32)
+		if _, err := rand.Read(token); err != nil {
+			return fmt.Errorf("生成会话令牌失败: %w", err)
+		}
+		session.ID = base64.RawURLEncoding.EncodeToString(token)
+	}
+
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(session.Values); err != nil {
+		return fmt.Errorf("编码会话数据失败: %w", err)
+	}
+	record.ID = SessionID(session.ID)
+	record.Data = data.Bytes()
+	record.IP = requestClientIP(r)
+	record.UserAgent = r.UserAgent()
+	record.LastSeenAt = now
+	if err := s.repo.Save(record); err != nil {
+		return fmt.Errorf("保存会话失败: %w", err)
+	}
+
+	codecs := s.getCodecs()
+	if len(codecs) == 0 {
+		return fmt.Errorf("会话密钥未加载")
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, codecs[0])
+	if err != nil {
+		return fmt.Errorf("签名会话 Cookie 失败: %w", err)
+	}
+	options := *session.Options
+	options.MaxAge = int(time.Until(record.ExpiresAt).Seconds())
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, &options))
+	return nil
+}
+
+func decodeSessionValues(data []byte, values *map[interface{}]interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(values)
+}
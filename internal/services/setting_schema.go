@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"glog/internal/constants"
+	"net/url"
+	"strings"
+)
+
+// SettingVisibility says where a setting may be shown.
+type SettingVisibility int
+
+const (
+	// SettingPublic settings are needed by public pages and are available to
+	// every template.
+	SettingPublic SettingVisibility = iota
+	// SettingAdmin settings are only shown on the settings page and over the API.
+	SettingAdmin
+	// SettingSecret settings are passwords, tokens and keys. They are never
+	// sent back to the browser and are encrypted at rest when a key is set.
+	SettingSecret
+)
+
+// settingSchema lists the visibility of each known setting. Settings that
+// are not listed are treated as admin-only.
+var settingSchema = map[string]SettingVisibility{
+	constants.SettingFavicon:           SettingPublic,
+	constants.SettingSiteTitle:         SettingPublic,
+	constants.SettingSiteDescription:   SettingPublic,
+	constants.SettingSiteURL:           SettingPublic,
+	constants.SettingSiteAuthor:        SettingPublic,
+	constants.SettingFeedContent:       SettingPublic,
+	constants.SettingAskEnabled:        SettingPublic,
+	constants.SettingWebmentionEnabled: SettingPublic,
+
+	constants.SettingPassword:               SettingSecret,
+	constants.SettingBackupPassword:         SettingSecret,
+	constants.SettingOpenAIToken:            SettingSecret,
+	constants.SettingGithubToken:            SettingSecret,
+	constants.SettingWebdavPassword:         SettingSecret,
+	constants.SettingBaiduPushAPI:           SettingSecret,
+	constants.SettingActivityPubKey:         SettingSecret,
+	constants.SettingMetaWeblogPasswordHash: SettingSecret,
+	constants.SettingSessionSecret:          SettingSecret,
+	constants.SettingSessionSecretPrevious:  SettingSecret,
+	constants.SettingTOTPSecret:             SettingSecret,
+	constants.SettingTOTPRecoveryCodes:      SettingSecret,
+	constants.SettingOIDCClientSecret:       SettingSecret,
+}
+
+// tokenWritableSettings are the settings an access token with the settings
+// scope may change: how the site looks and the public features. Credentials,
+// backup targets, security headers, the site address and anything else that
+// could hand over the site or its data need an admin session.
+var tokenWritableSettings = map[string]bool{
+	constants.SettingFavicon:              true,
+	constants.SettingSiteTitle:            true,
+	constants.SettingSiteDescription:      true,
+	constants.SettingSiteAuthor:           true,
+	constants.SettingFeedContent:          true,
+	constants.SettingRobotsTxt:            true,
+	constants.SettingAskEnabled:           true,
+	constants.SettingAskRateLimit:         true,
+	constants.SettingOpenAIModel:          true,
+	constants.SettingOpenAIEmbeddingModel: true,
+}
+
+// systemSettings hold keys and state generated by the system, which cannot
+// be changed through the settings form, the API or a restored backup.
+var systemSettings = map[string]bool{
+	constants.SettingActivityPubKey:         true,
+	constants.SettingBackupPasswordRandom:   true,
+	constants.SettingMetaWeblogPasswordHash: true,
+	constants.SettingIndexNowKey:            true,
+	constants.SettingPasswordChangeRequired: true,
+	constants.SettingSessionSecret:          true,
+	constants.SettingSessionSecretPrevious:  true,
+	constants.SettingTOTPSecret:             true,
+	constants.SettingTOTPLastStep:           true,
+	constants.SettingTOTPRecoveryCodes:      true,
+	constants.SettingWebAuthnUserID:         true,
+}
+
+// IsSystemSetting reports whether key is generated by the system and cannot
+// be written through the settings form, the API or a restored backup.
+func IsSystemSetting(key string) bool {
+	return systemSettings[key]
+}
+
+// TokenMayWrite reports whether an access token may change the setting key.
+func TokenMayWrite(key string) bool {
+	return tokenWritableSettings[key]
+}
+
+// SettingVisibilityOf returns the visibility of the setting key.
+func SettingVisibilityOf(key string) SettingVisibility {
+	if visibility, ok := settingSchema[key]; ok {
+		return visibility
+	}
+	return SettingAdmin
+}
+
+// siteURLFeatures are switches of features that must know the site's own
+// address. Without site_url they would fall back to the Host header, which
+// the client chooses.
+var siteURLFeatures = map[string]string{
+	constants.SettingWebmentionEnabled: "Webmention",
+	constants.SettingOIDCEnabled:       "单点登录",
+}
+
+// ValidateSettingDependencies checks the settings as they will be after
+// updates are applied to current. Only features whose switch or the site
+// address is part of updates are checked, so an unrelated change through
+// the API is not refused because of an older configuration.
+func ValidateSettingDependencies(current, updates map[string]string) error {
+	get := func(key string) string {
+		if value, ok := updates[key]; ok {
+			return value
+		}
+		return current[key]
+	}
+	siteURL, err := url.Parse(strings.TrimSpace(get(constants.SettingSiteURL)))
+	hasSiteURL := err == nil && (siteURL.Scheme == "http" || siteURL.Scheme == "https") && siteURL.Host != ""
+	_, siteURLUpdated := updates[constants.SettingSiteURL]
+	for key, feature := range siteURLFeatures {
+		if _, updated := updates[key]; !updated && !siteURLUpdated {
+			continue
+		}
+		if get(key) == "true" && !hasSiteURL {
+			return fmt.Errorf("开启 %s 前需要先设置站点地址", feature)
+		}
+	}
+	return nil
+}
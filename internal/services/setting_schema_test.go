@@ -0,0 +1,30 @@
+package services
+
+import (
+	"glog/internal/constants"
+	"testing"
+)
+
+func TestTokenMayWrite(t *testing.T) {
+	for _, key := range []string{constants.SettingSiteTitle, constants.SettingRobotsTxt} {
+		if !TokenMayWrite(key) {
+			t.Errorf("访问令牌应能修改 %s", key)
+		}
+	}
+	denied := []string{
+		constants.SettingPassword, constants.SettingBackupPassword, constants.SettingGithubRepo,
+		constants.SettingGithubToken, constants.SettingWebdavURL, constants.SettingCSPMode,
+		constants.SettingTrustedProxies, constants.SettingSiteURL, constants.SettingAuditRetentionDays,
+		constants.SettingOIDCAllowedEmails, constants.SettingOpenAIBaseURL, "unknown_setting",
+	}
+	for _, key := range denied {
+		if TokenMayWrite(key) {
+			t.Errorf("访问令牌不应能修改 %s", key)
+		}
+	}
+	for key, visibility := range settingSchema {
+		if visibility == SettingSecret && TokenMayWrite(key) {
+			t.Errorf("访问令牌不应能修改敏感设置 %s", key)
+		}
+	}
+}
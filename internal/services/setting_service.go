@@ -1,7 +1,9 @@
 package services
 
 import (
+	"fmt"
 	"glog/internal/repository"
+	"glog/internal/utils"
 	"log"
 	"sync"
 )
@@ -10,6 +12,8 @@ type SettingService struct {
 	repo         *repository.SettingRepository
 	settings     map[string]string
 	settingsLock sync.RWMutex
+	// cipher encrypts secret settings in the database; nil leaves them in plaintext.
+	cipher *utils.SecretCipher
 }
 
 func NewSettingService(repo *repository.SettingRepository) *SettingService {
@@ -21,6 +25,48 @@ func NewSettingService(repo *repository.SettingRepository) *SettingService {
 	return s
 }
 
+// InitEncryption sets up encryption of secret settings with passphrase, which
+// may be empty to store them in plaintext, and encrypts any secret settings
+// still stored in plaintext. It fails when stored values cannot be decrypted,
+// so that nothing overwrites them with new values.
+func (s *SettingService) InitEncryption(passphrase string) error {
+	if passphrase != "" {
+		cipher, err := utils.NewSecretCipher(passphrase)
+		if err != nil {
+			return err
+		}
+		s.cipher = cipher
+	}
+
+	stored, err := s.repo.GetAllSettings()
+	if err != nil {
+		return fmt.Errorf("加载设置失败: %w", err)
+	}
+	encrypted := 0
+	for key, value := range stored {
+		if utils.IsEncrypted(value) {
+			if s.cipher == nil {
+				return fmt.Errorf("设置 %s 已加密保存，请通过环境变量 GLOG_SETTINGS_KEY 提供密钥", key)
+			}
+			if _, err := s.cipher.Decrypt(key, value); err != nil {
+				return fmt.Errorf("解密设置 %s 失败，GLOG_SETTINGS_KEY 可能不正确", key)
+			}
+			continue
+		}
+		if s.cipher != nil && value != "" && SettingVisibilityOf(key) == SettingSecret {
+			if err := s.saveSetting(key, value); err != nil {
+				return err
+			}
+			encrypted++
+		}
+	}
+	if encrypted > 0 {
+		log.Printf("已加密 %d 项敏感设置", encrypted)
+	}
+	s.loadSettings()
+	return nil
+}
+
 func (s *SettingService) loadSettings() {
 	s.settingsLock.Lock()
 	defer s.settingsLock.Unlock()
@@ -30,10 +76,37 @@ func (s *SettingService) loadSettings() {
 		log.Printf("无法加载设置: %v", err)
 		return
 	}
+	for key, value := range settings {
+		if !utils.IsEncrypted(value) {
+			continue
+		}
+		// 无法解密时 InitEncryption 会拒绝启动，这里只在启动前短暂出现
+		settings[key] = ""
+		if s.cipher != nil {
+			if plaintext, err := s.cipher.Decrypt(key, value); err == nil {
+				settings[key] = plaintext
+			} else {
+				log.Printf("解密设置 %s 失败: %v", key, err)
+			}
+		}
+	}
 	s.settings = settings
 }
 
-// GetAllSettings retrieves all settings as a map from the cache.
+// saveSetting stores one setting, encrypting secrets when a key is configured.
+func (s *SettingService) saveSetting(key, value string) error {
+	if s.cipher != nil && value != "" && SettingVisibilityOf(key) == SettingSecret {
+		encrypted, err := s.cipher.Encrypt(key, value)
+		if err != nil {
+			return fmt.Errorf("加密设置 %s 失败: %w", key, err)
+		}
+		value = encrypted
+	}
+	return s.repo.UpdateSetting(key, value)
+}
+
+// GetAllSettings retrieves all settings as a map from the cache, including
+// secrets. Use PublicSettings or AdminSettings for anything shown to users.
 func (s *SettingService) GetAllSettings() (map[string]string, error) {
 	s.settingsLock.RLock()
 	defer s.settingsLock.RUnlock()
@@ -46,10 +119,48 @@ func (s *SettingService) GetAllSettings() (map[string]string, error) {
 	return settingsCopy, nil
 }
 
+// PublicSettings returns the settings that public pages may use.
+func (s *SettingService) PublicSettings() map[string]string {
+	return s.settingsUpTo(SettingPublic)
+}
+
+// AdminSettings returns all settings except secrets, for the settings page.
+func (s *SettingService) AdminSettings() map[string]string {
+	return s.settingsUpTo(SettingAdmin)
+}
+
+func (s *SettingService) settingsUpTo(visibility SettingVisibility) map[string]string {
+	s.settingsLock.RLock()
+	defer s.settingsLock.RUnlock()
+
+	settings := make(map[string]string)
+	for key, value := range s.settings {
+		if SettingVisibilityOf(key) <= visibility {
+			settings[key] = value
+		}
+	}
+	return settings
+}
+
+// ConfiguredSecrets reports which secret settings have a value, so that
+// pages can show whether they are set without revealing them.
+func (s *SettingService) ConfiguredSecrets() map[string]bool {
+	s.settingsLock.RLock()
+	defer s.settingsLock.RUnlock()
+
+	configured := make(map[string]bool)
+	for key, value := range s.settings {
+		if SettingVisibilityOf(key) == SettingSecret && value != "" {
+			configured[key] = true
+		}
+	}
+	return configured
+}
+
 // UpdateSettings updates multiple settings at once and refreshes the cache.
 func (s *SettingService) UpdateSettings(settings map[string]string) error {
 	for key, value := range settings {
-		if err := s.repo.UpdateSetting(key, value); err != nil {
+		if err := s.saveSetting(key, value); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/repository"
+	"strings"
+	"time"
+)
+
+// sitemapMaxURLs is the per-file limit defined by the sitemaps.org protocol.
+const sitemapMaxURLs = 50000
+
+// DefaultRobotsTxt is served when no custom robots.txt is configured.
+const DefaultRobotsTxt = `User-agent: *
+Disallow: /admin
+Disallow: /login`
+
+// SitemapService generates sitemap.xml and robots.txt. Only posts that are
+// public, already published and not marked noindex are listed.
+type SitemapService struct {
+	postRepo       *repository.PostRepository
+	settingService *SettingService
+}
+
+func NewSitemapService(postRepo *repository.PostRepository, settingService *SettingService) *SitemapService {
+	return &SitemapService{postRepo: postRepo, settingService: settingService}
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name         `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapPointer `xml:"sitemap"`
+}
+
+type sitemapPointer struct {
+	Loc string `xml:"loc"`
+}
+
+// Parts returns how many sitemap files are needed. The home page is always
+// the first URL of the first file.
+func (s *SitemapService) Parts() (int, error) {
+	count, err := s.postRepo.CountForSitemap()
+	if err != nil {
+		return 0, fmt.Errorf("统计文章失败: %w", err)
+	}
+	total := int(count) + 1
+	return (total + sitemapMaxURLs - 1) / sitemapMaxURLs, nil
+}
+
+// BuildIndex returns a sitemap index pointing at every sitemap file.
+func (s *SitemapService) BuildIndex(baseURL string, parts int) ([]byte, error) {
+	index := sitemapIndex{}
+	for i := 1; i <= parts; i++ {
+		index.Sitemaps = append(index.Sitemaps, sitemapPointer{Loc: fmt.Sprintf("%s/sitemap/%d.xml", baseURL, i)})
+	}
+	return marshalSitemap(index)
+}
+
+// BuildPart returns the 1-based sitemap file with up to 50,000 URLs.
+func (s *SitemapService) BuildPart(baseURL string, part int) ([]byte, error) {
+	start := (part - 1) * sitemapMaxURLs
+	end := start + sitemapMaxURLs
+
+	set := sitemapURLSet{}
+	if start == 0 {
+		set.URLs = append(set.URLs, sitemapURL{Loc: baseURL + "/"})
+		start = 1
+	}
+
+	// URL 序号减一即为文章的偏移量（首页占第一个位置）
+	posts, err := s.postRepo.FindForSitemap(start-1, end-start)
+	if err != nil {
+		return nil, fmt.Errorf("获取文章失败: %w", err)
+	}
+	for _, post := range posts {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     baseURL + "/post/" + post.Slug,
+			LastMod: post.UpdatedAt.In(time.UTC).Format(time.RFC3339),
+		})
+	}
+	return marshalSitemap(set)
+}
+
+func marshalSitemap(v interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("生成站点地图失败: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// BuildRobots returns the configured robots.txt with a Sitemap line appended
+// unless the configuration already contains one.
+func (s *SitemapService) BuildRobots(baseURL string) string {
+	robots, _ := s.settingService.GetSetting(constants.SettingRobotsTxt)
+	if strings.TrimSpace(robots) == "" {
+		robots = DefaultRobotsTxt
+	}
+	robots = strings.TrimSpace(strings.ReplaceAll(robots, "\r\n", "\n"))
+	if !strings.Contains(strings.ToLower(robots), "sitemap:") {
+		robots += "\n\nSitemap: " + baseURL + "/sitemap.xml"
+	}
+	return robots + "\n"
+}
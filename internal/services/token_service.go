@@ -0,0 +1,140 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"log"
+	"strings"
+	"time"
+)
+
+// Token scopes. The first four are named after the Micropub scopes, the
+// rest guard the REST API under /api/v1.
+const (
+	ScopeCreate = "create"
+	ScopeUpdate = "update"
+	ScopeDelete = "delete"
+	ScopeMedia  = "media"
+
+	ScopePostsRead  = "posts:read"
+	ScopePostsWrite = "posts:write"
+	ScopeBackup     = "backup"
+	ScopeSettings   = "settings"
+)
+
+// TokenScope describes a scope for the admin UI.
+type TokenScope struct {
+	Name  string
+	Label string
+}
+
+// TokenScopes lists the scopes a token can be granted, in display order.
+var TokenScopes = []TokenScope{
+	{ScopeCreate, "发布文章"},
+	{ScopeUpdate, "修改文章"},
+	{ScopeDelete, "删除文章"},
+	{ScopeMedia, "上传文件"},
+	{ScopePostsRead, "API 读取文章"},
+	{ScopePostsWrite, "API 发布文章"},
+	{ScopeBackup, "API 下载备份"},
+	{ScopeSettings, "API 读写设置（仅限外观等低风险项）"},
+}
+
+// tokenTouchInterval limits how often the last-used time is written.
+const tokenTouchInterval = time.Minute
+
+// tokenMaxValidDays bounds the expiry that can be chosen for a token.
+const tokenMaxValidDays = 3650
+
+var (
+	ErrTokenInvalid = errors.New("无效的访问令牌")
+	ErrTokenExpired = errors.New("访问令牌已过期")
+)
+
+// TokenService issues and checks bearer tokens for API clients.
+type TokenService struct {
+	repo *repository.TokenRepository
+}
+
+func NewTokenService(repo *repository.TokenRepository) *TokenService {
+	return &TokenService{repo: repo}
+}
+
+// Create issues a token with the given scopes that expires after validDays,
+// or never when validDays is 0. The plaintext token is returned once and only
+// its hash is stored.
+func (s *TokenService) Create(name string, scopes []string, validDays int) (string, *models.AccessToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("请填写令牌名称")
+	}
+	if validDays < 0 || validDays > tokenMaxValidDays {
+		return "", nil, fmt.Errorf("有效期必须在 0 到 %d 天之间，0 表示永不过期", tokenMaxValidDays)
+	}
+	var granted []string
+	for _, scope := range TokenScopes {
+		for _, requested := range scopes {
+			if requested == scope.Name {
+				granted = append(granted, scope.Name)
+				break
+			}
+		}
+	}
+	if len(granted) == 0 {
+		return "", nil, errors.New("请至少选择一项权限")
+	}
+
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", nil, fmt.Errorf("生成令牌失败: %w", err)
+	}
+	plaintext := "glog_" + hex.EncodeToString(random)
+
+	token := &models.AccessToken{
+		Name:      name,
+		TokenHash: hashCredential(plaintext),
+		Scopes:    strings.Join(granted, " "),
+	}
+	if validDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, validDays)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := s.repo.Create(token); err != nil {
+		return "", nil, fmt.Errorf("保存令牌失败: %w", err)
+	}
+	return plaintext, token, nil
+}
+
+// Authenticate returns the token matching plaintext and records its use.
+func (s *TokenService) Authenticate(plaintext string) (*models.AccessToken, error) {
+	if plaintext == "" {
+		return nil, ErrTokenInvalid
+	}
+	token, err := s.repo.FindByHash(hashCredential(plaintext))
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	now := time.Now()
+	if token.Expired(now) {
+		return nil, ErrTokenExpired
+	}
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > tokenTouchInterval {
+		if err := s.repo.TouchLastUsed(token.ID, now); err != nil {
+			log.Printf("更新令牌 ID %d 的使用时间失败: %v", token.ID, err)
+		}
+	}
+	return token, nil
+}
+
+func (s *TokenService) List() ([]models.AccessToken, error) {
+	return s.repo.FindAll()
+}
+
+func (s *TokenService) Revoke(id uint) error {
+	return s.repo.Delete(id)
+}
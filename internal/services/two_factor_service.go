@@ -0,0 +1,217 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"image/png"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	recoveryCodeCount = 10
+	totpPeriod        = 30
+	// 允许前后各一个周期的时钟误差
+	totpSkew = 1
+)
+
+var ErrInvalidTOTPCode = errors.New("验证码错误")
+
+// TOTPEnrollment is a freshly generated secret shown to the admin while
+// setting up two-factor authentication.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"`
+	QRCode string `json:"qr_code"` // PNG data URL
+}
+
+// TwoFactorService implements optional RFC 6238 TOTP for the admin login,
+// with one-time recovery codes. Recovery codes are stored as SHA-256 digests.
+type TwoFactorService struct {
+	settingService *SettingService
+	// 校验与消耗验证码需要原子进行，防止同一验证码被并发重放
+	mu sync.Mutex
+}
+
+func NewTwoFactorService(settingService *SettingService) *TwoFactorService {
+	return &TwoFactorService{settingService: settingService}
+}
+
+// Enabled reports whether logins require a second factor.
+func (s *TwoFactorService) Enabled() bool {
+	secret, _ := s.settingService.GetSetting(constants.SettingTOTPSecret)
+	return secret != ""
+}
+
+// NewEnrollment generates a secret for the setup dialog. Nothing is stored
+// until Enable confirms that the authenticator app produces valid codes.
+func (s *TwoFactorService) NewEnrollment() (*TOTPEnrollment, error) {
+	issuer, _ := s.settingService.GetSetting(constants.SettingSiteTitle)
+	if issuer == "" {
+		issuer = "Glog"
+	}
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: issuer, AccountName: "admin", Period: totpPeriod})
+	if err != nil {
+		return nil, fmt.Errorf("生成两步验证密钥失败: %w", err)
+	}
+	img, err := key.Image(240, 240)
+	if err != nil {
+		return nil, fmt.Errorf("生成二维码失败: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("生成二维码失败: %w", err)
+	}
+	return &TOTPEnrollment{
+		Secret: key.Secret(),
+		URL:    key.URL(),
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// Enable turns on two-factor authentication once code matches secret, and
+// returns the recovery codes, which are only shown this once.
+func (s *TwoFactorService) Enable(secret, code string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	step, ok := matchTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.settingService.UpdateSettings(map[string]string{
+		constants.SettingTOTPSecret:        secret,
+		constants.SettingTOTPLastStep:      strconv.FormatInt(step, 10),
+		constants.SettingTOTPRecoveryCodes: strings.Join(hashes, " "),
+	}); err != nil {
+		return nil, fmt.Errorf("保存两步验证设置失败: %w", err)
+	}
+	return codes, nil
+}
+
+// Disable turns off two-factor authentication and discards the recovery codes.
+func (s *TwoFactorService) Disable() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settingService.UpdateSettings(map[string]string{
+		constants.SettingTOTPSecret:        "",
+		constants.SettingTOTPLastStep:      "",
+		constants.SettingTOTPRecoveryCodes: "",
+	})
+}
+
+// Verify checks a code from the authenticator app or a recovery code. Each
+// TOTP code is accepted once and each recovery code is consumed on use.
+func (s *TwoFactorService) Verify(code string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, _ := s.settingService.GetSetting(constants.SettingTOTPSecret)
+	if secret == "" {
+		return false
+	}
+	code = strings.TrimSpace(code)
+
+	if step, ok := matchTOTP(secret, code, time.Now()); ok {
+		lastStep, _ := s.settingService.GetSetting(constants.SettingTOTPLastStep)
+		if last, err := strconv.ParseInt(lastStep, 10, 64); err == nil && step <= last {
+			return false
+		}
+		s.settingService.UpdateSettings(map[string]string{constants.SettingTOTPLastStep: strconv.FormatInt(step, 10)})
+		return true
+	}
+
+	stored, _ := s.settingService.GetSetting(constants.SettingTOTPRecoveryCodes)
+	hashes := strings.Fields(stored)
+	digest := hashRecoveryCode(code)
+	for i, hash := range hashes {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(digest)) == 1 {
+			remaining := append(hashes[:i:i], hashes[i+1:]...)
+			if err := s.settingService.UpdateSettings(map[string]string{constants.SettingTOTPRecoveryCodes: strings.Join(remaining, " ")}); err != nil {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// RecoveryCodesLeft returns the number of unused recovery codes.
+func (s *TwoFactorService) RecoveryCodesLeft() int {
+	stored, _ := s.settingService.GetSetting(constants.SettingTOTPRecoveryCodes)
+	return len(strings.Fields(stored))
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes with new ones.
+func (s *TwoFactorService) RegenerateRecoveryCodes() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if secret, _ := s.settingService.GetSetting(constants.SettingTOTPSecret); secret == "" {
+		return nil, errors.New("两步验证未启用")
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.settingService.UpdateSettings(map[string]string{constants.SettingTOTPRecoveryCodes: strings.Join(hashes, " ")}); err != nil {
+		return nil, fmt.Errorf("保存恢复码失败: %w", err)
+	}
+	return codes, nil
+}
+
+// matchTOTP returns the time step that code belongs to, allowing for clock skew.
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	if len(code) != 6 {
+		return 0, false
+	}
+	opts := totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+	for i := -totpSkew; i <= totpSkew; i++ {
+		t := now.Add(time.Duration(i*totpPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, t, opts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return t.Unix() / totpPeriod, true
+		}
+	}
+	return 0, false
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		random := make([]byte, 5)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, fmt.Errorf("生成恢复码失败: %w", err)
+		}
+		encoded := hex.EncodeToString(random)
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
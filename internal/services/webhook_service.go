@@ -0,0 +1,287 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DeliveryKindWebhook = "webhook"
+
+	// EventWebhookTest is only sent by the "send test" button.
+	EventWebhookTest = "webhook.test"
+
+	webhookMaxResponseBytes = 64 << 10
+	webhookLogSize          = 20
+)
+
+// WebhookEvent describes an event type for the admin UI.
+type WebhookEvent struct {
+	Name  string
+	Label string
+}
+
+// WebhookEvents lists the events a webhook can subscribe to, in display order.
+var WebhookEvents = []WebhookEvent{
+	{EventPostCreated, "文章创建"},
+	{EventPostUpdated, "文章更新"},
+	{EventPostPublished, "文章公开"},
+	{EventPostDeleted, "文章删除"},
+	{EventBackupSucceeded, "备份成功"},
+	{EventBackupFailed, "备份失败"},
+}
+
+var ErrWebhookNotFound = errors.New("Webhook 不存在")
+
+// WebhookService forwards site events to user-configured URLs. Payloads are
+// built when the event happens and delivered through the delivery queue,
+// signed with the webhook's secret at send time.
+type WebhookService struct {
+	repo           *repository.WebhookRepository
+	settingService *SettingService
+	queue          *DeliveryQueue
+	// Webhook 地址由管理员填写，可以指向内网中的服务，因此不使用投递队列的客户端
+	client *http.Client
+}
+
+// WebhookPayload is the JSON body POSTed to webhooks.
+type WebhookPayload struct {
+	Event string            `json:"event"`
+	Time  time.Time         `json:"time"`
+	Post  *WebhookPost      `json:"post,omitempty"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// WebhookPost is the post snapshot included in post events.
+type WebhookPost struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Slug        string    `json:"slug"`
+	URL         string    `json:"url"`
+	Content     string    `json:"content"`
+	IsPrivate   bool      `json:"is_private"`
+	PublishedAt time.Time `json:"published_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is an entry of the delivery log.
+type WebhookDelivery struct {
+	models.DeliveryJob
+	Event     string
+	WebhookID uint
+}
+
+func NewWebhookService(repo *repository.WebhookRepository, settingService *SettingService, queue *DeliveryQueue, eventBus *EventBus) *WebhookService {
+	s := &WebhookService{repo: repo, settingService: settingService, queue: queue, client: &http.Client{Timeout: deliveryTimeout}}
+	queue.Register(DeliveryKindWebhook, s.send)
+	eventBus.Subscribe(s.handleEvent)
+	return s
+}
+
+// Create adds a webhook. A random secret is generated when none is given.
+func (s *WebhookService) Create(rawURL, secret string, events []string) (*models.Webhook, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("请填写有效的 http(s) 地址")
+	}
+
+	var subscribed []string
+	for _, event := range WebhookEvents {
+		for _, requested := range events {
+			if requested == event.Name {
+				subscribed = append(subscribed, event.Name)
+				break
+			}
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil, errors.New("请至少选择一个事件")
+	}
+
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		random := make([]byte, 24)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("生成密钥失败: %w", err)
+		}
+		secret = hex.EncodeToString(random)
+	}
+
+	webhook := &models.Webhook{
+		URL:     rawURL,
+		Secret:  secret,
+		Events:  strings.Join(subscribed, " "),
+		Enabled: true,
+	}
+	if err := s.repo.Create(webhook); err != nil {
+		return nil, fmt.Errorf("保存 Webhook 失败: %w", err)
+	}
+	return webhook, nil
+}
+
+func (s *WebhookService) List() ([]models.Webhook, error) {
+	return s.repo.FindAll()
+}
+
+func (s *WebhookService) SetEnabled(id uint, enabled bool) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return ErrWebhookNotFound
+	}
+	return s.repo.UpdateEnabled(id, enabled)
+}
+
+func (s *WebhookService) Delete(id uint) error {
+	return s.repo.Delete(id)
+}
+
+// SendTest queues a test event for one webhook, whatever its event filter.
+func (s *WebhookService) SendTest(id uint) error {
+	webhook, err := s.repo.FindByID(id)
+	if err != nil {
+		return ErrWebhookNotFound
+	}
+	if !webhook.Enabled {
+		return errors.New("Webhook 已停用，请先启用")
+	}
+	body, err := json.Marshal(WebhookPayload{
+		Event: EventWebhookTest,
+		Time:  time.Now(),
+		Data:  map[string]string{"message": "这是一条来自 Glog 的测试消息"},
+	})
+	if err != nil {
+		return err
+	}
+	return s.queue.Enqueue(DeliveryKindWebhook, webhook.URL, string(body), strconv.FormatUint(uint64(webhook.ID), 10))
+}
+
+// Deliveries returns the most recent deliveries for the log in settings.
+func (s *WebhookService) Deliveries() ([]WebhookDelivery, error) {
+	jobs, _, err := s.queue.List([]string{DeliveryKindWebhook}, 1, webhookLogSize)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]WebhookDelivery, 0, len(jobs))
+	for _, job := range jobs {
+		delivery := WebhookDelivery{DeliveryJob: job}
+		var payload WebhookPayload
+		if json.Unmarshal([]byte(job.Payload), &payload) == nil {
+			delivery.Event = payload.Event
+		}
+		if id, err := strconv.ParseUint(job.Ref, 10, 64); err == nil {
+			delivery.WebhookID = uint(id)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+func (s *WebhookService) RetryDelivery(id uint) error {
+	return s.queue.Retry(id, []string{DeliveryKindWebhook})
+}
+
+func (s *WebhookService) handleEvent(event Event) {
+	webhooks, err := s.repo.FindEnabled()
+	if err != nil {
+		log.Printf("获取 Webhook 失败: %v", err)
+		return
+	}
+	var subscribers []models.Webhook
+	for _, webhook := range webhooks {
+		if webhook.Subscribes(event.Type) {
+			subscribers = append(subscribers, webhook)
+		}
+	}
+	if len(subscribers) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(s.buildPayload(event))
+	if err != nil {
+		log.Printf("生成 Webhook 内容失败: %v", err)
+		return
+	}
+	for _, webhook := range subscribers {
+		if err := s.queue.Enqueue(DeliveryKindWebhook, webhook.URL, string(body), strconv.FormatUint(uint64(webhook.ID), 10)); err != nil {
+			log.Printf("加入 Webhook ID %d 的投递任务失败: %v", webhook.ID, err)
+		}
+	}
+}
+
+func (s *WebhookService) buildPayload(event Event) WebhookPayload {
+	payload := WebhookPayload{Event: event.Type, Time: event.Time, Data: event.Data}
+	if post := event.Post; post != nil {
+		siteURL, _ := s.settingService.GetSetting(constants.SettingSiteURL)
+		payload.Post = &WebhookPost{
+			ID:          post.ID,
+			Title:       post.Title,
+			Slug:        post.Slug,
+			URL:         strings.TrimRight(siteURL, "/") + "/post/" + post.Slug,
+			Content:     post.Content,
+			IsPrivate:   post.IsPrivate,
+			PublishedAt: post.PublishedAt,
+			UpdatedAt:   post.UpdatedAt,
+		}
+	}
+	return payload
+}
+
+// SignWebhookPayload returns the value of the X-Glog-Signature-256 header:
+// the hex HMAC-SHA256 of the body keyed with the webhook secret.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookService) send(job *models.DeliveryJob) (int, error) {
+	id, err := strconv.ParseUint(job.Ref, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: 无效的 Webhook ID", ErrDeliveryPermanent)
+	}
+	webhook, err := s.repo.FindByID(uint(id))
+	if err != nil {
+		return 0, fmt.Errorf("%w: Webhook 已删除", ErrDeliveryPermanent)
+	}
+	if !webhook.Enabled {
+		return 0, fmt.Errorf("%w: Webhook 已停用", ErrDeliveryPermanent)
+	}
+
+	var payload WebhookPayload
+	json.Unmarshal([]byte(job.Payload), &payload)
+
+	body := []byte(job.Payload)
+	req, err := http.NewRequest(http.MethodPost, job.Target, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrDeliveryPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Glog-Webhook")
+	req.Header.Set("X-Glog-Event", payload.Event)
+	req.Header.Set("X-Glog-Delivery", strconv.FormatUint(uint64(job.ID), 10))
+	req.Header.Set("X-Glog-Signature-256", SignWebhookPayload(webhook.Secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookMaxResponseBytes))
+	return CheckDeliveryResponse(resp)
+}
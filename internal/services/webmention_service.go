@@ -0,0 +1,522 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"gorm.io/gorm"
+)
+
+const (
+	DeliveryKindWebmentionSend   = "webmention-send"
+	DeliveryKindWebmentionVerify = "webmention-verify"
+
+	webmentionMaxPageBytes  = 2 << 20
+	webmentionContentLength = 280
+)
+
+var (
+	ErrWebmentionDisabled = errors.New("Webmention 未开启")
+	// ErrWebmentionInvalid is returned for requests the receiver must reject with 400.
+	ErrWebmentionInvalid = errors.New("无效的 Webmention")
+)
+
+// WebmentionService implements both sides of the Webmention protocol. When a
+// post becomes public or is updated, every external link in it is notified;
+// incoming mentions are verified in the background and wait for moderation
+// before they are shown.
+type WebmentionService struct {
+	repo           *repository.WebmentionRepository
+	postRepo       *repository.PostRepository
+	settingService *SettingService
+	queue          *DeliveryQueue
+}
+
+// verifyPayload is the payload of a queued verification job.
+type verifyPayload struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	PostID uint   `json:"post_id"`
+}
+
+func NewWebmentionService(repo *repository.WebmentionRepository, postRepo *repository.PostRepository, settingService *SettingService, queue *DeliveryQueue, eventBus *EventBus) *WebmentionService {
+	s := &WebmentionService{
+		repo:           repo,
+		postRepo:       postRepo,
+		settingService: settingService,
+		queue:          queue,
+	}
+	queue.Register(DeliveryKindWebmentionSend, s.send)
+	queue.Register(DeliveryKindWebmentionVerify, s.verify)
+	eventBus.Subscribe(s.handleEvent)
+	return s
+}
+
+// Enabled reports whether sending and receiving mentions is switched on.
+// Mentions need the configured site address: the Host header of a request
+// is chosen by the sender and cannot tell which targets belong to this site.
+func (s *WebmentionService) Enabled() bool {
+	enabled, _ := s.settingService.GetSetting(constants.SettingWebmentionEnabled)
+	return enabled == "true" && s.baseURL() != nil
+}
+
+// baseURL returns the configured site address, or nil when it is not set.
+func (s *WebmentionService) baseURL() *url.URL {
+	siteURL, _ := s.settingService.GetSetting(constants.SettingSiteURL)
+	base, err := url.Parse(strings.TrimRight(siteURL, "/"))
+	if err != nil || base.Host == "" {
+		return nil
+	}
+	return base
+}
+
+// Receive validates an incoming mention and queues verification of the source.
+func (s *WebmentionService) Receive(source, target string) error {
+	if !s.Enabled() {
+		return ErrWebmentionDisabled
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil || (sourceURL.Scheme != "http" && sourceURL.Scheme != "https") || sourceURL.Host == "" {
+		return fmt.Errorf("%w: source 不是有效的网址", ErrWebmentionInvalid)
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil || (targetURL.Scheme != "http" && targetURL.Scheme != "https") {
+		return fmt.Errorf("%w: target 不是有效的网址", ErrWebmentionInvalid)
+	}
+	if source == target {
+		return fmt.Errorf("%w: source 与 target 相同", ErrWebmentionInvalid)
+	}
+	if base := s.baseURL(); base == nil || !strings.EqualFold(targetURL.Host, base.Host) {
+		return fmt.Errorf("%w: target 不属于本站", ErrWebmentionInvalid)
+	}
+	slug, ok := strings.CutPrefix(targetURL.Path, "/post/")
+	if !ok || slug == "" || strings.Contains(slug, "/") {
+		return fmt.Errorf("%w: target 不是文章地址", ErrWebmentionInvalid)
+	}
+	if unescaped, err := url.PathUnescape(slug); err == nil {
+		slug = unescaped
+	}
+	post, err := s.postRepo.FindBySlug(slug, false)
+	if err != nil {
+		return fmt.Errorf("%w: target 对应的文章不存在", ErrWebmentionInvalid)
+	}
+
+	// 同一提及已在等待验证时不再重复入队
+	pending, err := s.queue.HasPending(DeliveryKindWebmentionVerify, source, target)
+	if err != nil {
+		return fmt.Errorf("查询投递任务失败: %w", err)
+	}
+	if pending {
+		return nil
+	}
+
+	payload, err := json.Marshal(verifyPayload{Source: source, Target: target, PostID: post.ID})
+	if err != nil {
+		return err
+	}
+	return s.queue.Enqueue(DeliveryKindWebmentionVerify, source, string(payload), target)
+}
+
+// fetchPage GETs an HTML page and parses it, returning the response for its
+// status, headers and final URL.
+func (s *WebmentionService) fetchPage(rawURL string) (*http.Response, *goquery.Document, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrDeliveryPermanent, err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "Glog Webmention")
+
+	resp, err := s.queue.Client().Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, nil, nil
+	}
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, webmentionMaxPageBytes))
+	if err != nil {
+		return resp, nil, fmt.Errorf("解析页面失败: %w", err)
+	}
+	return resp, doc, nil
+}
+
+// verify fetches the source of a received mention and stores, updates or
+// removes the mention depending on whether the source still links to target.
+func (s *WebmentionService) verify(job *models.DeliveryJob) (int, error) {
+	var payload verifyPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrDeliveryPermanent, err)
+	}
+
+	resp, doc, err := s.fetchPage(payload.Source)
+	if err != nil {
+		return 0, err
+	}
+	if doc == nil {
+		// 来源页面已删除时同步移除对应的提及
+		if resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound {
+			if err := s.repo.DeleteBySourceTarget(payload.Source, payload.Target); err != nil {
+				return resp.StatusCode, fmt.Errorf("删除 Webmention 失败: %w", err)
+			}
+			return resp.StatusCode, nil
+		}
+		return CheckDeliveryResponse(resp)
+	}
+
+	sourceURL := resp.Request.URL
+	mentionType, linked := findMentionLink(doc, sourceURL, payload.Target)
+	if !linked {
+		if err := s.repo.DeleteBySourceTarget(payload.Source, payload.Target); err != nil {
+			return resp.StatusCode, fmt.Errorf("删除 Webmention 失败: %w", err)
+		}
+		return resp.StatusCode, fmt.Errorf("%w: 来源页面没有链接到目标文章", ErrDeliveryPermanent)
+	}
+
+	mention, err := s.repo.FindBySourceTarget(payload.Source, payload.Target)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mention = &models.Webmention{
+			PostID: payload.PostID,
+			Source: payload.Source,
+			Target: payload.Target,
+			Status: models.WebmentionPending,
+		}
+	} else if err != nil {
+		return resp.StatusCode, fmt.Errorf("查询 Webmention 失败: %w", err)
+	}
+	mention.Type = mentionType
+	fillMentionDetails(mention, doc, sourceURL)
+
+	if err := s.repo.Save(mention); err != nil {
+		return resp.StatusCode, fmt.Errorf("保存 Webmention 失败: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// normalizeMentionURL makes URLs comparable by dropping the fragment and a trailing slash.
+func normalizeMentionURL(u *url.URL) string {
+	clean := *u
+	clean.Fragment = ""
+	clean.Host = strings.ToLower(clean.Host)
+	return strings.TrimSuffix(clean.String(), "/")
+}
+
+// findMentionLink looks for a link to target in the source page and derives
+// the mention type from the microformats2 class of the linking element.
+func findMentionLink(doc *goquery.Document, sourceURL *url.URL, target string) (string, bool) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return "", false
+	}
+	want := normalizeMentionURL(targetURL)
+
+	mentionType, linked := "mention", false
+	doc.Find("a[href], link[href], img[src], video[src], audio[src]").EachWithBreak(func(i int, sel *goquery.Selection) bool {
+		ref, ok := sel.Attr("href")
+		if !ok {
+			ref, _ = sel.Attr("src")
+		}
+		u, err := sourceURL.Parse(strings.TrimSpace(ref))
+		if err != nil || normalizeMentionURL(u) != want {
+			return true
+		}
+		linked = true
+		switch {
+		case sel.HasClass("u-in-reply-to"):
+			mentionType = "reply"
+		case sel.HasClass("u-like-of"):
+			mentionType = "like"
+		case sel.HasClass("u-repost-of"):
+			mentionType = "repost"
+		case sel.HasClass("u-bookmark-of"):
+			mentionType = "bookmark"
+		default:
+			return true // 继续查找带有更明确类型的链接
+		}
+		return false
+	})
+	return mentionType, linked
+}
+
+// fillMentionDetails reads the author, title and text of the source's h-entry,
+// falling back to the page title.
+func fillMentionDetails(mention *models.Webmention, doc *goquery.Document, sourceURL *url.URL) {
+	entry := doc.Find(".h-entry").First()
+	if entry.Length() == 0 {
+		entry = doc.Selection
+	}
+
+	absolute := func(ref string) string {
+		if ref == "" {
+			return ""
+		}
+		u, err := sourceURL.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return ""
+		}
+		return u.String()
+	}
+
+	author := entry.Find(".p-author").First()
+	if author.Length() == 0 {
+		author = doc.Find(".h-card").First()
+	}
+	mention.AuthorName, mention.AuthorURL, mention.AuthorPhoto = "", "", ""
+	if author.Length() > 0 {
+		name := author.Find(".p-name").First()
+		if name.Length() == 0 {
+			name = author
+		}
+		mention.AuthorName = truncateRunes(strings.TrimSpace(name.Text()), 100)
+		if href, ok := author.Attr("href"); ok {
+			mention.AuthorURL = absolute(href)
+		} else if href, ok := author.Find(".u-url").First().Attr("href"); ok {
+			mention.AuthorURL = absolute(href)
+		}
+		if src, ok := author.Find(".u-photo").First().Attr("src"); ok {
+			mention.AuthorPhoto = absolute(src)
+		} else if src, ok := author.Attr("src"); ok && author.HasClass("u-photo") {
+			mention.AuthorPhoto = absolute(src)
+		}
+	}
+	if mention.AuthorName == "" {
+		mention.AuthorName = sourceURL.Host
+	}
+	if mention.AuthorURL == "" {
+		mention.AuthorURL = sourceURL.Scheme + "://" + sourceURL.Host + "/"
+	}
+
+	// 作者卡片里的 p-name 是作者名，不是标题
+	title := strings.TrimSpace(entry.Find(".p-name").FilterFunction(func(i int, sel *goquery.Selection) bool {
+		return sel.Closest(".h-card").Length() == 0
+	}).First().Text())
+	if title == "" {
+		title = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+	mention.Title = truncateRunes(title, 200)
+
+	content := entry.Find(".e-content, .p-content, .p-summary").First()
+	text := ""
+	if content.Length() > 0 {
+		content.Find("script, style").Remove()
+		text = strings.Join(strings.Fields(content.Text()), " ")
+	}
+	mention.Content = truncateRunes(text, webmentionContentLength)
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
+func (s *WebmentionService) handleEvent(event Event) {
+	switch event.Type {
+	case EventPostPublished:
+	case EventPostUpdated:
+		// 尚未公开的文章等到发布事件时再发送
+		if event.Post.AnnouncedAt == nil {
+			return
+		}
+	case EventPostDeleted:
+		if err := s.repo.DeleteByPostID(event.Post.ID); err != nil {
+			log.Printf("删除文章 ID %d 的 Webmention 失败: %v", event.Post.ID, err)
+		}
+		return
+	default:
+		return
+	}
+
+	if err := s.sendForPost(event.Post); err != nil {
+		log.Printf("发送文章 ID %d 的 Webmention 失败: %v", event.Post.ID, err)
+	}
+}
+
+// sendForPost queues a mention for every external link in the post.
+func (s *WebmentionService) sendForPost(post *models.Post) error {
+	if !s.Enabled() || post.IsPrivate {
+		return nil
+	}
+	base := s.baseURL()
+	source := base.String() + "/post/" + post.Slug
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(post.ContentHTML))
+	if err != nil {
+		return fmt.Errorf("解析文章内容失败: %w", err)
+	}
+	seen := make(map[string]bool)
+	var targets []string
+	doc.Find("a[href]").Each(func(i int, sel *goquery.Selection) {
+		href, _ := sel.Attr("href")
+		u, err := base.Parse(strings.TrimSpace(href))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || strings.EqualFold(u.Host, base.Host) {
+			return
+		}
+		u.Fragment = ""
+		if target := u.String(); !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	})
+
+	for _, target := range targets {
+		if err := s.queue.Enqueue(DeliveryKindWebmentionSend, target, source, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send discovers the Webmention endpoint of job.Target and notifies it. Pages
+// without an endpoint are skipped.
+func (s *WebmentionService) send(job *models.DeliveryJob) (int, error) {
+	resp, doc, err := s.fetchPage(job.Target)
+	if err != nil {
+		return 0, err
+	}
+	endpoint := discoverEndpoint(resp, doc)
+	if endpoint == "" {
+		if doc == nil {
+			return CheckDeliveryResponse(resp)
+		}
+		return resp.StatusCode, nil
+	}
+
+	form := url.Values{"source": {job.Payload}, "target": {job.Target}}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrDeliveryPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "Glog Webmention")
+
+	result, err := s.queue.Client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer result.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(result.Body, webmentionMaxPageBytes))
+	return CheckDeliveryResponse(result)
+}
+
+// discoverEndpoint finds the Webmention endpoint advertised by a page, first
+// in the Link header and then in <link> and <a> elements, in that order.
+func discoverEndpoint(resp *http.Response, doc *goquery.Document) string {
+	pageURL := resp.Request.URL
+	resolve := func(ref string) string {
+		u, err := pageURL.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return ""
+		}
+		return u.String()
+	}
+
+	for _, header := range resp.Header.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			ref, params, ok := strings.Cut(link, ";")
+			if !ok {
+				continue
+			}
+			ref = strings.Trim(strings.TrimSpace(ref), "<>")
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(key, "rel") && hasRel(strings.Trim(value, `"`)) {
+					return resolve(ref)
+				}
+			}
+		}
+	}
+
+	if doc == nil {
+		return ""
+	}
+	endpoint := ""
+	doc.Find("link[rel][href], a[rel][href]").EachWithBreak(func(i int, sel *goquery.Selection) bool {
+		rel, _ := sel.Attr("rel")
+		if !hasRel(rel) {
+			return true
+		}
+		href, _ := sel.Attr("href")
+		endpoint = resolve(href) // 空的 href 表示页面本身
+		return false
+	})
+	return endpoint
+}
+
+func hasRel(rel string) bool {
+	for _, value := range strings.Fields(rel) {
+		if strings.EqualFold(value, "webmention") {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovedForPost returns the mentions to display under a post.
+func (s *WebmentionService) ApprovedForPost(postID uint) ([]models.Webmention, error) {
+	return s.repo.FindApprovedByPost(postID)
+}
+
+// ListMentions returns a page of mentions for moderation together with the
+// posts they refer to, keyed by ID.
+func (s *WebmentionService) ListMentions(status string, page, pageSize int) ([]models.Webmention, int64, map[uint]models.Post, error) {
+	total, err := s.repo.Count(status)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("统计 Webmention 失败: %w", err)
+	}
+	mentions, err := s.repo.FindPage(status, page, pageSize)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("获取 Webmention 失败: %w", err)
+	}
+
+	ids := make([]uint, 0, len(mentions))
+	for _, mention := range mentions {
+		ids = append(ids, mention.PostID)
+	}
+	posts, err := s.postRepo.FindAllByIDs(ids)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("获取文章失败: %w", err)
+	}
+	postsByID := make(map[uint]models.Post, len(posts))
+	for _, post := range posts {
+		postsByID[post.ID] = post
+	}
+	return mentions, total, postsByID, nil
+}
+
+// CountPending returns the number of mentions awaiting moderation.
+func (s *WebmentionService) CountPending() int64 {
+	count, err := s.repo.Count(models.WebmentionPending)
+	if err != nil {
+		log.Printf("统计待审核 Webmention 失败: %v", err)
+	}
+	return count
+}
+
+func (s *WebmentionService) SetStatus(id uint, status string) error {
+	if status != models.WebmentionApproved && status != models.WebmentionRejected && status != models.WebmentionPending {
+		return fmt.Errorf("未知的状态: %s", status)
+	}
+	if _, err := s.repo.FindByID(id); err != nil {
+		return fmt.Errorf("Webmention 不存在: %w", err)
+	}
+	return s.repo.UpdateStatus(id, status)
+}
+
+func (s *WebmentionService) DeleteMention(id uint) error {
+	return s.repo.Delete(id)
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"glog/internal/constants"
+	"glog/internal/models"
+	"glog/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestWebmentionReceiveChecksTargetAgainstSiteURL(t *testing.T) {
+	db := newTestDB(t)
+	settingService := newTestSettings(t, db, map[string]string{constants.SettingWebmentionEnabled: "true"})
+	postRepo := repository.NewPostRepository(db)
+	if err := postRepo.Create(&models.Post{Title: "Hello", Slug: "hello", Content: "Hi", PublishedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	queue := NewDeliveryQueue(repository.NewDeliveryRepository(db))
+	service := NewWebmentionService(repository.NewWebmentionRepository(db), postRepo, settingService, queue, NewEventBus())
+
+	// 未设置站点地址时不接收提及，不能以请求的 Host 判断 target 是否属于本站
+	err := service.Receive("https://other.example/reply", "http://localhost/post/hello")
+	if !errors.Is(err, ErrWebmentionDisabled) {
+		t.Fatalf("未设置站点地址时应拒绝，实际 %v", err)
+	}
+
+	if err := settingService.UpdateSettings(map[string]string{constants.SettingSiteURL: "https://blog.example/"}); err != nil {
+		t.Fatal(err)
+	}
+	err = service.Receive("https://other.example/reply", "http://localhost/post/hello")
+	if !errors.Is(err, ErrWebmentionInvalid) {
+		t.Fatalf("target 不属于站点地址时应拒绝，实际 %v", err)
+	}
+	if err := service.Receive("https://other.example/reply", "https://blog.example/post/hello"); err != nil {
+		t.Fatalf("应接收指向本站文章的提及: %v", err)
+	}
+
+	// 等待验证时重复发送的提及不会再次入队
+	if err := service.Receive("https://other.example/reply", "https://blog.example/post/hello"); err != nil {
+		t.Fatalf("重复的提及应正常应答: %v", err)
+	}
+	if count, _ := repository.NewDeliveryRepository(db).Count([]string{DeliveryKindWebmentionVerify}); count != 1 {
+		t.Errorf("同一提及应只有一个验证任务，实际 %d 个", count)
+	}
+}
+
+func TestValidateSettingDependencies(t *testing.T) {
+	current := map[string]string{constants.SettingSiteURL: ""}
+	enable := map[string]string{constants.SettingWebmentionEnabled: "true"}
+	if err := ValidateSettingDependencies(current, enable); err == nil {
+		t.Error("未设置站点地址时不应允许开启 Webmention")
+	}
+	enable[constants.SettingSiteURL] = "https://blog.example"
+	if err := ValidateSettingDependencies(current, enable); err != nil {
+		t.Errorf("同时设置站点地址时应允许开启: %v", err)
+	}
+	current = map[string]string{constants.SettingSiteURL: "https://blog.example", constants.SettingWebmentionEnabled: "true"}
+	if err := ValidateSettingDependencies(current, map[string]string{constants.SettingSiteURL: ""}); err == nil {
+		t.Error("Webmention 开启时不应允许清空站点地址")
+	}
+	current = map[string]string{constants.SettingWebmentionEnabled: "true"}
+	if err := ValidateSettingDependencies(current, map[string]string{constants.SettingSiteTitle: "Blog"}); err != nil {
+		t.Errorf("不应因已有的配置拒绝无关的修改: %v", err)
+	}
+}
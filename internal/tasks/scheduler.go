@@ -17,9 +17,17 @@ type Scheduler struct {
 	cron           *cron.Cron
 	settingService *services.SettingService
 	backupService  *services.BackupService
+	jobs           []fixedJob
 	mu             sync.Mutex
 }
 
+// fixedJob is a background job that runs on a fixed schedule regardless of settings.
+type fixedJob struct {
+	name string
+	spec string
+	fn   func() error
+}
+
 func NewScheduler(settingService *services.SettingService, backupService *services.BackupService) *Scheduler {
 	return &Scheduler{
 		cron:           cron.New(),
@@ -28,10 +36,26 @@ func NewScheduler(settingService *services.SettingService, backupService *servic
 	}
 }
 
+// RegisterJob adds a background job that runs on the given cron spec. Jobs
+// must be registered before Start; they survive ReloadTasks.
+func (s *Scheduler) RegisterJob(name, spec string, fn func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, fixedJob{name: name, spec: spec, fn: fn})
+}
+
 func (s *Scheduler) Start() {
 	log.Println("定时备份调度器正在初始化...")
 	s.ReloadTasks()
 	s.cron.Start()
+
+	// 固定任务在启动时立即执行一次，补上停机期间错过的工作
+	s.mu.Lock()
+	jobs := append([]fixedJob(nil), s.jobs...)
+	s.mu.Unlock()
+	for _, job := range jobs {
+		go fixedJobFunc(job)()
+	}
 }
 
 func (s *Scheduler) ReloadTasks() {
@@ -72,6 +96,10 @@ func (s *Scheduler) ReloadTasks() {
 		return s.backupService.BackupToWebdav(url, user, password)
 	})
 
+	for _, job := range s.jobs {
+		s.addFixedTask(job)
+	}
+
 	if len(s.cron.Entries()) > 0 {
 		s.cron.Start()
 		log.Println("定时任务已重载并启动。")
@@ -114,6 +142,21 @@ func (s *Scheduler) addBackupTask(settings map[string]string, intervalKey, taskN
 	}
 }
 
+func (s *Scheduler) addFixedTask(job fixedJob) {
+	_, err := s.cron.AddFunc(job.spec, fixedJobFunc(job))
+	if err != nil {
+		log.Printf("添加 %s 任务失败: %v", job.name, err)
+	}
+}
+
+func fixedJobFunc(job fixedJob) func() {
+	return recoveryWrapper(func() {
+		if err := job.fn(); err != nil {
+			log.Printf("%s 任务执行失败: %v", job.name, err)
+		}
+	})
+}
+
 func recoveryWrapper(job func()) func() {
 	return func() {
 		defer func() {
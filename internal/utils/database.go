@@ -4,33 +4,69 @@ import (
 	"glog/internal/models"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
 )
 
+// DatabasePath returns the SQLite file path, taken from DB_PATH or defaulting
+// to glog.db next to the executable.
+func DatabasePath() (string, error) {
+	if dbPath := os.Getenv("DB_PATH"); dbPath != "" {
+		return dbPath, nil
+	}
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(exePath), "glog.db"), nil
+}
+
+// DataDir returns the directory holding the database, where generated files
+// such as caches are stored as well.
+func DataDir() (string, error) {
+	dbPath, err := DatabasePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(dbPath), nil
+}
+
 func InitDatabase() (*gorm.DB, error) {
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		exePath, err := os.Executable()
-		if err != nil {
-			return nil, err
-		}
-		dbPath = filepath.Join(filepath.Dir(exePath), "glog.db")
+	dbPath, err := DatabasePath()
+	if err != nil {
+		return nil, err
 	}
 	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
 
+	// 升级前已公开的文章视为已发布过，避免首次启动时把旧文章全部推送一遍
+	hadAnnouncedAt := !db.Migrator().HasTable(&models.Post{}) || db.Migrator().HasColumn(&models.Post{}, "AnnouncedAt")
+	existingSite := db.Migrator().HasTable(&models.Setting{})
+
 	// 自动迁移模式
-	err = db.AutoMigrate(&models.Post{}, &models.Setting{})
+	err = db.AutoMigrate(&models.Post{}, &models.Setting{}, &models.PostEmbedding{}, &models.DeliveryJob{}, &models.Follower{}, &models.Webmention{}, &models.AccessToken{}, &models.Webhook{}, &models.Session{}, &models.LoginAttempt{}, &models.Passkey{}, &models.CSPReport{}, &models.AuditLog{})
 	if err != nil {
 		return nil, err
 	}
 
+	if !hadAnnouncedAt {
+		// published_at 以上海时区的文本形式存储，比较时必须使用同一时区
+		loc, err := time.LoadLocation("Asia/Shanghai")
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Model(&models.Post{}).Where("is_private = ?", false).Where("published_at <= ?", time.Now().In(loc)).
+			Update("announced_at", gorm.Expr("published_at")).Error; err != nil {
+			return nil, err
+		}
+	}
+
 	// Seed the database with initial settings
-	if err := seedSettings(db); err != nil {
+	if err := seedSettings(db, existingSite); err != nil {
 		return nil, err
 	}
 
@@ -38,7 +74,8 @@ func InitDatabase() (*gorm.DB, error) {
 }
 
 // seedSettings populates the database with default settings if they don't exist.
-func seedSettings(db *gorm.DB) error {
+// existingSite says whether the database was created by an earlier version.
+func seedSettings(db *gorm.DB, existingSite bool) error {
 	defaultSettings := map[string]string{
 		"password":         "admin",
 		"favicon":          "",
@@ -46,6 +83,36 @@ func seedSettings(db *gorm.DB) error {
 		"openai_base_url":  "",
 		"openai_token":     "",
 		"openai_model":     "gemini-2.5-flash",
+		// 问答功能对外开放且消耗 AI 额度，默认关闭
+		"ask_enabled":    "false",
+		"ask_rate_limit": "10",
+		"feed_content":   "full",
+		// 联邦需要固定的站点地址，默认关闭
+		"activitypub_enabled":  "false",
+		"activitypub_username": "blog",
+		"webmention_enabled":   "false",
+		// 搜索引擎推送需要站点地址与对应平台的配置，默认关闭
+		"websub_hubs":       "",
+		"indexnow_enabled":  "false",
+		"indexnow_endpoint": "https://api.indexnow.org/indexnow",
+		"baidu_push_api":    "",
+		// 默认不信任任何代理，直接使用连接地址作为客户端 IP
+		"trusted_proxies": "",
+		// 文章中只允许嵌入常见视频网站的播放器
+		"html_iframe_hosts":    "www.youtube-nocookie.com\nwww.youtube.com\nplayer.bilibili.com\nplayer.vimeo.com",
+		"csp_mode":             "enforce",
+		"csp_extra":            "",
+		"frame_ancestors":      "'self'",
+		"hsts_max_age":         "31536000",
+		"audit_retention_days": "365",
+		// 单点登录需要先配置身份提供方和允许登录的账号，默认关闭
+		"oidc_enabled":      "false",
+		"oidc_name":         "单点登录",
+		"oidc_groups_claim": "groups",
+	}
+	// 已有站点中信任 HTML 的文章可能包含内联脚本，升级后 CSP 先只报告不拦截
+	if existingSite {
+		defaultSettings["csp_mode"] = "report-only"
 	}
 
 	for key, value := range defaultSettings {
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"glog/internal/models"
+	"path/filepath"
+	"testing"
+)
+
+func TestSeedCSPModeForExistingSites(t *testing.T) {
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "glog.db"))
+	cspMode := func() string {
+		db, err := InitDatabase()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			sqlDB, _ := db.DB()
+			sqlDB.Close()
+		}()
+		var setting models.Setting
+		db.Where("key = ?", "csp_mode").First(&setting)
+		value := setting.Value
+		// 模拟升级前没有这项设置的数据库
+		db.Unscoped().Where("key = ?", "csp_mode").Delete(&models.Setting{})
+		return value
+	}
+
+	if mode := cspMode(); mode != "enforce" {
+		t.Errorf("新站点应默认拦截，实际 %q", mode)
+	}
+	if mode := cspMode(); mode != "report-only" {
+		t.Errorf("已有站点升级后应默认只报告，实际 %q", mode)
+	}
+}
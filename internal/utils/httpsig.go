@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpSignatureMaxSkew bounds how far the Date header may be from now.
+const httpSignatureMaxSkew = 12 * time.Hour
+
+// GenerateRSAKeyPEM creates a 2048-bit RSA key encoded as PKCS#8 PEM.
+func GenerateRSAKeyPEM() (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParseRSAPrivateKeyPEM parses a PKCS#8 or PKCS#1 RSA private key.
+func ParseRSAPrivateKeyPEM(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("无效的 PEM 私钥")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+			return rsaKey, nil
+		}
+		return nil, errors.New("私钥不是 RSA 类型")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// PublicKeyPEM encodes the public half of key as PKIX PEM.
+func PublicKeyPEM(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// ParseRSAPublicKeyPEM parses a PKIX or PKCS#1 RSA public key.
+func ParseRSAPublicKeyPEM(data string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("无效的 PEM 公钥")
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		if rsaKey, ok := key.(*rsa.PublicKey); ok {
+			return rsaKey, nil
+		}
+		return nil, errors.New("公钥不是 RSA 类型")
+	}
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
+
+// BodyDigest returns the value of the Digest header for body.
+func BodyDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// SignRequest adds an HTTP Signature (draft-cavage-http-signatures, rsa-sha256)
+// to req, as expected by Mastodon and other ActivityPub servers. For requests
+// with a body the Digest header is set and signed as well.
+func SignRequest(req *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", BodyDigest(body))
+		headers = append(headers, "digest")
+	}
+
+	signingString, err := buildSigningString(req, headers)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(signingString))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+type httpSignature struct {
+	keyID     string
+	headers   []string
+	signature []byte
+	created   string
+	expires   string
+}
+
+func parseSignatureHeader(req *http.Request) (*httpSignature, error) {
+	value := req.Header.Get("Signature")
+	if value == "" {
+		if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Signature ") {
+			value = strings.TrimPrefix(auth, "Signature ")
+		}
+	}
+	if value == "" {
+		return nil, errors.New("缺少 Signature 请求头")
+	}
+
+	params := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		params[strings.ToLower(k)] = strings.Trim(v, `"`)
+	}
+
+	sig := &httpSignature{keyID: params["keyid"], created: params["created"], expires: params["expires"]}
+	if sig.keyID == "" {
+		return nil, errors.New("签名缺少 keyId")
+	}
+	if algorithm := params["algorithm"]; algorithm != "" && algorithm != "rsa-sha256" && algorithm != "hs2019" {
+		return nil, fmt.Errorf("不支持的签名算法: %s", algorithm)
+	}
+	sig.headers = strings.Fields(strings.ToLower(params["headers"]))
+	if len(sig.headers) == 0 {
+		sig.headers = []string{"date"}
+	}
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil || len(signature) == 0 {
+		return nil, errors.New("无效的签名值")
+	}
+	sig.signature = signature
+	return sig, nil
+}
+
+func buildSigningString(req *http.Request, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines = append(lines, "(request-target): "+strings.ToLower(req.Method)+" "+req.URL.RequestURI())
+		case "host":
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			lines = append(lines, "host: "+host)
+		default:
+			values := req.Header.Values(h)
+			if len(values) == 0 {
+				return "", fmt.Errorf("签名所需的请求头 %s 不存在", h)
+			}
+			lines = append(lines, h+": "+strings.Join(values, ", "))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// SignatureKeyID returns the keyId of the request's HTTP Signature.
+func SignatureKeyID(req *http.Request) (string, error) {
+	sig, err := parseSignatureHeader(req)
+	if err != nil {
+		return "", err
+	}
+	return sig.keyID, nil
+}
+
+// VerifyRequest checks the HTTP Signature of an incoming request against key.
+// The signature must cover the request target and a fresh Date (or created)
+// value, and for requests with a body a matching Digest.
+func VerifyRequest(req *http.Request, body []byte, key *rsa.PublicKey) error {
+	sig, err := parseSignatureHeader(req)
+	if err != nil {
+		return err
+	}
+
+	covered := make(map[string]bool, len(sig.headers))
+	for _, h := range sig.headers {
+		covered[h] = true
+	}
+	if !covered["(request-target)"] {
+		return errors.New("签名未覆盖 (request-target)")
+	}
+
+	var signedAt time.Time
+	switch {
+	case covered["date"]:
+		signedAt, err = http.ParseTime(req.Header.Get("Date"))
+		if err != nil {
+			return errors.New("无效的 Date 请求头")
+		}
+	case covered["(created)"]:
+		created, err := strconv.ParseInt(sig.created, 10, 64)
+		if err != nil {
+			return errors.New("无效的 created 参数")
+		}
+		signedAt = time.Unix(created, 0)
+	default:
+		return errors.New("签名未覆盖 Date")
+	}
+	if skew := time.Since(signedAt); skew > httpSignatureMaxSkew || skew < -httpSignatureMaxSkew {
+		return errors.New("签名已过期")
+	}
+
+	if len(body) > 0 {
+		if !covered["digest"] {
+			return errors.New("签名未覆盖 Digest")
+		}
+		if req.Header.Get("Digest") != BodyDigest(body) {
+			return errors.New("Digest 与请求体不匹配")
+		}
+	}
+
+	lines := make([]string, 0, len(sig.headers))
+	for _, h := range sig.headers {
+		switch h {
+		case "(created)":
+			lines = append(lines, "(created): "+sig.created)
+		case "(expires)":
+			lines = append(lines, "(expires): "+sig.expires)
+		default:
+			line, err := buildSigningString(req, []string{h})
+			if err != nil {
+				return err
+			}
+			lines = append(lines, line)
+		}
+	}
+	hash := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig.signature); err != nil {
+		return errors.New("签名校验失败")
+	}
+	return nil
+}
@@ -3,10 +3,12 @@ package utils
 import (
 	"bytes"
 	"html/template"
+	"net/url"
 	"regexp"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/microcosm-cc/bluemonday"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
@@ -26,48 +28,116 @@ func init() {
 			html.WithXHTML(),
 			// 启用 Unsafe 选项是为了让 Markdown 表格的 align 属性能够被渲染
 			// 这对于实现表格列的对齐功能是必需的。
-			// 警告：这会允许在 Markdown 中使用原始 HTML，如果 Markdown 内容来自不受信任的用户，可能存在 XSS 风险。
-			// 在这个博客项目中，内容是博主自己控制的，所以风险较低。
+			// 原始 HTML 随后由 sanitizePolicy 过滤，只有标记为可信的文章才原样输出。
 			html.WithUnsafe(),
 		),
 	)
 }
 
-// wrapTablesInDiv 使用 goquery 解析 HTML，并为每个 table 元素包裹一个带 class 的 div。
-// 这是为了在后端处理响应式表格，避免前端 JS 操作 DOM 导致的页面闪烁。
-func wrapTablesInDiv(htmlContent string) (string, error) {
-	// 从字符串读取 HTML
+// HTMLPolicy controls how RenderMarkdown sanitizes raw HTML in posts.
+type HTMLPolicy struct {
+	// Trusted skips sanitization, for posts whose raw HTML the owner vouches for.
+	Trusted bool
+	// IframeHosts lists the hosts that iframe embeds may load from.
+	IframeHosts []string
+	// SiteHost is the host of the site; links to other hosts are external.
+	SiteHost string
+}
+
+var sanitizePolicy = newSanitizePolicy()
+
+// newSanitizePolicy allows the HTML that goldmark generates plus common
+// formatting. Iframes pass here and are checked against the host allowlist
+// in postProcessHTML.
+func newSanitizePolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// UGCPolicy 默认给链接加 nofollow，站长自己写的文章不需要
+	p.RequireNoFollowOnLinks(false)
+	// Prism 根据 language-* 类名高亮代码
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	// XHTML 模式下表格对齐以 style 输出
+	p.AllowStyles("text-align").MatchingEnum("left", "center", "right").OnElements("th", "td")
+	// GFM 任务列表
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^(|checked|disabled)$`)).OnElements("input")
+	p.AllowAttrs("rel").Matching(bluemonday.SpaceSeparatedTokens).OnElements("a")
+	p.AllowAttrs("src").OnElements("iframe")
+	p.AllowAttrs("width", "height").Matching(bluemonday.NumberOrPercent).OnElements("iframe")
+	p.AllowAttrs("title").Matching(bluemonday.Paragraph).OnElements("iframe")
+	p.AllowAttrs("allow").Matching(regexp.MustCompile(`^[a-z\- ;]+$`)).OnElements("iframe")
+	p.AllowAttrs("allowfullscreen", "frameborder", "loading", "scrolling").Matching(regexp.MustCompile(`^[a-z0-9]*$`)).OnElements("iframe")
+	return p
+}
+
+// postProcessHTML parses the generated HTML once to:
+//   - wrap every table in a div, so that wide tables scroll on small screens
+//     without JS rearranging the DOM and making the page flicker;
+//   - drop iframes whose host is not allowed, unless the post is trusted;
+//   - add rel="noopener noreferrer" to links that leave the site.
+func postProcessHTML(htmlContent string, policy HTMLPolicy) (string, error) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
 	if err != nil {
 		return "", err
 	}
 
-	// 查找所有的 table 元素并用 div 包裹它们
 	doc.Find("table").Each(func(i int, s *goquery.Selection) {
 		s.WrapHtml(`<div class="table-wrapper"></div>`)
 	})
 
-	// 将修改后的 HTML 写回字符串
-	newHtml, err := doc.Html()
-	if err != nil {
-		return "", err
+	if !policy.Trusted {
+		doc.Find("iframe").Each(func(i int, s *goquery.Selection) {
+			src, _ := s.Attr("src")
+			u, err := url.Parse(src)
+			if err != nil || u.Scheme != "https" || !containsFold(policy.IframeHosts, u.Hostname()) {
+				s.Remove()
+			}
+		})
 	}
 
-	return newHtml, nil
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		u, err := url.Parse(href)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || strings.EqualFold(u.Hostname(), policy.SiteHost) {
+			return
+		}
+		rel, _ := s.Attr("rel")
+		tokens := strings.Fields(rel)
+		for _, required := range []string{"noopener", "noreferrer"} {
+			if !containsFold(tokens, required) {
+				tokens = append(tokens, required)
+			}
+		}
+		s.SetAttr("rel", strings.Join(tokens, " "))
+	})
+
+	// goquery 会补全 html/body 结构，只取 body 内部的内容
+	return doc.Find("body").Html()
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
 }
 
 // RenderMarkdown 将 markdown 字符串转换为处理过的 HTML 模板。
-func RenderMarkdown(mdStr string) (template.HTML, error) {
+func RenderMarkdown(mdStr string, policy HTMLPolicy) (template.HTML, error) {
 	var buf bytes.Buffer
 	if err := md.Convert([]byte(mdStr), &buf); err != nil {
 		return "", err
 	}
 
-	// 在返回之前，先对生成的 HTML 进行处理，为表格添加包裹 div
-	processedHtml, err := wrapTablesInDiv(buf.String())
+	rendered := buf.String()
+	if !policy.Trusted {
+		rendered = sanitizePolicy.Sanitize(rendered)
+	}
+
+	processedHtml, err := postProcessHTML(rendered, policy)
 	if err != nil {
-		// 如果处理失败，可以选择返回原始 HTML 或错误
-		// 这里我们选择返回错误，以便于调试
 		return "", err
 	}
 
@@ -113,6 +183,41 @@ func GenerateExcerpt(md string, length int) string {
 	return string(runes)
 }
 
+// Summarize returns the excerpt before the <!--more--> separator, or the
+// beginning of the plain text when the post has no separator.
+func Summarize(md string, length int) string {
+	if excerpt := GenerateExcerpt(md, length); excerpt != "" {
+		return excerpt
+	}
+	plainText := strings.TrimSpace(stripMarkdown(md))
+	runes := []rune(plainText)
+	if len(runes) > length {
+		return string(runes[:length]) + "..."
+	}
+	return plainText
+}
+
+// AbsolutizeURLs rewrites root-relative href and src attributes in an HTML
+// fragment so that it still works outside the site, e.g. in feed readers.
+func AbsolutizeURLs(htmlContent, baseURL string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	for _, attr := range []string{"href", "src"} {
+		doc.Find("[" + attr + "]").Each(func(i int, s *goquery.Selection) {
+			value, _ := s.Attr(attr)
+			if strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//") {
+				s.SetAttr(attr, baseURL+value)
+			}
+		})
+	}
+
+	// goquery 会补全 html/body 结构，只取 body 内部的内容
+	return doc.Find("body").Html()
+}
+
 // ExtractFirstImageURL uses a regular expression to find the first Markdown image URL.
 func ExtractFirstImageURL(md string) string {
 	// Regex to find the first markdown image: ![alt text](image_url)
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+var testHTMLPolicy = HTMLPolicy{IframeHosts: []string{"www.youtube.com"}, SiteHost: "blog.example"}
+
+func renderHTML(t *testing.T, markdown string, policy HTMLPolicy) string {
+	t.Helper()
+	html, err := RenderMarkdown(markdown, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(html)
+}
+
+func TestRenderMarkdownSanitizesHTML(t *testing.T) {
+	cases := []struct {
+		name, markdown string
+		forbidden      []string
+	}{
+		{"脚本", "hello\n\n<script>alert(1)</script>", []string{"<script", "alert(1)"}},
+		{"事件属性", `<img src="/a.png" onerror="alert(1)">`, []string{"onerror", "alert(1)"}},
+		{"javascript 链接", "[click](javascript:alert(1))\n\n<a href=\"javascript:alert(1)\">raw</a>", []string{"javascript:"}},
+		{"不在白名单的 iframe", `<iframe src="https://evil.example/embed"></iframe>`, []string{"<iframe", "evil.example"}},
+		{"http iframe", `<iframe src="http://www.youtube.com/embed/x"></iframe>`, []string{"<iframe"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			html := renderHTML(t, tc.markdown, testHTMLPolicy)
+			for _, s := range tc.forbidden {
+				if strings.Contains(html, s) {
+					t.Errorf("渲染结果不应包含 %q: %s", s, html)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownKeepsAllowedContent(t *testing.T) {
+	html := renderHTML(t, `<iframe src="https://www.youtube.com/embed/x" allowfullscreen></iframe>`, testHTMLPolicy)
+	if !strings.Contains(html, `src="https://www.youtube.com/embed/x"`) {
+		t.Errorf("应保留白名单中的 https iframe: %s", html)
+	}
+
+	html = renderHTML(t, "[外部](https://other.example/) [站内](https://blog.example/post/a) [相对](/post/b)", testHTMLPolicy)
+	if !strings.Contains(html, `href="https://other.example/" rel="noopener noreferrer"`) {
+		t.Errorf("外部链接应添加 noopener noreferrer: %s", html)
+	}
+	if strings.Count(html, "noopener") != 1 {
+		t.Errorf("站内链接不应添加 rel: %s", html)
+	}
+}
+
+func TestRenderMarkdownTrustedPassesRawHTML(t *testing.T) {
+	raw := `<div onclick="go()"><script>track()</script><iframe src="https://evil.example/embed"></iframe></div>`
+	html := renderHTML(t, raw, HTMLPolicy{Trusted: true, IframeHosts: testHTMLPolicy.IframeHosts})
+	for _, s := range []string{`onclick="go()"`, "<script>track()</script>", `src="https://evil.example/embed"`} {
+		if !strings.Contains(html, s) {
+			t.Errorf("可信文章应原样保留 %q: %s", s, html)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is an in-memory sliding-window limiter keyed by an arbitrary
+// string such as a client IP. It is meant for low-volume endpoints of a single
+// instance and forgets everything on restart.
+type RateLimiter struct {
+	mu        sync.Mutex
+	hits      map[string][]time.Time
+	lastSweep time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{hits: make(map[string][]time.Time)}
+}
+
+// Allow records a hit for key and reports whether it stays within limit hits
+// per window. A non-positive limit disables limiting.
+func (l *RateLimiter) Allow(key string, limit int, window time.Duration) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-window)
+
+	// 定期清理已过期的记录，防止 map 无限增长
+	if now.Sub(l.lastSweep) > window {
+		for k, times := range l.hits {
+			if len(times) == 0 || times[len(times)-1].Before(cutoff) {
+				delete(l.hits, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	times := l.hits[key]
+	kept := times[:0]
+	for _, t := range times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) >= limit {
+		l.hits[key] = kept
+		return false
+	}
+	l.hits[key] = append(kept, now)
+	return true
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when a request to a remote server resolves
+// to an address inside the local network.
+var ErrPrivateAddress = errors.New("不允许访问本机或内网地址")
+
+// reservedRanges are non-public ranges that net.IP has no method for.
+var reservedRanges = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),   // 运营商级 NAT（RFC 6598）
+	mustParseCIDR("192.0.0.0/24"),    // IETF 协议分配（RFC 6890）
+	mustParseCIDR("198.18.0.0/15"),   // 网络设备测试（RFC 2544）
+	mustParseCIDR("192.0.2.0/24"),    // 文档示例（RFC 5737）
+	mustParseCIDR("198.51.100.0/24"), // 文档示例（RFC 5737）
+	mustParseCIDR("203.0.113.0/24"),  // 文档示例（RFC 5737）
+	mustParseCIDR("64:ff9b::/96"),    // NAT64，可经网关访问内网 IPv4（RFC 6052）
+	mustParseCIDR("2001:db8::/32"),   // 文档示例（RFC 3849）
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+// IsPublicIP reports whether ip is a globally reachable unicast address.
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, ipNet := range reservedRanges {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewPublicHTTPClient returns an HTTP client for requests whose target is
+// chosen by a remote party, such as ActivityPub actors and Webmention
+// sources. The address is checked after DNS resolution when dialing, so
+// host names and redirects pointing into the local network are refused too.
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second, Control: publicAddressControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// 经代理转发时只能检查代理本身的地址，因此不使用环境变量中的代理
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func publicAddressControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
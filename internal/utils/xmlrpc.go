@@ -0,0 +1,285 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XML-RPC values are decoded to string, int, bool, float64, time.Time,
+// []byte (base64), []interface{} (array) and map[string]interface{} (struct).
+
+const xmlrpcTimeFormat = "20060102T15:04:05"
+
+// ParseXMLRPCCall decodes a <methodCall> document.
+func ParseXMLRPCCall(r io.Reader) (string, []interface{}, error) {
+	dec := xml.NewDecoder(r)
+	var method string
+	var params []interface{}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("解析 XML-RPC 请求失败: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "methodName":
+			var name string
+			if err := dec.DecodeElement(&name, &start); err != nil {
+				return "", nil, fmt.Errorf("解析 methodName 失败: %w", err)
+			}
+			method = strings.TrimSpace(name)
+		case "value":
+			value, err := decodeXMLRPCValue(dec)
+			if err != nil {
+				return "", nil, err
+			}
+			params = append(params, value)
+		}
+	}
+
+	if method == "" {
+		return "", nil, errors.New("缺少 methodName")
+	}
+	return method, params, nil
+}
+
+// decodeXMLRPCValue reads the content of a <value> element whose start tag
+// has already been consumed, up to and including its end tag.
+func decodeXMLRPCValue(dec *xml.Decoder) (interface{}, error) {
+	var text strings.Builder
+	var value interface{}
+	typed := false
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("解析 XML-RPC 值失败: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if !typed {
+				// 没有类型标签的值按字符串处理
+				return text.String(), nil
+			}
+			return value, nil
+		case xml.StartElement:
+			typed = true
+			value, err = decodeXMLRPCTyped(dec, t)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func decodeXMLRPCTyped(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "struct":
+		return decodeXMLRPCStruct(dec)
+	case "array":
+		return decodeXMLRPCArray(dec)
+	}
+
+	var raw string
+	if err := dec.DecodeElement(&raw, &start); err != nil {
+		return nil, fmt.Errorf("解析 XML-RPC 值失败: %w", err)
+	}
+	switch start.Name.Local {
+	case "string":
+		return raw, nil
+	case "int", "i4", "i8":
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("无效的整数: %s", raw)
+		}
+		return n, nil
+	case "boolean":
+		return strings.TrimSpace(raw) == "1", nil
+	case "double":
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的浮点数: %s", raw)
+		}
+		return f, nil
+	case "dateTime.iso8601":
+		return parseXMLRPCTime(strings.TrimSpace(raw))
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(raw), ""))
+		if err != nil {
+			return nil, errors.New("无效的 base64 数据")
+		}
+		return data, nil
+	case "nil":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("不支持的 XML-RPC 类型: %s", start.Name.Local)
+	}
+}
+
+func parseXMLRPCTime(raw string) (time.Time, error) {
+	// 不同客户端的写法不一，依次尝试常见格式；不带时区的时间视为 UTC
+	for _, layout := range []string{xmlrpcTimeFormat, "20060102T15:04:05Z07:00", "20060102T15:04:05Z", "2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05", "20060102T150405Z", "20060102T150405"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无效的时间: %s", raw)
+}
+
+func decodeXMLRPCStruct(dec *xml.Decoder) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	var name string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("解析 XML-RPC struct 失败: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "name":
+				if err := dec.DecodeElement(&name, &t); err != nil {
+					return nil, err
+				}
+			case "value":
+				value, err := decodeXMLRPCValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				result[strings.TrimSpace(name)] = value
+			}
+		case xml.EndElement:
+			if t.Name.Local == "struct" {
+				return result, nil
+			}
+		}
+	}
+}
+
+func decodeXMLRPCArray(dec *xml.Decoder) ([]interface{}, error) {
+	result := []interface{}{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("解析 XML-RPC array 失败: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "value" {
+				value, err := decodeXMLRPCValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, value)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "array" {
+				return result, nil
+			}
+		}
+	}
+}
+
+// EncodeXMLRPCResponse renders a successful <methodResponse> with one value.
+func EncodeXMLRPCResponse(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<methodResponse><params><param>")
+	if err := encodeXMLRPCValue(&buf, value); err != nil {
+		return nil, err
+	}
+	buf.WriteString("</param></params></methodResponse>\n")
+	return buf.Bytes(), nil
+}
+
+// EncodeXMLRPCFault renders a <methodResponse> carrying a fault.
+func EncodeXMLRPCFault(code int, message string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<methodResponse><fault>")
+	encodeXMLRPCValue(&buf, map[string]interface{}{"faultCode": code, "faultString": message})
+	buf.WriteString("</fault></methodResponse>\n")
+	return buf.Bytes()
+}
+
+func encodeXMLRPCValue(buf *bytes.Buffer, value interface{}) error {
+	buf.WriteString("<value>")
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("<nil/>")
+	case string:
+		buf.WriteString("<string>")
+		xml.EscapeText(buf, []byte(v))
+		buf.WriteString("</string>")
+	case int:
+		fmt.Fprintf(buf, "<int>%d</int>", v)
+	case int64:
+		fmt.Fprintf(buf, "<int>%d</int>", v)
+	case uint:
+		fmt.Fprintf(buf, "<int>%d</int>", v)
+	case bool:
+		if v {
+			buf.WriteString("<boolean>1</boolean>")
+		} else {
+			buf.WriteString("<boolean>0</boolean>")
+		}
+	case float64:
+		fmt.Fprintf(buf, "<double>%s</double>", strconv.FormatFloat(v, 'f', -1, 64))
+	case time.Time:
+		fmt.Fprintf(buf, "<dateTime.iso8601>%s</dateTime.iso8601>", v.UTC().Format(xmlrpcTimeFormat))
+	case []byte:
+		fmt.Fprintf(buf, "<base64>%s</base64>", base64.StdEncoding.EncodeToString(v))
+	case []interface{}:
+		buf.WriteString("<array><data>")
+		for _, item := range v {
+			if err := encodeXMLRPCValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</data></array>")
+	case []map[string]interface{}:
+		buf.WriteString("<array><data>")
+		for _, item := range v {
+			if err := encodeXMLRPCValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</data></array>")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteString("<struct>")
+		for _, key := range keys {
+			buf.WriteString("<member><name>")
+			xml.EscapeText(buf, []byte(key))
+			buf.WriteString("</name>")
+			if err := encodeXMLRPCValue(buf, v[key]); err != nil {
+				return err
+			}
+			buf.WriteString("</member>")
+		}
+		buf.WriteString("</struct>")
+	default:
+		return fmt.Errorf("不支持编码为 XML-RPC 的类型: %T", value)
+	}
+	buf.WriteString("</value>")
+	return nil
+}
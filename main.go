@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"glog/internal/constants"
 	"glog/internal/handlers"
 	"glog/internal/repository"
 	"glog/internal/services"
@@ -11,10 +13,11 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"github.com/gin-contrib/multitemplate"
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 )
 
@@ -39,9 +42,17 @@ func createRenderer() multitemplate.Renderer {
 	add("admin.html", "base.html", "admin.html", "_pagination.html")
 	add("editor.html", "base.html", "editor.html")
 	add("settings.html", "base.html", "settings.html")
+	add("webmentions.html", "base.html", "webmentions.html", "_pagination.html")
+	add("pings.html", "base.html", "pings.html", "_pagination.html")
 	add("login.html", "base.html", "login.html")
+	add("password.html", "base.html", "password.html")
+	add("sessions.html", "base.html", "sessions.html")
+	add("login_attempts.html", "base.html", "login_attempts.html", "_pagination.html")
+	add("csp_reports.html", "base.html", "csp_reports.html", "_pagination.html")
+	add("audit.html", "base.html", "audit.html", "_pagination.html")
 	add("search.html", "base.html", "search.html", "_pagination.html")
 	add("search_cards.html", "base.html", "search_cards.html", "_pagination.html")
+	add("ask.html", "base.html", "ask.html")
 	add("404.html", "base.html", "404.html")
 
 	return r
@@ -53,6 +64,7 @@ func main() {
 	}
 
 	unsafe := flag.Bool("unsafe", false, "allow insecure cookies")
+	disable2FA := flag.Bool("disable-2fa", false, "disable two-factor authentication and exit, for when the authenticator device is lost")
 	flag.Parse()
 
 	db, err := utils.InitDatabase()
@@ -62,50 +74,186 @@ func main() {
 
 	postRepo := repository.NewPostRepository(db)
 	settingRepo := repository.NewSettingRepository(db)
+	embeddingRepo := repository.NewEmbeddingRepository(db)
+	deliveryRepo := repository.NewDeliveryRepository(db)
+	followerRepo := repository.NewFollowerRepository(db)
+	webmentionRepo := repository.NewWebmentionRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db)
+	cspReportRepo := repository.NewCSPReportRepository(db)
+	auditLogRepo := repository.NewAuditLogRepository(db)
+	passkeyRepo := repository.NewPasskeyRepository(db)
 
 	settingService := services.NewSettingService(settingRepo)
+	if err := settingService.InitEncryption(os.Getenv("GLOG_SETTINGS_KEY")); err != nil {
+		log.Fatal(err)
+	}
+	authService := services.NewAuthService(settingService)
+	if err := authService.MigratePassword(); err != nil {
+		log.Fatal(err)
+	}
+	sessionService := services.NewSessionService(sessionRepo, settingService)
+	if err := sessionService.LoadSecret(); err != nil {
+		log.Fatal(err)
+	}
+	loginGuard := services.NewLoginGuard(loginAttemptRepo)
+	securityService := services.NewSecurityService(settingService, cspReportRepo)
+	auditService := services.NewAuditService(auditLogRepo, settingService)
+	twoFactorService := services.NewTwoFactorService(settingService)
+	passkeyService := services.NewPasskeyService(passkeyRepo, settingService)
+	oidcService := services.NewOIDCService(settingService)
+	if *disable2FA {
+		if err := twoFactorService.Disable(); err != nil {
+			log.Fatal("关闭两步验证失败：", err)
+		}
+		log.Println("两步验证已关闭，恢复码已作废")
+		return
+	}
 
 	aiService := services.NewAIService()
-	postService := services.NewPostService(postRepo, settingService, aiService)
-	backupService := services.NewBackupService(postService, settingService)
+	eventBus := services.NewEventBus()
+	postService := services.NewPostService(postRepo, settingService, aiService, eventBus)
+	// 旧文章的 HTML 可能是在更宽松的规则下生成的，启动时按当前规则重新渲染
+	if err := postService.RerenderAll(); err != nil {
+		log.Printf("重新渲染文章失败: %v", err)
+	}
+	embeddingService := services.NewEmbeddingService(embeddingRepo, postRepo, postService, settingService, aiService)
+	askService := services.NewAskService(postRepo, settingService, aiService)
+	feedService := services.NewFeedService(postRepo, settingService)
+	sitemapService := services.NewSitemapService(postRepo, settingService)
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		log.Fatal("获取数据目录失败：", err)
+	}
+	ogImageService := services.NewOGImageService(settingService, filepath.Join(dataDir, "cache", "og"))
+	mediaService := services.NewMediaService(filepath.Join(dataDir, "uploads"))
+	metaWeblogService := services.NewMetaWeblogService(postService, settingService, mediaService)
+	tokenService := services.NewTokenService(tokenRepo)
+	micropubService := services.NewMicropubService(postService)
+	deliveryQueue := services.NewDeliveryQueue(deliveryRepo)
+	activityPubService := services.NewActivityPubService(followerRepo, postRepo, settingService, deliveryQueue, eventBus)
+	webmentionService := services.NewWebmentionService(webmentionRepo, postRepo, settingService, deliveryQueue, eventBus)
+	pingService := services.NewPingService(settingService, deliveryQueue, eventBus)
+	webhookService := services.NewWebhookService(webhookRepo, settingService, deliveryQueue, eventBus)
+	backupService := services.NewBackupService(postService, settingService, eventBus)
 	scheduler := tasks.NewScheduler(settingService, backupService)
+	scheduler.RegisterJob("语义向量刷新", "@every 5m", func() error {
+		if err := embeddingService.RefreshStale(); err != nil && !errors.Is(err, services.ErrEmbeddingDisabled) {
+			return err
+		}
+		return nil
+	})
+	scheduler.RegisterJob("定时发布检查", "@every 1m", postService.AnnouncePublished)
+	scheduler.RegisterJob("投递队列", "@every 30s", deliveryQueue.ProcessDue)
+	scheduler.RegisterJob("清理过期会话", "@every 1h", sessionService.CleanupExpired)
+	scheduler.RegisterJob("清理登录记录", "@daily", loginGuard.Cleanup)
+	scheduler.RegisterJob("清理 CSP 报告", "@daily", securityService.CleanupReports)
+	scheduler.RegisterJob("清理审计日志", "@daily", auditService.Cleanup)
 
-	blogHandler := handlers.NewBlogHandler(postService)
-	adminHandler := handlers.NewAdminHandler(postService, settingService, aiService, backupService, scheduler)
-	searchHandler := handlers.NewSearchHandler(postService)
-	authHandler := handlers.NewAuthHandler(settingService)
-	apiHandler := handlers.NewAPIHandler(postService)
+	blogHandler := handlers.NewBlogHandler(postService, embeddingService, webmentionService)
+	adminHandler := handlers.NewAdminHandler(postService, settingService, aiService, backupService, scheduler, activityPubService, tokenService, webhookService, passkeyService, auditService)
+	searchHandler := handlers.NewSearchHandler(postService, embeddingService)
+	authHandler := handlers.NewAuthHandler(authService, loginGuard, twoFactorService, passkeyService, oidcService, sessionService, auditService)
+	apiHandler := handlers.NewAPIHandler(postService, settingService, auditService)
+	askHandler := handlers.NewAskHandler(askService, settingService)
+	feedHandler := handlers.NewFeedHandler(feedService)
+	sitemapHandler := handlers.NewSitemapHandler(sitemapService)
+	ogImageHandler := handlers.NewOGImageHandler(postService, ogImageService)
+	activityPubHandler := handlers.NewActivityPubHandler(activityPubService)
+	webmentionHandler := handlers.NewWebmentionHandler(webmentionService)
+	metaWeblogHandler := handlers.NewMetaWeblogHandler(metaWeblogService, settingService, loginGuard, auditService)
+	micropubHandler := handlers.NewMicropubHandler(micropubService, tokenService, mediaService, auditService)
+	tokenHandler := handlers.NewTokenHandler(tokenService, auditService)
+	pingHandler := handlers.NewPingHandler(pingService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, auditService)
+	sessionHandler := handlers.NewSessionHandler(sessionService, auditService)
+	securityHandler := handlers.NewSecurityHandler(securityService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, loginGuard, auditService)
+	passkeyHandler := handlers.NewPasskeyHandler(passkeyService, authService, twoFactorService, loginGuard, auditService)
 
 	r := gin.Default()
 	r.HTMLRender = createRenderer()
 
-	store := cookie.NewStore([]byte("secret-key-should-be-changed"))
-	store.Options(sessions.Options{
+	// 只有来自可信代理的请求才读取 X-Forwarded-For，否则使用连接地址，防止伪造 IP 绕过登录限制
+	trustedProxies, _ := settingService.GetSetting(constants.SettingTrustedProxies)
+	proxies, err := services.ParseTrustedProxies(trustedProxies)
+	if err != nil {
+		log.Printf("可信代理设置无效，已忽略: %v", err)
+		proxies = nil
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		log.Fatal(err)
+	}
+	r.Use(handlers.ClientIPMiddleware())
+	r.Use(handlers.SecurityHeadersMiddleware(securityService))
+	sessionService.Options(sessions.Options{
+		Path:     "/",
 		HttpOnly: true,
 		Secure:   !*unsafe,
 		SameSite: http.SameSiteLaxMode,
 	})
-	r.Use(sessions.Sessions("glog_session", store))
+	r.Use(sessions.Sessions("glog_session", sessionService))
 
 	r.Use(handlers.SettingsMiddleware(settingService))
+	r.Use(handlers.CSRFTokenMiddleware())
 
 	staticGroup := r.Group("/static")
 	staticGroup.Use(handlers.CacheControlMiddleware())
 	staticGroup.StaticFS("/", http.FS(staticFS))
 
+	uploadsGroup := r.Group("/uploads")
+	uploadsGroup.Use(handlers.CacheControlMiddleware())
+	uploadsGroup.Static("/", mediaService.Dir())
+
 	r.GET("/favicon.ico", func(c *gin.Context) {
 		c.File("./static/pic/favicon.ico")
 	})
 	r.GET("/", blogHandler.Index)
-	r.GET("/post/:slug", blogHandler.ShowPost)
+	r.GET("/post/:slug", activityPubHandler.NegotiatePost, blogHandler.ShowPost)
+	r.GET("/post/:slug/og.png", ogImageHandler.PostImage)
 	r.GET("/search", searchHandler.Search)
+	r.GET("/ask", askHandler.ShowAskPage)
+	r.GET("/ask/stream", askHandler.Stream)
+	r.GET("/feed.xml", feedHandler.RSS)
+	r.GET("/atom.xml", feedHandler.Atom)
+	r.GET("/feed.json", feedHandler.JSONFeed)
+	r.GET("/sitemap.xml", sitemapHandler.Sitemap)
+	r.GET("/sitemap/:part", sitemapHandler.SitemapPart)
+	r.GET("/robots.txt", sitemapHandler.Robots)
+	r.GET("/.well-known/webfinger", activityPubHandler.WebFinger)
+	r.GET("/ap/actor", activityPubHandler.Actor)
+	r.GET("/ap/outbox", activityPubHandler.Outbox)
+	r.GET("/ap/followers", activityPubHandler.Followers)
+	r.POST("/ap/inbox", activityPubHandler.Inbox)
+	r.POST("/webmention", webmentionHandler.Receive)
+	r.POST(services.CSPReportPath, securityHandler.ReceiveReport)
+	r.POST("/xmlrpc", metaWeblogHandler.XMLRPC)
+	r.GET("/rsd.xml", metaWeblogHandler.RSD)
+	r.GET("/micropub", micropubHandler.Query)
+	r.POST("/micropub", micropubHandler.Create)
+	r.POST("/micropub/media", micropubHandler.Media)
 
 	r.GET("/login", authHandler.ShowLoginPage)
 	r.POST("/login", authHandler.Login)
-	r.GET("/logout", authHandler.Logout)
+	r.POST("/login/2fa", authHandler.VerifyTwoFactor)
+	r.POST("/login/passkey/begin", authHandler.BeginPasskeyLogin)
+	r.POST("/login/passkey/finish", authHandler.FinishPasskeyLogin)
+	r.GET("/login/oidc", authHandler.BeginOIDCLogin)
+	r.GET(services.OIDCCallbackPath, authHandler.FinishOIDCLogin)
+	r.POST("/logout", handlers.CSRFMiddleware(), authHandler.Logout)
+
+	passwordGroup := r.Group("/admin/password")
+	passwordGroup.Use(handlers.AuthMiddleware(), handlers.CSRFMiddleware())
+	{
+		passwordGroup.GET("", authHandler.ShowChangePasswordPage)
+		passwordGroup.POST("", authHandler.ChangePassword)
+	}
 
 	admin := r.Group("/admin")
-	admin.Use(handlers.AuthMiddleware())
+	admin.Use(handlers.AuthMiddleware(), handlers.CSRFMiddleware(), handlers.PasswordChangeMiddleware(authService))
 	{
 		admin.GET("/", adminHandler.ListPosts)
 		admin.GET("/new", adminHandler.NewPost)
@@ -113,29 +261,62 @@ func main() {
 		admin.POST("/save", adminHandler.SavePost)
 		admin.POST("/delete/:id", adminHandler.DeletePost)
 		admin.POST("/posts/batch-update", adminHandler.BatchUpdatePosts)
+		admin.GET("/webmentions", webmentionHandler.ListMentions)
+		admin.POST("/webmentions/:id/status", webmentionHandler.UpdateStatus)
+		admin.POST("/webmentions/:id/delete", webmentionHandler.DeleteMention)
+		admin.GET("/pings", pingHandler.ListSubmissions)
+		admin.POST("/pings/:id/retry", pingHandler.RetrySubmission)
+		admin.GET("/sessions", sessionHandler.ListSessions)
+		admin.POST("/sessions/:id/revoke", sessionHandler.RevokeSession)
+		admin.POST("/sessions/revoke-all", sessionHandler.RevokeAll)
+		admin.POST("/sessions/rotate-secret", sessionHandler.RotateSecret)
+		admin.GET("/login-attempts", authHandler.ListLoginAttempts)
+		admin.GET("/csp-reports", securityHandler.ListReports)
+		admin.GET("/audit", auditHandler.ListAuditLogs)
+		admin.POST("/csp-reports/clear", securityHandler.ClearReports)
 	}
 
 	settings := r.Group("/admin/setting")
-	settings.Use(handlers.AuthMiddleware())
+	settings.Use(handlers.AuthMiddleware(), handlers.CSRFMiddleware(), handlers.PasswordChangeMiddleware(authService))
 	{
 		settings.GET("/", adminHandler.ShowSettingsPage)
 		settings.POST("/", adminHandler.UpdateSettings)
 		settings.POST("/test-ai", adminHandler.TestAISettings)
-		settings.GET("/backup", adminHandler.BackupSite)
+		settings.POST("/backup", adminHandler.BackupSite)
 		settings.POST("/upload", adminHandler.UploadBackup)
 		settings.POST("/test-github", adminHandler.TestGithubSettings)
 		settings.POST("/test-webdav", adminHandler.TestWebdavSettings)
 		settings.POST("/backup-github-now", adminHandler.BackupToGithubNow)
 		settings.POST("/backup-webdav-now", adminHandler.BackupToWebdavNow)
+		settings.POST("/metaweblog-password", metaWeblogHandler.GenerateCredential)
+		settings.POST("/metaweblog-revoke", metaWeblogHandler.RevokeCredential)
+		settings.POST("/tokens", tokenHandler.CreateToken)
+		settings.POST("/tokens/:id/revoke", tokenHandler.RevokeToken)
+		settings.POST("/webhooks", webhookHandler.CreateWebhook)
+		settings.POST("/webhooks/:id/enabled", webhookHandler.SetEnabled)
+		settings.POST("/webhooks/:id/delete", webhookHandler.DeleteWebhook)
+		settings.POST("/webhooks/:id/test", webhookHandler.SendTest)
+		settings.POST("/webhook-deliveries/:id/retry", webhookHandler.RetryDelivery)
+		settings.POST("/2fa/setup", twoFactorHandler.Setup)
+		settings.POST("/2fa/enable", twoFactorHandler.Enable)
+		settings.POST("/2fa/disable", twoFactorHandler.Disable)
+		settings.POST("/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+		settings.POST("/passkeys/begin", passkeyHandler.BeginRegistration)
+		settings.POST("/passkeys/finish", passkeyHandler.FinishRegistration)
+		settings.POST("/passkeys/:id/rename", passkeyHandler.RenamePasskey)
+		settings.POST("/passkeys/:id/delete", passkeyHandler.DeletePasskey)
 	}
 	api := r.Group("/api/v1")
-	api.Use(handlers.APIAuthMiddleware(settingService))
+	api.Use(handlers.APIAuthMiddleware(tokenService))
 	{
-		api.POST("/posts", apiHandler.CreatePost)
-		api.GET("/posts", apiHandler.FindPosts)
+		api.POST("/posts", handlers.RequireScope(services.ScopePostsWrite), apiHandler.CreatePost)
+		api.GET("/posts", handlers.RequireScope(services.ScopePostsRead), apiHandler.FindPosts)
+		api.GET("/backup", handlers.RequireScope(services.ScopeBackup), adminHandler.BackupSite)
+		api.GET("/settings", handlers.RequireScope(services.ScopeSettings), apiHandler.GetSettings)
+		api.POST("/settings", handlers.RequireScope(services.ScopeSettings), adminHandler.UpdateSettings)
 	}
 
-	r.NoRoute(blogHandler.NotFound)
+	r.NoRoute(pingHandler.IndexNowKeyFile, blogHandler.NotFound)
 
 	go scheduler.Start()
 
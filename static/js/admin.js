@@ -20,7 +20,7 @@ document.addEventListener('DOMContentLoaded', function() {
             if (confirmButton.classList.contains('delete-confirm') && !confirmButton.classList.contains('disabled')) {
                 const postId = confirmButton.dataset.id;
                 
-                fetch(`/admin/delete/${postId}`, {
+                csrfFetch(`/admin/delete/${postId}`, {
                     method: 'POST',
                 })
                 .then(response => response.json())
@@ -97,7 +97,7 @@ document.addEventListener('DOMContentLoaded', function() {
         }
 
         try {
-            const response = await fetch('/admin/posts/batch-update', {
+            const response = await csrfFetch('/admin/posts/batch-update', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ ids, action: currentAction, is_private: currentIsPrivate }),
@@ -0,0 +1,101 @@
+document.addEventListener('DOMContentLoaded', function() {
+    const form = document.getElementById('ask-form');
+    const questionInput = document.getElementById('ask-question');
+    const submitBtn = document.getElementById('ask-submit');
+    const answerEl = document.getElementById('ask-answer');
+    const sourcesEl = document.getElementById('ask-sources');
+    const sourceListEl = document.getElementById('ask-source-list');
+
+    let eventSource = null;
+    let sources = [];
+    let answerText = '';
+
+    // 将回答中的 [n] 引用替换为指向对应文章的链接
+    function renderAnswer() {
+        answerEl.textContent = '';
+        const parts = answerText.split(/(\[\d+\])/);
+        parts.forEach(part => {
+            const match = part.match(/^\[(\d+)\]$/);
+            const source = match && sources.find(s => s.index === parseInt(match[1], 10));
+            if (source) {
+                const link = document.createElement('a');
+                link.href = source.url;
+                link.textContent = part;
+                link.title = source.title;
+                link.className = 'ask-citation';
+                answerEl.appendChild(link);
+            } else {
+                answerEl.appendChild(document.createTextNode(part));
+            }
+        });
+    }
+
+    function renderSources() {
+        sourceListEl.textContent = '';
+        sources.forEach(source => {
+            const item = document.createElement('li');
+            const link = document.createElement('a');
+            link.href = source.url;
+            link.textContent = source.title;
+            item.appendChild(link);
+            sourceListEl.appendChild(item);
+        });
+        sourcesEl.hidden = sources.length === 0;
+    }
+
+    function finish() {
+        if (eventSource) {
+            eventSource.close();
+            eventSource = null;
+        }
+        submitBtn.disabled = false;
+    }
+
+    function ask(question) {
+        finish();
+        sources = [];
+        answerText = '';
+        answerEl.textContent = '思考中...';
+        answerEl.hidden = false;
+        sourcesEl.hidden = true;
+        submitBtn.disabled = true;
+
+        const url = `${form.action}?q=${encodeURIComponent(question)}`;
+        history.replaceState(null, '', `/ask?q=${encodeURIComponent(question)}`);
+        eventSource = new EventSource(url);
+
+        eventSource.addEventListener('sources', event => {
+            sources = JSON.parse(event.data);
+            renderSources();
+        });
+        eventSource.addEventListener('delta', event => {
+            answerText += JSON.parse(event.data).text;
+            renderAnswer();
+        });
+        eventSource.addEventListener('done', finish);
+        eventSource.addEventListener('error', event => {
+            // 服务端发送的 error 事件携带消息；连接错误（如频率限制）则没有
+            let message = '请求失败，可能是提问过于频繁，请稍后再试。';
+            if (event.data) {
+                message = JSON.parse(event.data).message;
+            }
+            if (!answerText) {
+                answerEl.textContent = '';
+            }
+            showNotification(message, 'error');
+            finish();
+        });
+    }
+
+    form.addEventListener('submit', function(event) {
+        event.preventDefault();
+        const question = questionInput.value.trim();
+        if (question) {
+            ask(question);
+        }
+    });
+
+    if (questionInput.value.trim()) {
+        ask(questionInput.value.trim());
+    }
+});
@@ -0,0 +1,14 @@
+document.addEventListener('DOMContentLoaded', function() {
+    const retentionForm = document.getElementById('audit-retention-form');
+    retentionForm.addEventListener('submit', async event => {
+        event.preventDefault();
+        try {
+            const response = await csrfFetch('/admin/setting/', { method: 'POST', body: new URLSearchParams(new FormData(retentionForm)) });
+            const data = await response.json();
+            showNotification(data.message, data.status === 'success' ? 'success' : 'error');
+        } catch (error) {
+            console.error('保存保留天数失败:', error);
+            showNotification('操作失败，请检查网络或后台日志！', 'error');
+        }
+    });
+});
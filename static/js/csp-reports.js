@@ -0,0 +1,20 @@
+document.addEventListener('DOMContentLoaded', function() {
+    document.getElementById('csp-clear-btn').addEventListener('click', async () => {
+        if (!confirm('确定清空所有 CSP 报告吗？')) {
+            return;
+        }
+        try {
+            const response = await csrfFetch('/admin/csp-reports/clear', { method: 'POST' });
+            const data = await response.json();
+            if (data.status === 'success') {
+                showNotification(data.message, 'success');
+                setTimeout(() => { window.location.reload(); }, 1000);
+            } else {
+                showNotification(data.message, 'error');
+            }
+        } catch (error) {
+            console.error('清空 CSP 报告失败:', error);
+            showNotification('操作失败，请检查网络或后台日志！', 'error');
+        }
+    });
+});
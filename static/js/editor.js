@@ -61,7 +61,7 @@ document.addEventListener('DOMContentLoaded', function() {
         const form = document.getElementById('app-form');
         const formData = new FormData(form);
 
-        fetch(form.action, {
+        csrfFetch(form.action, {
             method: 'POST',
             body: new URLSearchParams(formData)
         })
@@ -1,22 +1,70 @@
-document.getElementById('login-form').addEventListener('submit', function(event) {
-    event.preventDefault();
-    const form = event.target;
-    const formData = new FormData(form);
+const loginForm = document.getElementById('login-form');
+const twoFactorForm = document.getElementById('two-factor-form');
 
-    fetch(form.action, {
-        method: 'POST',
-        body: new URLSearchParams(formData)
-    })
-    .then(response => response.json())
-    .then(data => {
-        if (data.status === 'success') {
-            window.location.href = '/admin/';
+async function submitLoginForm(form) {
+    try {
+        const response = await fetch(form.action, {
+            method: 'POST',
+            body: new URLSearchParams(new FormData(form))
+        });
+        const data = await response.json();
+        if (data.status === 'success' && data.two_factor) {
+            loginForm.classList.add('hidden-file-input');
+            twoFactorForm.classList.remove('hidden-file-input');
+            document.getElementById('two-factor-code').focus();
+        } else if (data.status === 'success') {
+            window.location.href = data.redirect || '/admin/';
         } else {
             showNotification(data.message, 'error');
+            if (data.restart) {
+                twoFactorForm.reset();
+                twoFactorForm.classList.add('hidden-file-input');
+                loginForm.classList.remove('hidden-file-input');
+            }
         }
-    })
-    .catch(error => {
+    } catch (error) {
         console.error('登录请求失败:', error);
         showNotification('登录请求失败，请检查网络！', 'error');
+    }
+}
+
+[loginForm, twoFactorForm].forEach(form => {
+    form.addEventListener('submit', function(event) {
+        event.preventDefault();
+        submitLoginForm(event.target);
     });
-});
\ No newline at end of file
+});
+
+const passkeyLoginBtn = document.getElementById('passkey-login-btn');
+if (passkeyLoginBtn) {
+    passkeyLoginBtn.addEventListener('click', async () => {
+        try {
+            const beginResponse = await fetch('/login/passkey/begin', { method: 'POST' });
+            const begin = await beginResponse.json();
+            if (begin.status !== 'success') {
+                showNotification(begin.message, 'error');
+                return;
+            }
+            const assertion = await getPasskey(begin.options);
+            const response = await fetch('/login/passkey/finish', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(assertion)
+            });
+            const data = await response.json();
+            if (data.status === 'success') {
+                window.location.href = data.redirect || '/admin/';
+            } else {
+                showNotification(data.message, 'error');
+            }
+        } catch (error) {
+            console.error('通行密钥登录失败:', error);
+            showNotification('通行密钥登录已取消或失败', 'error');
+        }
+    });
+}
+
+const loginError = document.querySelector('[data-login-error]');
+if (loginError) {
+    showNotification(loginError.dataset.loginError, 'error');
+}
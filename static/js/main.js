@@ -26,6 +26,16 @@ function showNotification(message, type = 'info') {
     }, 5000);
 }
 
+// 带 CSRF 令牌的 fetch，后台的写操作都需要通过它发送
+function csrfFetch(url, options = {}) {
+    const meta = document.querySelector('meta[name="csrf-token"]');
+    const headers = new Headers(options.headers || {});
+    if (meta) {
+        headers.set('X-CSRF-Token', meta.content);
+    }
+    return fetch(url, { ...options, headers });
+}
+
 // DOM 加载完成后执行的脚本
 document.addEventListener('DOMContentLoaded', function() {
     // Auto-focus search bar on home page
@@ -57,6 +67,14 @@ document.addEventListener('DOMContentLoaded', function() {
         });
     }
 
+    // 分页每页条数选择（CSP 不允许内联事件处理器）
+    const pageSizeSelect = document.getElementById('page-size-select');
+    if (pageSizeSelect) {
+        pageSizeSelect.addEventListener('change', () => {
+            window.location = pageSizeSelect.value;
+        });
+    }
+
     // 返回顶部按钮逻辑
     const backToTopButton = document.getElementById('back-to-top');
     if (backToTopButton) {
@@ -0,0 +1,28 @@
+document.getElementById('password-form').addEventListener('submit', function(event) {
+    event.preventDefault();
+    const form = event.target;
+    const formData = new FormData(form);
+
+    if (formData.get('new_password') !== formData.get('confirm_password')) {
+        showNotification('两次输入的新密码不一致！', 'error');
+        return;
+    }
+
+    csrfFetch(form.action, {
+        method: 'POST',
+        body: new URLSearchParams(formData)
+    })
+    .then(response => response.json())
+    .then(data => {
+        if (data.status === 'success') {
+            showNotification(data.message, 'success');
+            setTimeout(() => { window.location.href = '/admin/'; }, 800);
+        } else {
+            showNotification(data.message, 'error');
+        }
+    })
+    .catch(error => {
+        console.error('修改密码请求失败:', error);
+        showNotification('修改密码请求失败，请检查网络！', 'error');
+    });
+});
@@ -0,0 +1,20 @@
+document.addEventListener('DOMContentLoaded', function() {
+    document.querySelectorAll('.delivery-retry').forEach(link => {
+        link.addEventListener('click', async event => {
+            event.preventDefault();
+            try {
+                const response = await csrfFetch(`/admin/pings/${link.dataset.id}/retry`, { method: 'POST' });
+                const data = await response.json();
+                if (data.status === 'success') {
+                    showNotification(data.message, 'success');
+                    setTimeout(() => window.location.reload(), 1500);
+                } else {
+                    showNotification(data.message, 'error');
+                }
+            } catch (error) {
+                console.error('重试推送失败:', error);
+                showNotification('操作失败，请检查网络或后台日志！', 'error');
+            }
+        });
+    });
+});
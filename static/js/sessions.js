@@ -0,0 +1,40 @@
+document.addEventListener('DOMContentLoaded', function() {
+    async function post(url, fallback) {
+        try {
+            const response = await csrfFetch(url, { method: 'POST' });
+            const data = await response.json();
+            if (data.status === 'success') {
+                showNotification(data.message, 'success');
+                setTimeout(() => { window.location.href = fallback || data.redirect || window.location.href; }, 1000);
+            } else {
+                showNotification(data.message, 'error');
+            }
+        } catch (error) {
+            console.error('会话操作失败:', error);
+            showNotification('操作失败，请检查网络或后台日志！', 'error');
+        }
+    }
+
+    document.querySelectorAll('.session-revoke').forEach(link => {
+        link.addEventListener('click', event => {
+            event.preventDefault();
+            const current = link.dataset.current === 'true';
+            if (current && !confirm('这是当前会话，撤销后需要重新登录。继续吗？')) {
+                return;
+            }
+            post(`/admin/sessions/${link.dataset.id}/revoke`, current ? '/login' : null);
+        });
+    });
+
+    document.getElementById('session-revoke-all-btn').addEventListener('click', () => {
+        if (confirm('所有设备（包括当前设备）都需要重新登录。继续吗？')) {
+            post('/admin/sessions/revoke-all');
+        }
+    });
+
+    document.getElementById('session-rotate-btn').addEventListener('click', () => {
+        if (confirm('轮换后新登录将使用新密钥签名，更早的密钥签名的 Cookie 将失效。继续吗？')) {
+            post('/admin/sessions/rotate-secret');
+        }
+    });
+});
@@ -11,19 +11,35 @@ document.addEventListener('DOMContentLoaded', function() {
 
     // --- Modal Setup using Global Function ---
     setupGlobalModal('ai-modal', 'ai-settings-btn');
+    setupGlobalModal('activitypub-modal', 'activitypub-settings-btn');
+    setupGlobalModal('webmention-modal', 'webmention-settings-btn');
+    setupGlobalModal('ping-modal', 'ping-settings-btn');
     setupGlobalModal('github-modal', 'github-backup-btn');
     setupGlobalModal('webdav-modal', 'webdav-backup-btn');
+    setupGlobalModal('security-modal', 'security-settings-btn');
+    setupGlobalModal('oidc-modal', 'oidc-settings-btn');
     // Note: password-prompt-modal is now opened programmatically when needed.
 
     // --- Form-specific Logic inside Modals ---
     attachModalFormLogic('save-ai-btn', 'ai-settings-form', 'ai-modal');
+    attachModalFormLogic('save-activitypub-btn', 'activitypub-settings-form', 'activitypub-modal');
+    attachModalFormLogic('save-webmention-btn', 'webmention-settings-form', 'webmention-modal');
+    attachModalFormLogic('save-ping-btn', 'ping-settings-form', 'ping-modal');
     attachModalFormLogic('save-github-btn', 'github-settings-form', 'github-modal');
     attachModalFormLogic('save-webdav-btn', 'webdav-settings-form', 'webdav-modal');
+    attachModalFormLogic('save-security-btn', 'security-settings-form', 'security-modal');
+    attachModalFormLogic('save-oidc-btn', 'oidc-settings-form', 'oidc-modal');
 
     attachTestConnectionLogic('test-ai-btn', 'ai-settings-form', '/admin/setting/test-ai');
     attachTestConnectionLogic('test-github-btn', 'github-settings-form', '/admin/setting/test-github');
     attachTestConnectionLogic('test-webdav-btn', 'webdav-settings-form', '/admin/setting/test-webdav');
 
+    attachMetaWeblogLogic();
+    attachTokenLogic();
+    attachWebhookLogic();
+    attachTwoFactorLogic();
+    attachPasskeyLogic();
+
     attachBackupNowLogic('backup-github-now-btn', '/admin/setting/backup-github-now');
     attachBackupNowLogic('backup-webdav-now-btn', '/admin/setting/backup-webdav-now');
 
@@ -101,7 +117,7 @@ function validateBackupJson(data) {
 
 function uploadJsonData(jsonData) {
     showNotification('正在上传并恢复...', 'info');
-    fetch('/admin/setting/upload', {
+    csrfFetch('/admin/setting/upload', {
         method: 'POST',
         headers: {
             'Content-Type': 'application/json',
@@ -127,7 +143,7 @@ function uploadZipFile(file, password = '') {
 
     showNotification('正在上传并恢复...', 'info');
 
-    fetch('/admin/setting/upload', {
+    csrfFetch('/admin/setting/upload', {
         method: 'POST',
         body: formData
     })
@@ -143,6 +159,305 @@ function uploadZipFile(file, password = '') {
     });
 }
 
+function attachMetaWeblogLogic() {
+    const generateBtn = document.getElementById('metaweblog-generate-btn');
+    const revokeBtn = document.getElementById('metaweblog-revoke-btn');
+    const passwordInput = document.getElementById('metaweblog-password');
+
+    if (generateBtn && passwordInput) {
+        generateBtn.addEventListener('click', async () => {
+            if (!confirm('生成新密码后，旧密码将立即失效。继续吗？')) {
+                return;
+            }
+            try {
+                const response = await csrfFetch('/admin/setting/metaweblog-password', { method: 'POST' });
+                const data = await response.json();
+                showNotification(data.message, data.status);
+                if (data.status === 'success') {
+                    passwordInput.value = data.password;
+                    passwordInput.classList.remove('hidden-file-input');
+                    passwordInput.select();
+                    alert('请立即复制并妥善保存新密码，它只会显示这一次：\n\n' + data.password);
+                }
+            } catch (error) {
+                console.error('生成密码失败:', error);
+                showNotification('生成密码失败，请检查网络或后台日志！', 'error');
+            }
+        });
+    }
+
+    if (revokeBtn) {
+        revokeBtn.addEventListener('click', async () => {
+            if (!confirm('停用后桌面编辑器将无法再发布文章。继续吗？')) {
+                return;
+            }
+            try {
+                const response = await csrfFetch('/admin/setting/metaweblog-revoke', { method: 'POST' });
+                const data = await response.json();
+                showNotification(data.message, data.status);
+                if (data.status === 'success') {
+                    setTimeout(() => window.location.reload(), 1000);
+                }
+            } catch (error) {
+                console.error('停用失败:', error);
+                showNotification('停用失败，请检查网络或后台日志！', 'error');
+            }
+        });
+    }
+}
+
+function attachTokenLogic() {
+    const createBtn = document.getElementById('token-create-btn');
+    const form = document.getElementById('token-form');
+    const tokenInput = document.getElementById('token-plaintext');
+
+    if (createBtn && form && tokenInput) {
+        createBtn.addEventListener('click', async () => {
+            try {
+                const response = await csrfFetch('/admin/setting/tokens', { method: 'POST', body: new FormData(form) });
+                const data = await response.json();
+                showNotification(data.message, data.status);
+                if (data.status === 'success') {
+                    tokenInput.value = data.token;
+                    tokenInput.classList.remove('hidden-file-input');
+                    tokenInput.select();
+                    alert('请立即复制并妥善保存令牌，它只会显示这一次：\n\n' + data.token);
+                    window.location.reload();
+                }
+            } catch (error) {
+                console.error('创建令牌失败:', error);
+                showNotification('创建令牌失败，请检查网络或后台日志！', 'error');
+            }
+        });
+    }
+
+    document.querySelectorAll('.token-revoke').forEach(link => {
+        link.addEventListener('click', async (event) => {
+            event.preventDefault();
+            if (!confirm('撤销后使用该令牌的客户端和脚本将无法再访问。继续吗？')) {
+                return;
+            }
+            try {
+                const response = await csrfFetch(`/admin/setting/tokens/${link.dataset.id}/revoke`, { method: 'POST' });
+                const data = await response.json();
+                showNotification(data.message, data.status);
+                if (data.status === 'success') {
+                    link.closest('.token-item').remove();
+                }
+            } catch (error) {
+                console.error('撤销令牌失败:', error);
+                showNotification('撤销令牌失败，请检查网络或后台日志！', 'error');
+            }
+        });
+    });
+}
+
+function attachWebhookLogic() {
+    const createBtn = document.getElementById('webhook-create-btn');
+    const form = document.getElementById('webhook-form');
+
+    if (createBtn && form) {
+        createBtn.addEventListener('click', async () => {
+            try {
+                const response = await csrfFetch('/admin/setting/webhooks', { method: 'POST', body: new FormData(form) });
+                const data = await response.json();
+                showNotification(data.message, data.status);
+                if (data.status === 'success') {
+                    alert('请在接收端配置以下签名密钥：\n\n' + data.secret);
+                    window.location.reload();
+                }
+            } catch (error) {
+                console.error('添加 Webhook 失败:', error);
+                showNotification('添加 Webhook 失败，请检查网络或后台日志！', 'error');
+            }
+        });
+    }
+
+    document.querySelectorAll('.webhook-action').forEach(link => {
+        link.addEventListener('click', async (event) => {
+            event.preventDefault();
+            if (link.dataset.confirm && !confirm(link.dataset.confirm)) {
+                return;
+            }
+            const body = new URLSearchParams();
+            if (link.dataset.enabled) {
+                body.set('enabled', link.dataset.enabled);
+            }
+            try {
+                const response = await csrfFetch(link.dataset.url, { method: 'POST', body: body });
+                const data = await response.json();
+                showNotification(data.message, data.status);
+                if (data.status === 'success') {
+                    setTimeout(() => window.location.reload(), 1500);
+                }
+            } catch (error) {
+                console.error('Webhook 操作失败:', error);
+                showNotification('操作失败，请检查网络或后台日志！', 'error');
+            }
+        });
+    });
+}
+
+function attachTwoFactorLogic() {
+    const showRecoveryCodes = (codes) => {
+        alert('请立即保存以下恢复码，每个只能使用一次，并且只会显示这一次：\n\n' + codes.join('\n'));
+    };
+
+    const setupBtn = document.getElementById('totp-setup-btn');
+    if (setupBtn) {
+        setupGlobalModal('totp-modal', 'totp-setup-btn');
+        setupBtn.addEventListener('click', async () => {
+            try {
+                const response = await csrfFetch('/admin/setting/2fa/setup', { method: 'POST' });
+                const data = await response.json();
+                if (data.status !== 'success') {
+                    showNotification(data.message, 'error');
+                    return;
+                }
+                document.getElementById('totp-qr').src = data.enrollment.qr_code;
+                document.getElementById('totp-secret').value = data.enrollment.secret;
+                document.getElementById('totp-code').value = '';
+            } catch (error) {
+                console.error('生成两步验证密钥失败:', error);
+                showNotification('生成密钥失败，请检查网络或后台日志！', 'error');
+            }
+        });
+
+        document.getElementById('totp-enable-btn').addEventListener('click', async () => {
+            try {
+                const form = document.getElementById('totp-form');
+                const response = await csrfFetch('/admin/setting/2fa/enable', { method: 'POST', body: new URLSearchParams(new FormData(form)) });
+                const data = await response.json();
+                showNotification(data.message, data.status);
+                if (data.status === 'success') {
+                    showRecoveryCodes(data.recovery_codes);
+                    window.location.reload();
+                }
+            } catch (error) {
+                console.error('启用两步验证失败:', error);
+                showNotification('启用两步验证失败，请检查网络或后台日志！', 'error');
+            }
+        });
+    }
+
+    const postWithCode = async (url, title, onSuccess) => {
+        const code = await showGlobalPasswordPrompt(title);
+        if (!code) {
+            return;
+        }
+        try {
+            const response = await csrfFetch(url, { method: 'POST', body: new URLSearchParams({ code: code }) });
+            const data = await response.json();
+            showNotification(data.message, data.status);
+            if (data.status === 'success') {
+                onSuccess(data);
+            }
+        } catch (error) {
+            console.error('两步验证操作失败:', error);
+            showNotification('操作失败，请检查网络或后台日志！', 'error');
+        }
+    };
+
+    const recoveryBtn = document.getElementById('totp-recovery-btn');
+    if (recoveryBtn) {
+        recoveryBtn.addEventListener('click', () => {
+            postWithCode('/admin/setting/2fa/recovery-codes', '请输入验证码（旧的恢复码将全部作废）：', data => showRecoveryCodes(data.recovery_codes));
+        });
+    }
+
+    const disableBtn = document.getElementById('totp-disable-btn');
+    if (disableBtn) {
+        disableBtn.addEventListener('click', () => {
+            postWithCode('/admin/setting/2fa/disable', '请输入验证码以关闭两步验证：', () => window.location.reload());
+        });
+    }
+}
+
+function attachPasskeyLogic() {
+    const addBtn = document.getElementById('passkey-add-btn');
+    if (addBtn) {
+        addBtn.addEventListener('click', async () => {
+            if (!window.PublicKeyCredential) {
+                showNotification('当前浏览器不支持通行密钥', 'error');
+                return;
+            }
+            const twoFactor = addBtn.dataset.twoFactor === 'true';
+            const secret = await showGlobalPasswordPrompt(twoFactor ? '请输入验证器中的验证码：' : '请输入当前密码：');
+            if (!secret) {
+                return;
+            }
+            try {
+                const beginResponse = await csrfFetch('/admin/setting/passkeys/begin', {
+                    method: 'POST',
+                    body: new URLSearchParams(twoFactor ? { code: secret } : { password: secret })
+                });
+                const begin = await beginResponse.json();
+                if (begin.status !== 'success') {
+                    showNotification(begin.message, 'error');
+                    return;
+                }
+                const credential = await createPasskey(begin.options);
+                const name = document.getElementById('passkey-name').value;
+                const response = await csrfFetch('/admin/setting/passkeys/finish?name=' + encodeURIComponent(name), {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify(credential)
+                });
+                const data = await response.json();
+                showNotification(data.message, data.status);
+                if (data.status === 'success') {
+                    setTimeout(() => window.location.reload(), 1000);
+                }
+            } catch (error) {
+                console.error('添加通行密钥失败:', error);
+                showNotification('添加通行密钥已取消或失败', 'error');
+            }
+        });
+    }
+
+    document.querySelectorAll('.passkey-rename').forEach(link => {
+        link.addEventListener('click', async (event) => {
+            event.preventDefault();
+            const name = prompt('新的名称：', link.dataset.name);
+            if (!name) {
+                return;
+            }
+            try {
+                const response = await csrfFetch(`/admin/setting/passkeys/${link.dataset.id}/rename`, { method: 'POST', body: new URLSearchParams({ name: name }) });
+                const data = await response.json();
+                showNotification(data.message, data.status);
+                if (data.status === 'success') {
+                    link.closest('.token-item').querySelector('strong').textContent = name;
+                    link.dataset.name = name;
+                }
+            } catch (error) {
+                console.error('重命名通行密钥失败:', error);
+                showNotification('重命名失败，请检查网络或后台日志！', 'error');
+            }
+        });
+    });
+
+    document.querySelectorAll('.passkey-delete').forEach(link => {
+        link.addEventListener('click', async (event) => {
+            event.preventDefault();
+            if (!confirm('删除后将无法再使用该通行密钥登录。继续吗？')) {
+                return;
+            }
+            try {
+                const response = await csrfFetch(`/admin/setting/passkeys/${link.dataset.id}/delete`, { method: 'POST' });
+                const data = await response.json();
+                showNotification(data.message, data.status);
+                if (data.status === 'success') {
+                    link.closest('.token-item').remove();
+                }
+            } catch (error) {
+                console.error('删除通行密钥失败:', error);
+                showNotification('删除失败，请检查网络或后台日志！', 'error');
+            }
+        });
+    });
+}
+
 function attachModalFormLogic(saveBtnId, formId, modalId) {
     const saveBtn = document.getElementById(saveBtnId);
     const form = document.getElementById(formId);
@@ -167,7 +482,7 @@ function attachTestConnectionLogic(testBtnId, formId, testUrl) {
             showNotification('测试中...', 'info');
             testButton.disabled = true;
 
-            fetch(testUrl, {
+            csrfFetch(testUrl, {
                 method: 'POST',
                 body: new URLSearchParams(new FormData(form))
             })
@@ -191,7 +506,7 @@ function attachBackupNowLogic(backupNowBtnId, backupNowUrl) {
             showNotification('正在备份...', 'info');
             backupButton.disabled = true;
 
-            fetch(backupNowUrl, {
+            csrfFetch(backupNowUrl, {
                 method: 'POST'
             })
             .then(res => res.json())
@@ -207,7 +522,7 @@ function attachBackupNowLogic(backupNowBtnId, backupNowUrl) {
 
 function saveFormData(formElement, callback) {
     const formData = new FormData(formElement);
-    fetch('/admin/setting', {
+    csrfFetch('/admin/setting', {
         method: 'POST',
         body: new URLSearchParams(formData)
     })
@@ -216,6 +531,7 @@ function saveFormData(formElement, callback) {
         showNotification(data.message, data.status);
         if (data.status === 'success') {
             formElement.querySelectorAll('input[type="password"]').forEach(input => input.value = '');
+            formElement.querySelectorAll('input[name="clear"]:checked').forEach(input => input.closest('label').remove());
             if (callback) callback();
         }
     })
@@ -0,0 +1,36 @@
+document.addEventListener('DOMContentLoaded', function() {
+    async function post(url, body) {
+        try {
+            const response = await csrfFetch(url, { method: 'POST', body: new URLSearchParams(body) });
+            const data = await response.json();
+            if (data.status === 'success') {
+                showNotification(data.message, 'success');
+                setTimeout(() => window.location.reload(), 600);
+            } else {
+                showNotification(data.message, 'error');
+            }
+        } catch (error) {
+            console.error('Webmention 操作失败:', error);
+            showNotification('操作失败，请检查网络或后台日志！', 'error');
+        }
+    }
+
+    const statusFilter = document.querySelector('.admin-filter-bar select[name="status"]');
+    if (statusFilter) {
+        statusFilter.addEventListener('change', () => statusFilter.form.submit());
+    }
+
+    document.querySelectorAll('.mention-action').forEach(link => {
+        link.addEventListener('click', event => {
+            event.preventDefault();
+            post(`/admin/webmentions/${link.dataset.id}/status`, { status: link.dataset.status });
+        });
+    });
+
+    document.querySelectorAll('.mention-delete').forEach(link => {
+        link.addEventListener('click', event => {
+            event.preventDefault();
+            post(`/admin/webmentions/${link.dataset.id}/delete`, {});
+        });
+    });
+});